@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/config"
+	"github.com/NekoLambda/journal-tui/internal/export"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// runExport implements `journal-tui export [--saved NAME | --query Q]
+// [--format F] [-o FILE]`, writing the entries of the journal, or those
+// matching a search, as a zip with a manifest, or as an Obsidian vault or
+// Joplin RAW export folder. `journal-tui export site` is runSite.
+func runExport(cfg config.Config, store storage.Store, args []string, out io.Writer) error {
+	if len(args) > 0 && args[0] == "site" {
+		return runSite(cfg, store, args[1:], out)
+	}
+	fset := flag.NewFlagSet("export", flag.ExitOnError)
+	saved := fset.String("saved", "", "only entries matching the saved search `NAME`")
+	q := fset.String("query", "", "only entries matching the search `QUERY`")
+	format := fset.String("format", "markdown", "export `FORMAT`: "+export.Names()+", or obsidian or joplin for a folder")
+	output := fset.String("o", "", "write the zip, or the folder, to `PATH` (default in exports/; - for a zip on stdout)")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	dirExport := map[string]func(dir string, s storage.Store, ents []storage.Entry, attachments string) error{
+		"obsidian": export.Obsidian,
+		"joplin":   export.Joplin,
+	}[strings.ToLower(*format)]
+	x, ok := export.Lookup(*format)
+	if !ok && dirExport == nil {
+		return fmt.Errorf("export: unknown format %q (have %s, obsidian, joplin)", *format, export.Names())
+	}
+	ents, scope, err := selectEntries(cfg, store, *saved, *q)
+	if err != nil {
+		return err
+	}
+
+	var p string
+	if dirExport != nil {
+		switch p = *output; p {
+		case "-":
+			return fmt.Errorf("export: %s writes a folder, not to stdout", *format)
+		case "":
+			p = filepath.Join(cfg.ExportDir(), strings.ToLower(*format)+"-"+time.Now().Format("20060102-150405"))
+		}
+		if err := dirExport(p, store, ents, cfg.DataDir()); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "exported %d notes to %s\n", len(ents), p)
+		return err
+	}
+	switch *output {
+	case "-":
+		return export.Archive(out, store, ents, x, scope)
+	case "":
+		if p, err = export.ArchiveFile(cfg.ExportDir(), store, ents, x, scope); err != nil {
+			return err
+		}
+	default:
+		if p, err = writeArchive(*output, store, ents, x, scope); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(out, "exported %d notes to %s\n", len(ents), p)
+	return err
+}
+
+// selectEntries lists the entries matching the saved search saved or the
+// query q, every entry when both are blank, returning the query used
+func selectEntries(cfg config.Config, store storage.Store, saved, q string) ([]storage.Entry, string, error) {
+	if saved != "" && q != "" {
+		return nil, "", fmt.Errorf("export: --saved and --query don't mix")
+	}
+	ents, err := storage.LoadEntries(store)
+	if err != nil {
+		return nil, "", err
+	}
+	q = strings.TrimSpace(q)
+	switch {
+	case saved != "":
+		return matchSaved(cfg, store, ents, saved)
+	case q != "":
+		if ents, err = match(cfg, store, ents, q); err != nil {
+			return nil, "", fmt.Errorf("query: %w", err)
+		}
+	}
+	return ents, q, nil
+}
+
+// writeArchive writes the archive to name, replacing it only once the
+// archive is complete
+func writeArchive(name string, store storage.Store, ents []storage.Entry, x export.Exporter, scope string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(name), ".journal-*.zip")
+	if err != nil {
+		return "", err
+	}
+	err = export.Archive(f, store, ents, x, scope)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return name, nil
+}
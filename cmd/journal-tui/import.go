@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/NekoLambda/journal-tui/internal/config"
+	"github.com/NekoLambda/journal-tui/internal/importer"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// runImport implements `journal-tui import [--format F] [--folder DIR]
+// [--dry-run] PATH`, bringing notes from another tool into the journal, and
+// the files they link to into its data folder, printing what became of each
+func runImport(cfg config.Config, store storage.Store, args []string, out io.Writer) error {
+	fset := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fset.String("format", "", "source `FORMAT`: "+importer.Names()+" (default: guessed from PATH)")
+	folder := fset.String("folder", "", "put the notes in `FOLDER`")
+	dryRun := fset.Bool("dry-run", false, "only report what would be imported")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	if fset.NArg() != 1 {
+		return fmt.Errorf("usage: journal-tui import [--format F] [--folder DIR] [--dry-run] PATH")
+	}
+	src := fset.Arg(0)
+	var x importer.Importer
+	if *format != "" {
+		var ok bool
+		if x, ok = importer.Lookup(*format); !ok {
+			return fmt.Errorf("import: unknown format %q (have %s)", *format, importer.Names())
+		}
+	} else {
+		var err error
+		if x, err = importer.Detect(src); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+	}
+	notes, err := x.Read(src)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	opts := importer.Options{Folder: *folder, DryRun: *dryRun}
+	rep, err := importer.Import(store, notes, opts)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	copied := 0
+	if a, ok := x.(importer.Attacher); ok {
+		atts, err := a.Attachments(src)
+		if err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+		if copied, err = importer.CopyAttachments(atts, cfg.DataDir(), opts); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+	}
+	for _, res := range rep.Results {
+		var line string
+		switch res.Status {
+		case importer.Imported:
+			verb := "imported "
+			if rep.DryRun {
+				verb = "new      "
+			}
+			line = verb + "  " + res.Source + " -> " + res.Filename
+		case importer.Duplicate:
+			line = "duplicate  " + res.Source + " (same as " + res.Filename + ")"
+		default:
+			line = "skipped    " + res.Source + ": " + res.Reason
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	summary := rep.Summary()
+	if copied > 0 {
+		summary += fmt.Sprintf(", %d attachments", copied)
+	}
+	_, err = fmt.Fprintf(out, "%s (%s)\n", summary, x.Name())
+	return err
+}
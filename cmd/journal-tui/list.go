@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/NekoLambda/journal-tui/internal/config"
+	"github.com/NekoLambda/journal-tui/internal/query"
+	"github.com/NekoLambda/journal-tui/internal/search"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+const timeLayout = "2006-01-02 15:04"
+
+// runList implements `journal-tui list [--saved NAME]`, printing the entries
+// of the journal or those matching a saved search, one per line
+func runList(cfg config.Config, store storage.Store, args []string, out io.Writer) error {
+	fset := flag.NewFlagSet("list", flag.ExitOnError)
+	saved := fset.String("saved", "", "only entries matching the saved search `NAME`")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	ents, err := storage.LoadEntries(store)
+	if err != nil {
+		return err
+	}
+	if *saved != "" {
+		if ents, _, err = matchSaved(cfg, store, ents, *saved); err != nil {
+			return err
+		}
+	}
+	for _, e := range ents {
+		line := e.Created.Format(timeLayout) + "  " + e.Title
+		if len(e.Tags) > 0 {
+			line += "  #" + strings.Join(e.Tags, " #")
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchSaved narrows ents to those matching the saved search name,
+// returning them along with its query
+func matchSaved(cfg config.Config, store storage.Store, ents []storage.Entry, name string) ([]storage.Entry, string, error) {
+	settings, err := config.LoadSettings(cfg.SettingsFile())
+	if err != nil {
+		return nil, "", err
+	}
+	ss, ok := settings.FindSaved(name)
+	if !ok {
+		if len(settings.Saved) == 0 {
+			return nil, "", fmt.Errorf("no saved search %q (none saved yet)", name)
+		}
+		return nil, "", fmt.Errorf("no saved search %q (have %s)", name, settings.SavedNames())
+	}
+	ents, err = match(cfg, store, ents, ss.Query)
+	if err != nil {
+		return nil, "", fmt.Errorf("saved search %q: %w", ss.Name, err)
+	}
+	return ents, ss.Query, nil
+}
+
+// match narrows ents to those matching the query q, as the TUI's search
+// would list them
+func match(cfg config.Config, store storage.Store, ents []storage.Entry, q string) ([]storage.Entry, error) {
+	node, err := query.Parse(q)
+	if err != nil {
+		return nil, err
+	}
+	ix, err := loadIndex(cfg, store, ents)
+	if err != nil {
+		return nil, err
+	}
+	ents, _ = query.Match(node, q, ents, query.Env{Index: ix, Content: func(id string) (string, error) {
+		e, err := store.Get(id)
+		return e.Content, err
+	}})
+	return ents, nil
+}
+
+// loadIndex brings the saved search index up to date with ents
+func loadIndex(cfg config.Config, store storage.Store, ents []storage.Entry) (*search.Index, error) {
+	ix, path := search.New(), cfg.SearchIndex()
+	if path != "" {
+		ix.Load(path)
+	}
+	if err := ix.Sync(store, ents, nil); err != nil {
+		return nil, err
+	}
+	if path != "" && ix.Dirty() {
+		// the index is only a cache, as in the TUI
+		_ = ix.Save(path)
+	}
+	return ix, nil
+}
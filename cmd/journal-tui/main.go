@@ -1,14 +1,60 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
+	"github.com/NekoLambda/journal-tui/internal/config"
 	"github.com/NekoLambda/journal-tui/internal/model"
+	"github.com/NekoLambda/journal-tui/internal/storage"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
 func main() {
-	p := tea.NewProgram(model.New())
+	dataDir := flag.String("data-dir", "", "journal root (default $"+config.EnvDir+" or $XDG_DATA_HOME/journal-tui)")
+	editor := flag.String("editor", "", "editor command (default $"+config.EnvEditor+", $VISUAL or $EDITOR)")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "usage: journal-tui [flags] [list [--saved NAME] | export [site] [--saved NAME | --query Q] [--format F] [-o FILE] | import [--format F] [--folder DIR] [--dry-run] PATH]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.Load(*dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *editor != "" {
+		cfg.Editor = *editor
+	}
+	store := storage.NewFSStore(cfg.DataDir())
+	// one-shot move of legacy metadata.json tags into front matter
+	if _, err := store.MigrateMetadata(); err != nil {
+		log.Fatalf("migrating metadata.json: %v", err)
+	}
+	switch cmd := flag.Arg(0); cmd {
+	case "":
+	case "list":
+		if err := runList(cfg, store, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "import":
+		if err := runImport(cfg, store, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "export":
+		if err := runExport(cfg, store, flag.Args()[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("unknown command %q (try -h)", cmd)
+	}
+	p := tea.NewProgram(model.New(store, cfg))
 	if err := p.Start(); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/NekoLambda/journal-tui/internal/config"
+	"github.com/NekoLambda/journal-tui/internal/export"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// runSite implements `journal-tui export site [--saved NAME | --query Q]
+// [--title T] [-o DIR]`, publishing the entries of the journal, or those
+// matching a search, as a static site
+func runSite(cfg config.Config, store storage.Store, args []string, out io.Writer) error {
+	fset := flag.NewFlagSet("export site", flag.ExitOnError)
+	saved := fset.String("saved", "", "only entries matching the saved search `NAME`")
+	q := fset.String("query", "", "only entries matching the search `QUERY`, e.g. 'folder:handbook OR folder:team'")
+	title := fset.String("title", "Journal", "site `TITLE`")
+	output := fset.String("o", "", "write the site to `DIR`, replacing an earlier one (default exports/site)")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+	ents, _, err := selectEntries(cfg, store, *saved, *q)
+	if err != nil {
+		return err
+	}
+	dir := *output
+	if dir == "" {
+		dir = filepath.Join(cfg.ExportDir(), "site")
+	}
+	n, err := export.Site(dir, store, ents, export.SiteOptions{Title: *title, Attachments: cfg.DataDir()})
+	if err != nil {
+		return fmt.Errorf("export site: %w", err)
+	}
+	msg := fmt.Sprintf("published %d notes to %s", n, dir)
+	if private := len(ents) - n; private > 0 {
+		msg += fmt.Sprintf(" (%d private left out)", private)
+	}
+	_, err = fmt.Fprintln(out, msg)
+	return err
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// EnvDir overrides the journal root when no --data-dir flag is given
+	EnvDir = "JOURNAL_TUI_DIR"
+	// EnvEditor overrides $VISUAL and $EDITOR for journal-tui only
+	EnvEditor = "JOURNAL_TUI_EDITOR"
+)
+
+// Config holds the resolved journal locations
+type Config struct {
+	Root   string // journal root; entries live in Root/data, exports in Root/exports
+	Editor string // editor command override, may include arguments
+}
+
+// DataDir is where entries and metadata.json are kept
+func (c Config) DataDir() string { return filepath.Join(c.Root, "data") }
+
+// ExportDir is where exports are written
+func (c Config) ExportDir() string { return filepath.Join(c.Root, "exports") }
+
+// SearchIndex is the full-text index file, or "" when there is no root to
+// keep it in (the index is then rebuilt in memory every run)
+func (c Config) SearchIndex() string {
+	if c.Root == "" {
+		return ""
+	}
+	return filepath.Join(c.Root, "search.idx")
+}
+
+// Load resolves the journal root, in order of precedence, from the --data-dir
+// flag value, $JOURNAL_TUI_DIR, or $XDG_DATA_HOME/journal-tui (falling back to
+// ~/.local/share/journal-tui when XDG_DATA_HOME is unset)
+func Load(flagDir string) (Config, error) {
+	root := flagDir
+	if root == "" {
+		root = os.Getenv(EnvDir)
+	}
+	if root == "" {
+		base := os.Getenv("XDG_DATA_HOME")
+		if base == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return Config{}, errors.New("cannot locate journal: set --data-dir, " + EnvDir + " or XDG_DATA_HOME")
+			}
+			base = filepath.Join(home, ".local", "share")
+		}
+		root = filepath.Join(base, "journal-tui")
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return Config{}, err
+	}
+	return Config{Root: abs, Editor: os.Getenv(EnvEditor)}, nil
+}
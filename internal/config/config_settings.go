@@ -0,0 +1,177 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SavedSearch is a search box query kept under a name
+type SavedSearch struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// Retention defaults, used unless the settings say otherwise
+const (
+	DefaultTrashDays   = 30  // days deleted entries stay in the trash
+	DefaultHistoryKeep = 50  // revisions kept per entry
+	DefaultHistoryDays = 365 // days revisions are kept
+)
+
+// Settings are the per-journal preferences kept in the journal's config file
+type Settings struct {
+	Saved []SavedSearch `json:"saved_searches,omitempty"`
+	// TrashDays is how many days deleted entries are kept before being purged:
+	// 0 means DefaultTrashDays, a negative number keeps them until purged by hand
+	TrashDays int `json:"trash_days,omitempty"`
+	// HistoryKeep and HistoryDays cap the revisions kept of each entry, by
+	// number and by age: 0 means the default, a negative number no limit
+	HistoryKeep int `json:"history_keep,omitempty"`
+	HistoryDays int `json:"history_days,omitempty"`
+}
+
+// TrashRetention is how long deleted entries are kept, 0 for forever
+func (s Settings) TrashRetention() time.Duration {
+	days := s.TrashDays
+	switch {
+	case days < 0:
+		return 0
+	case days == 0:
+		days = DefaultTrashDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// HistoryRetention is how many revisions of each entry are kept and for how
+// long, 0 meaning no limit
+func (s Settings) HistoryRetention() (keep int, age time.Duration) {
+	keep, days := s.HistoryKeep, s.HistoryDays
+	switch {
+	case keep < 0:
+		keep = 0
+	case keep == 0:
+		keep = DefaultHistoryKeep
+	}
+	switch {
+	case days < 0:
+		days = 0
+	case days == 0:
+		days = DefaultHistoryDays
+	}
+	return keep, time.Duration(days) * 24 * time.Hour
+}
+
+// SettingsFile is the journal's config file, or "" when there is no root to
+// keep it in
+func (c Config) SettingsFile() string {
+	if c.Root == "" {
+		return ""
+	}
+	return filepath.Join(c.Root, "config.json")
+}
+
+// LoadSettings reads the settings at path; a missing file (or an empty path)
+// gives empty settings
+func LoadSettings(path string) (Settings, error) {
+	var s Settings
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Settings{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save writes the settings to path atomically
+func (s Settings) Save(path string) error {
+	if path == "" {
+		return errors.New("no journal directory to save settings in")
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(append(data, '\n'))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// FindSaved looks up a saved search by name, ignoring case
+func (s Settings) FindSaved(name string) (SavedSearch, bool) {
+	if i := s.savedIndex(name); i >= 0 {
+		return s.Saved[i], true
+	}
+	return SavedSearch{}, false
+}
+
+// SetSaved saves query under name, replacing a search of the same name
+func (s *Settings) SetSaved(name, query string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("saved search needs a name")
+	}
+	if i := s.savedIndex(name); i >= 0 {
+		s.Saved[i] = SavedSearch{Name: name, Query: query}
+		return nil
+	}
+	s.Saved = append(s.Saved, SavedSearch{Name: name, Query: query})
+	return nil
+}
+
+// RemoveSaved deletes the saved search called name, reporting whether there was one
+func (s *Settings) RemoveSaved(name string) bool {
+	i := s.savedIndex(name)
+	if i < 0 {
+		return false
+	}
+	s.Saved = append(s.Saved[:i], s.Saved[i+1:]...)
+	return true
+}
+
+// SavedNames lists the saved search names, for messages
+func (s Settings) SavedNames() string {
+	names := make([]string, len(s.Saved))
+	for i, ss := range s.Saved {
+		names[i] = ss.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s Settings) savedIndex(name string) int {
+	for i, ss := range s.Saved {
+		if strings.EqualFold(ss.Name, strings.TrimSpace(name)) {
+			return i
+		}
+	}
+	return -1
+}
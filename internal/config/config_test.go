@@ -0,0 +1,92 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", "/xdg")
+	t.Setenv(EnvDir, "")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Root != filepath.Join("/xdg", "journal-tui") {
+		t.Errorf("expected XDG default, got %s", cfg.Root)
+	}
+
+	t.Setenv(EnvDir, "/from-env")
+	if cfg, _ = Load(""); cfg.Root != "/from-env" {
+		t.Errorf("expected env override, got %s", cfg.Root)
+	}
+
+	if cfg, _ = Load("/from-flag"); cfg.Root != "/from-flag" {
+		t.Errorf("expected flag override, got %s", cfg.Root)
+	}
+	if cfg.DataDir() != filepath.Join("/from-flag", "data") || cfg.ExportDir() != filepath.Join("/from-flag", "exports") {
+		t.Errorf("unexpected layout: %s %s", cfg.DataDir(), cfg.ExportDir())
+	}
+}
+
+func TestSettingsSavedSearches(t *testing.T) {
+	cfg := Config{Root: t.TempDir()}
+	s, err := LoadSettings(cfg.SettingsFile())
+	if err != nil || len(s.Saved) != 0 {
+		t.Fatalf("missing file: %+v, %v", s, err)
+	}
+	if err := s.SetSaved(" ", "x"); err == nil {
+		t.Error("expected an error for a blank name")
+	}
+	s.SetSaved("1on1", "tag:1on1 todo")
+	s.SetSaved("drafts", "tag:draft")
+	s.SetSaved("1ON1", "tag:1on1 action") // replaces, ignoring case
+	if err := s.Save(cfg.SettingsFile()); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadSettings(cfg.SettingsFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Saved) != 2 || got.SavedNames() != "1ON1, drafts" {
+		t.Fatalf("saved = %+v", got.Saved)
+	}
+	if ss, ok := got.FindSaved("1on1"); !ok || ss.Query != "tag:1on1 action" {
+		t.Errorf("FindSaved = %+v, %v", ss, ok)
+	}
+	if !got.RemoveSaved("Drafts") || got.RemoveSaved("drafts") {
+		t.Error("RemoveSaved should remove once")
+	}
+	if _, ok := got.FindSaved("drafts"); ok {
+		t.Error("drafts still saved")
+	}
+}
+
+func TestSettingsTrashRetention(t *testing.T) {
+	for _, tc := range []struct {
+		days int
+		want time.Duration
+	}{
+		{0, DefaultTrashDays * 24 * time.Hour},
+		{7, 7 * 24 * time.Hour},
+		{-1, 0},
+	} {
+		if got := (Settings{TrashDays: tc.days}).TrashRetention(); got != tc.want {
+			t.Errorf("TrashDays %d: retention %v, want %v", tc.days, got, tc.want)
+		}
+	}
+}
+
+func TestSettingsHistoryRetention(t *testing.T) {
+	keep, age := Settings{}.HistoryRetention()
+	if keep != DefaultHistoryKeep || age != DefaultHistoryDays*24*time.Hour {
+		t.Errorf("default retention = %d, %v", keep, age)
+	}
+	keep, age = Settings{HistoryKeep: -1, HistoryDays: 7}.HistoryRetention()
+	if keep != 0 || age != 7*24*time.Hour {
+		t.Errorf("retention = %d, %v", keep, age)
+	}
+}
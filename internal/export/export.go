@@ -0,0 +1,133 @@
+// Package export writes journal entries out in formats other tools read:
+// Markdown with front matter, standalone HTML, JSON and plain text, one by
+// one or zipped with a manifest; Obsidian vaults and Joplin RAW exports; and
+// static sites.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Exporter renders a single entry in one format
+type Exporter interface {
+	// Name is the format's name, e.g. "html"
+	Name() string
+	// Ext is the extension of exported files, e.g. ".html"
+	Ext() string
+	// Export writes e, content included, to w
+	Export(w io.Writer, e storage.Entry) error
+}
+
+var formats = []Exporter{Markdown{}, HTML{}, JSON{}, Text{}}
+
+// Formats lists the export formats, Markdown first
+func Formats() []Exporter {
+	return append([]Exporter(nil), formats...)
+}
+
+// Lookup finds an export format by name, ignoring case
+func Lookup(name string) (Exporter, bool) {
+	for _, x := range formats {
+		if strings.EqualFold(x.Name(), name) {
+			return x, true
+		}
+	}
+	return nil, false
+}
+
+// Names lists the format names, comma separated
+func Names() string {
+	names := make([]string, len(formats))
+	for i, x := range formats {
+		names[i] = x.Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// FileName is the name an exported entry gets: its own file name with the
+// format's extension
+func FileName(x Exporter, e storage.Entry) string {
+	return strings.TrimSuffix(path.Base(e.Filename), ".md") + x.Ext()
+}
+
+// File exports e into dir and returns the path written. An existing file is
+// never overwritten; a -N suffix is added instead.
+func File(x Exporter, e storage.Entry, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating exports dir: %w", err)
+	}
+	name := FileName(x, e)
+	base := strings.TrimSuffix(name, x.Ext())
+	for i := 1; ; i++ {
+		p := filepath.Join(dir, name)
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			name = fmt.Sprintf("%s-%d%s", base, i, x.Ext())
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		err = x.Export(f, e)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(p)
+			return "", err
+		}
+		return p, nil
+	}
+}
+
+// Record is an entry's metadata, and optionally its content, as exported to
+// JSON
+type Record struct {
+	ID       string         `json:"id"`
+	Title    string         `json:"title"`
+	Filename string         `json:"filename"`
+	Folder   string         `json:"folder,omitempty"`
+	Created  time.Time      `json:"created"`
+	Updated  time.Time      `json:"updated"`
+	Tags     []string       `json:"tags"`
+	Fields   map[string]any `json:"fields,omitempty"`
+	Content  string         `json:"content,omitempty"`
+}
+
+// NewRecord describes e, content included
+func NewRecord(e storage.Entry) Record {
+	tags := e.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return Record{
+		ID:       e.ID,
+		Title:    e.Title,
+		Filename: e.Filename,
+		Folder:   e.Folder(),
+		Created:  e.Created,
+		Updated:  e.Updated,
+		Tags:     tags,
+		Fields:   e.Fields,
+		Content:  e.Content,
+	}
+}
+
+// body is the content of e without the "# title" heading it starts with
+func body(e storage.Entry) string {
+	content := strings.TrimLeft(e.Content, "\n")
+	first, rest, _ := strings.Cut(content, "\n")
+	if strings.TrimSpace(strings.TrimPrefix(first, "# ")) == e.Title && strings.HasPrefix(first, "# ") {
+		return strings.TrimLeft(rest, "\n")
+	}
+	return content
+}
@@ -0,0 +1,121 @@
+package export
+
+import (
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// attachments finds the files below a journal's data folder that notes link
+// to, images and such kept next to the notes
+type attachments struct {
+	root   string
+	files  map[string]bool     // slash paths below root
+	byName map[string][]string // base name -> paths
+}
+
+// loadAttachments indexes the files in root other than notes; a blank root
+// has none
+func loadAttachments(root string) (*attachments, error) {
+	a := &attachments{root: root, files: map[string]bool{}, byName: map[string][]string{}}
+	if root == "" {
+		return a, nil
+	}
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			// the journal's own .trash and .history
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || strings.EqualFold(filepath.Ext(p), ".md") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		a.files[rel] = true
+		a.byName[path.Base(rel)] = append(a.byName[path.Base(rel)], rel)
+		return nil
+	})
+	return a, err
+}
+
+var (
+	wikiTarget = regexp.MustCompile(`\[\[([^\]|#^]+)`)
+	linkTarget = regexp.MustCompile(`\]\(<?([^)\s>]+)>?(?:\s[^)]*)?\)`)
+)
+
+// link is a link in a note to an attachment
+type link struct {
+	target string // as written in the note
+	path   string // the file below root
+	wiki   bool   // a [[wikilink]] rather than a markdown link
+}
+
+// links returns the attachments that content, a note in folder, links to:
+// relative markdown links, and wikilinks by path or file name as Obsidian
+// resolves them
+func (a *attachments) links(content, folder string) []link {
+	var out []link
+	for _, m := range linkTarget.FindAllStringSubmatch(content, -1) {
+		target := m[1]
+		if strings.Contains(target, ":") || strings.HasPrefix(target, "/") || strings.HasPrefix(target, "#") {
+			continue
+		}
+		p := target
+		if unescaped, err := url.PathUnescape(target); err == nil {
+			p = unescaped
+		}
+		if p = path.Join(folder, p); a.files[p] {
+			out = append(out, link{target, p, false})
+		}
+	}
+	for _, m := range wikiTarget.FindAllStringSubmatch(content, -1) {
+		target := strings.TrimSpace(m[1])
+		if ext := path.Ext(target); ext == "" || strings.EqualFold(ext, ".md") {
+			continue
+		}
+		if a.files[target] {
+			out = append(out, link{target, target, true})
+		} else if ps := a.byName[path.Base(target)]; len(ps) > 0 {
+			out = append(out, link{target, ps[0], true})
+		}
+	}
+	return out
+}
+
+// copyTo copies the attachment at p to dst, unless something is there
+func (a *attachments) copyTo(p, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(filepath.Join(a.root, filepath.FromSlash(p)))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Markdown exports the entry file as the journal keeps it: front matter
+// followed by the note
+type Markdown struct{}
+
+func (Markdown) Name() string { return "markdown" }
+func (Markdown) Ext() string  { return ".md" }
+
+func (Markdown) Export(w io.Writer, e storage.Entry) error {
+	_, err := w.Write(storage.MarshalEntry(e))
+	return err
+}
+
+// JSON exports the entry as an indented Record
+type JSON struct{}
+
+func (JSON) Name() string { return "json" }
+func (JSON) Ext() string  { return ".json" }
+
+func (JSON) Export(w io.Writer, e storage.Entry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewRecord(e))
+}
+
+// Text exports the entry as plain text: the title, a line of metadata and
+// the note with its markdown markup taken out
+type Text struct{}
+
+func (Text) Name() string { return "text" }
+func (Text) Ext() string  { return ".txt" }
+
+func (Text) Export(w io.Writer, e storage.Entry) error {
+	var b strings.Builder
+	b.WriteString(e.Title + "\n")
+	b.WriteString(strings.Repeat("=", max(len([]rune(e.Title)), 3)) + "\n")
+	b.WriteString("Created " + e.Created.Format("2006-01-02 15:04"))
+	if !e.Updated.Equal(e.Created) {
+		b.WriteString(" · Updated " + e.Updated.Format("2006-01-02 15:04"))
+	}
+	if len(e.Tags) > 0 {
+		b.WriteString(" · Tags: " + strings.Join(e.Tags, ", "))
+	}
+	b.WriteString("\n\n" + PlainText(body(e)))
+	_, err := io.WriteString(w, strings.TrimRight(b.String(), "\n")+"\n")
+	return err
+}
+
+var (
+	mdImage  = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)[^)]*\)`)
+	mdStrong = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	mdEm     = regexp.MustCompile(`(^|[\s(])[*_](\S(?:[^*_]*\S)?)[*_]`)
+	mdCode   = regexp.MustCompile("`([^`]+)`")
+	mdStrike = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	mdHead   = regexp.MustCompile(`^#{1,6}\s+`)
+	mdBullet = regexp.MustCompile(`^(\s*)[*+]\s+`)
+	mdQuote  = regexp.MustCompile(`^\s*>\s?`)
+	mdRule   = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+)
+
+// PlainText takes the markdown markup out of md, keeping code blocks as they
+// are and link targets in parentheses
+func PlainText(md string) string {
+	var b strings.Builder
+	fenced := false
+	for _, line := range strings.Split(md, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			b.WriteString(line + "\n")
+			continue
+		}
+		if mdRule.MatchString(line) {
+			b.WriteString("\n")
+			continue
+		}
+		line = mdQuote.ReplaceAllString(line, "")
+		line = mdHead.ReplaceAllString(line, "")
+		line = mdBullet.ReplaceAllString(line, "$1- ")
+		line = mdImage.ReplaceAllString(line, "$1")
+		line = mdLink.ReplaceAllString(line, "$1 ($2)")
+		line = mdCode.ReplaceAllString(line, "$1")
+		line = mdStrong.ReplaceAllString(line, "$2")
+		line = mdEm.ReplaceAllString(line, "$1$2")
+		line = mdStrike.ReplaceAllString(line, "$1")
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
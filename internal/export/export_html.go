@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// md renders notes the way most markdown tools do (tables, task lists,
+// strikethrough and bare links included). Raw HTML in a note is left out.
+var md = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// RenderMarkdown renders markdown source to an HTML fragment
+func RenderMarkdown(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// HTML exports the entry as a standalone page: the rendered note, its dates
+// and tags, and the stylesheet inline so it opens anywhere
+type HTML struct{}
+
+func (HTML) Name() string { return "html" }
+func (HTML) Ext() string  { return ".html" }
+
+func (HTML) Export(w io.Writer, e storage.Entry) error {
+	rendered, err := RenderMarkdown(body(e))
+	if err != nil {
+		return err
+	}
+	return pageTmpl.Execute(w, struct {
+		Entry  storage.Entry
+		Edited bool
+		Body   template.HTML
+		CSS    template.CSS
+	}{e, !e.Updated.Equal(e.Created), rendered, Stylesheet})
+}
+
+// Stylesheet is the CSS inlined into exported pages
+const Stylesheet template.CSS = `
+:root { color-scheme: light dark; --fg: #282a36; --bg: #fdfdfd; --muted: #6272a4; --accent: #bd93f9; }
+@media (prefers-color-scheme: dark) { :root { --fg: #f8f8f2; --bg: #282a36; --muted: #8b95c9; } }
+body { margin: 0; background: var(--bg); color: var(--fg);
+  font: 17px/1.6 -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; }
+main { max-width: 46rem; margin: 0 auto; padding: 2rem 1.25rem 4rem; }
+h1, h2, h3 { line-height: 1.25; }
+a { color: var(--accent); }
+.meta { color: var(--muted); font-size: .9rem; margin: -.5rem 0 2rem; }
+.tag { display: inline-block; margin: 0 .25rem .25rem 0; padding: 0 .5rem; border-radius: 1rem;
+  background: var(--accent); color: #282a36; font-size: .8rem; text-decoration: none; }
+pre, code { font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; font-size: .9em; }
+pre { overflow-x: auto; padding: 1rem; border-radius: .5rem; background: rgba(127, 127, 127, .12); }
+blockquote { margin: 0; padding-left: 1rem; border-left: 3px solid var(--accent); color: var(--muted); }
+table { border-collapse: collapse; } th, td { border: 1px solid rgba(127, 127, 127, .4); padding: .25rem .5rem; }
+img { max-width: 100%; }
+`
+
+var pageTmpl = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Entry.Title}}</title>
+<style>{{.CSS}}</style>
+</head>
+<body>
+<main>
+<article>
+<h1>{{.Entry.Title}}</h1>
+<p class="meta"><time datetime="{{.Entry.Created.Format "2006-01-02T15:04:05Z07:00"}}">{{.Entry.Created.Format "2 Jan 2006 15:04"}}</time>
+{{- if .Edited}} · updated {{.Entry.Updated.Format "2 Jan 2006 15:04"}}{{end}}
+{{- range .Entry.Tags}} <span class="tag">#{{.}}</span>{{end}}</p>
+{{.Body}}
+</article>
+</main>
+</body>
+</html>
+`))
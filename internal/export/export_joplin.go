@@ -0,0 +1,175 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Joplin writes entries into dir as a Joplin RAW export: a note per entry, a
+// notebook per folder, and Joplin's tags and note tags. Note ids are the
+// entries' ids in hex, so they survive a round trip; the rest get ids derived
+// from their names. Files notes link to in attachments, the journal's data
+// folder, become resources.
+func Joplin(dir string, s storage.Store, entries []storage.Entry, attachments string) error {
+	atts, err := loadAttachments(attachments)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "resources"), 0o755); err != nil {
+		return err
+	}
+	now := time.Now()
+	write := func(id, text string, props [][2]string) error {
+		var b strings.Builder
+		if text != "" {
+			b.WriteString(text + "\n\n")
+		}
+		for _, p := range props {
+			b.WriteString(p[0] + ": " + p[1] + "\n")
+		}
+		return os.WriteFile(filepath.Join(dir, id+".md"), []byte(strings.TrimSuffix(b.String(), "\n")), 0o644)
+	}
+	folders := map[string]string{} // path -> id
+	var folder func(p string) (string, error)
+	folder = func(p string) (string, error) {
+		if p == "" {
+			return "", nil
+		}
+		if id, ok := folders[p]; ok {
+			return id, nil
+		}
+		up := path.Dir(p)
+		if up == "." {
+			up = ""
+		}
+		parent, err := folder(up)
+		if err != nil {
+			return "", err
+		}
+		id := joplinID("folder", p)
+		folders[p] = id
+		return id, write(id, path.Base(p), joplinProps(id, now, now,
+			[2]string{"parent_id", parent}, [2]string{"is_shared", "0"}, [2]string{"type_", "2"}))
+	}
+	tags := map[string]bool{}
+	resources := map[string]string{} // attachment path -> id
+	for _, e := range entries {
+		full, err := s.Get(e.ID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Title, err)
+		}
+		parent, err := folder(full.Folder())
+		if err != nil {
+			return err
+		}
+		noteID := storage.IDHex(full.ID)
+		text := body(full)
+		for _, l := range atts.links(text, full.Folder()) {
+			id, ok := resources[l.path]
+			if !ok {
+				if id, err = joplinResource(dir, atts, l.path, write, now); err != nil {
+					return err
+				}
+				resources[l.path] = id
+			}
+			if l.wiki {
+				// Joplin has no wikilinks
+				md := "[" + path.Base(l.target) + "](:/" + id + ")"
+				text = strings.ReplaceAll(text, "[["+l.target+"]]", md)
+			} else {
+				text = strings.ReplaceAll(text, "("+l.target+")", "(:/"+id+")")
+			}
+		}
+		text = strings.TrimRight(full.Title+"\n\n"+strings.TrimSpace(text), "\n")
+		props := joplinProps(noteID, full.Created, full.Updated,
+			[2]string{"parent_id", parent}, [2]string{"is_conflict", "0"}, [2]string{"latitude", "0.00000000"},
+			[2]string{"longitude", "0.00000000"}, [2]string{"altitude", "0.0000"}, [2]string{"author", ""},
+			[2]string{"source_url", ""}, [2]string{"is_todo", "0"}, [2]string{"todo_due", "0"},
+			[2]string{"todo_completed", "0"}, [2]string{"source", "journal-tui"},
+			[2]string{"source_application", "journal-tui"}, [2]string{"application_data", ""},
+			[2]string{"order", "0"}, [2]string{"markup_language", "1"}, [2]string{"is_shared", "0"},
+			[2]string{"type_", "1"})
+		if err := write(noteID, text, props); err != nil {
+			return err
+		}
+		for _, tag := range full.Tags {
+			tagID := joplinID("tag", tag)
+			if !tags[tag] {
+				tags[tag] = true
+				if err := write(tagID, tag, joplinProps(tagID, now, now,
+					[2]string{"is_shared", "0"}, [2]string{"parent_id", ""}, [2]string{"type_", "5"})); err != nil {
+					return err
+				}
+			}
+			linkID := joplinID("note_tag", noteID+tagID)
+			if err := write(linkID, "", joplinProps(linkID, now, now, [2]string{"note_id", noteID},
+				[2]string{"tag_id", tagID}, [2]string{"is_shared", "0"}, [2]string{"type_", "6"})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// joplinResource copies the attachment at p into resources and writes its
+// item, returning its id
+func joplinResource(dir string, atts *attachments, p string, write func(string, string, [][2]string) error, now time.Time) (string, error) {
+	ext := strings.TrimPrefix(path.Ext(p), ".")
+	id := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		id = joplinID("resource", p)
+	}
+	name := id
+	if ext != "" {
+		name += "." + ext
+	}
+	dst := filepath.Join(dir, "resources", name)
+	if err := atts.copyTo(p, dst); err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		return "", err
+	}
+	mimeType := mime.TypeByExtension(path.Ext(p))
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return id, write(id, path.Base(p), joplinProps(id, now, now,
+		[2]string{"mime", mimeType}, [2]string{"filename", ""}, [2]string{"file_extension", ext},
+		[2]string{"size", fmt.Sprint(fi.Size())}, [2]string{"is_shared", "0"}, [2]string{"type_", "4"}))
+}
+
+// joplinProps is an item's id and times, with encryption off, followed by
+// more properties ending in its type_
+func joplinProps(id string, created, updated time.Time, more ...[2]string) [][2]string {
+	stamp := func(t time.Time) string { return t.UTC().Format("2006-01-02T15:04:05.000Z") }
+	props := [][2]string{
+		{"id", id},
+		{"created_time", stamp(created)},
+		{"updated_time", stamp(updated)},
+		{"user_created_time", stamp(created)},
+		{"user_updated_time", stamp(updated)},
+		{"encryption_cipher_text", ""},
+		{"encryption_applied", "0"},
+	}
+	return append(props, more...)
+}
+
+// joplinID is a stable 32 hex digit id for the item of kind named name
+func joplinID(kind, name string) string {
+	sum := sha256.Sum256([]byte(kind + "\x00" + name))
+	return hex.EncodeToString(sum[:16])
+}
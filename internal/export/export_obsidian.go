@@ -0,0 +1,73 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Obsidian writes entries into dir as an Obsidian vault: one note per entry
+// in its folder, named after its title so [[wikilinks]] find it, with the
+// journal's front matter (tags, dates and id) as its properties. The files
+// the notes link to are copied from attachments, the journal's data folder,
+// to the same place in the vault. Nothing in dir is overwritten.
+func Obsidian(dir string, s storage.Store, entries []storage.Entry, attachments string) error {
+	atts, err := loadAttachments(attachments)
+	if err != nil {
+		return err
+	}
+	// an empty settings folder is enough for Obsidian, and for the importer,
+	// to tell a vault
+	if err := os.MkdirAll(filepath.Join(dir, ".obsidian"), 0o755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		full, err := s.Get(e.ID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Title, err)
+		}
+		name := path.Join(full.Folder(), noteName(full))
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		for i := 2; ; i++ {
+			if _, err := os.Lstat(p + ".md"); os.IsNotExist(err) {
+				break
+			}
+			p = filepath.Join(dir, filepath.FromSlash(fmt.Sprintf("%s %d", name, i)))
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p+".md", storage.MarshalEntry(full), 0o644); err != nil {
+			return err
+		}
+		for _, l := range atts.links(full.Content, full.Folder()) {
+			if err := atts.copyTo(l.path, filepath.Join(dir, filepath.FromSlash(l.path))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// noteName is the name, without .md, of e's note in a vault: its file name
+// when that came from elsewhere (a vault, say), else its title
+func noteName(e storage.Entry) string {
+	if _, ok := storage.FilenameTime(e.Filename); !ok {
+		return strings.TrimSuffix(path.Base(e.Filename), ".md")
+	}
+	// characters Obsidian doesn't allow in note names
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`\/:*?"<>|#^[]`, r) {
+			return '-'
+		}
+		return r
+	}, e.Title)
+	if name = strings.Trim(strings.TrimSpace(name), "."); name == "" {
+		name = "Untitled"
+	}
+	return name
+}
@@ -0,0 +1,465 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// SiteOptions configure a static site
+type SiteOptions struct {
+	Title       string // shown on every page; "Journal" when blank
+	Attachments string // the journal's data folder, where linked images are copied from
+}
+
+// siteMarker marks a folder as a generated site, which Site may replace
+const siteMarker = ".journal-site"
+
+// Private reports whether e is kept out of a site: a private or exclude
+// front matter field that isn't false, or the tag private
+func Private(e storage.Entry) bool {
+	for _, k := range []string{"private", "exclude"} {
+		if v, ok := e.Fields[k]; ok {
+			switch strings.ToLower(strings.TrimSpace(fmt.Sprint(v))) {
+			case "false", "no", "0", "off":
+			default:
+				return true
+			}
+		}
+	}
+	for _, t := range e.Tags {
+		if strings.EqualFold(t, "private") {
+			return true
+		}
+	}
+	return false
+}
+
+// siteNote is a published entry
+type siteNote struct {
+	storage.Entry
+	URL     string // page, from the site root
+	Body    template.HTML
+	Text    string    // the note as plain text on one line, for search
+	Summary string    // the start of Text, for lists
+	Tags    []siteTag // its tags, linked to their pages
+}
+
+// siteTag is a tag and its page
+type siteTag struct {
+	storage.TagNode
+	URL string
+}
+
+// Site writes entries, less private ones, to dir as a static site: a page per
+// note, an index newest first, a page per tag, an archive by month and a
+// search.json that the pages search in the browser. dir is replaced if it
+// holds an earlier site; a file or any other non-empty folder is left alone.
+// Returns the number of notes published.
+func Site(dir string, s storage.Store, entries []storage.Entry, opts SiteOptions) (int, error) {
+	if opts.Title == "" {
+		opts.Title = "Journal"
+	}
+	if fi, err := os.Lstat(dir); err == nil && !fi.IsDir() {
+		return 0, fmt.Errorf("%s is not a folder", dir)
+	} else if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if len(des) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, siteMarker)); err != nil {
+			return 0, fmt.Errorf("%s is not empty and holds no earlier site", dir)
+		}
+	}
+	atts, err := loadAttachments(opts.Attachments)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(filepath.Clean(dir)), 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(filepath.Clean(dir)), ".site-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmp)
+
+	b := &siteBuilder{dir: tmp, title: opts.Title}
+	var notes []*siteNote
+	taken := map[string]bool{"tags/index.html": true}
+	for _, e := range entries {
+		full, err := s.Get(e.ID)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", e.Title, err)
+		}
+		if Private(full) {
+			continue
+		}
+		n := &siteNote{Entry: full, URL: uniqueURL("notes/"+strings.TrimSuffix(path.Base(full.Filename), ".md"), taken)}
+		src := body(full)
+		n.Text = strings.Join(strings.Fields(PlainText(src)), " ")
+		n.Summary = n.Text
+		if r := []rune(n.Text); len(r) > 200 {
+			n.Summary = strings.TrimSpace(string(r[:200])) + "…"
+		}
+		for _, l := range atts.links(src, full.Folder()) {
+			if err := atts.copyTo(l.path, filepath.Join(tmp, "files", filepath.FromSlash(l.path))); err != nil {
+				return 0, err
+			}
+			href := "../files/" + escapePath(l.path)
+			if l.wiki {
+				src = strings.ReplaceAll(src, "![["+l.target+"]]", "![]("+href+")")
+				src = strings.ReplaceAll(src, "[["+l.target+"]]", "["+path.Base(l.target)+"]("+href+")")
+			} else {
+				src = strings.ReplaceAll(src, "("+l.target+")", "("+href+")")
+			}
+		}
+		if n.Body, err = RenderMarkdown(src); err != nil {
+			return 0, fmt.Errorf("%s: %w", full.Title, err)
+		}
+		notes = append(notes, n)
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Created.After(notes[j].Created) })
+
+	// tag pages, named after the tags
+	ents := make([]storage.Entry, len(notes))
+	for i, n := range notes {
+		ents[i] = n.Entry
+	}
+	var tags []siteTag
+	byTag := map[string]siteTag{}
+	for _, node := range storage.TagTree(ents) {
+		st := siteTag{node, uniqueURL(tagPage(node.Path), taken)}
+		tags = append(tags, st)
+		byTag[strings.ToLower(node.Path)] = st
+	}
+	for _, n := range notes {
+		for _, t := range n.Entry.Tags {
+			if st, ok := byTag[strings.ToLower(storage.CleanTag(t))]; ok {
+				n.Tags = append(n.Tags, st)
+			}
+		}
+	}
+
+	for i, n := range notes {
+		var prev, next *siteNote
+		if i > 0 {
+			next = notes[i-1]
+		}
+		if i < len(notes)-1 {
+			prev = notes[i+1]
+		}
+		err := b.page(n.URL, n.Title, "note", struct {
+			Note       *siteNote
+			Edited     bool
+			Prev, Next *siteNote
+		}{n, !n.Updated.Equal(n.Created), prev, next})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := b.page("index.html", "", "list", []siteSection{{Items: notes}}); err != nil {
+		return 0, err
+	}
+
+	// archive by month, newest first
+	var months []siteSection
+	for _, n := range notes {
+		id := n.Created.Format("2006-01")
+		if len(months) == 0 || months[len(months)-1].ID != id {
+			months = append(months, siteSection{ID: id, Heading: n.Created.Format("January 2006")})
+		}
+		months[len(months)-1].Items = append(months[len(months)-1].Items, n)
+	}
+	if err := b.page("archive.html", "Archive", "archive", months); err != nil {
+		return 0, err
+	}
+
+	// nested tags list the notes below them too
+	for _, st := range tags {
+		var tagged []*siteNote
+		for _, n := range notes {
+			for _, t := range n.Entry.Tags {
+				if storage.TagHas(t, st.Path) {
+					tagged = append(tagged, n)
+					break
+				}
+			}
+		}
+		if err := b.page(st.URL, "#"+st.Path, "list", []siteSection{{Items: tagged}}); err != nil {
+			return 0, err
+		}
+	}
+	if err := b.page("tags/index.html", "Tags", "tags", tags); err != nil {
+		return 0, err
+	}
+
+	if err := b.search(notes); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "style.css"), []byte(Stylesheet+siteStyle), 0o644); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, siteMarker), nil, 0o644); err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return 0, err
+	}
+	return len(notes), nil
+}
+
+// siteSection is a run of notes under a heading
+type siteSection struct {
+	ID      string
+	Heading string
+	Items   []*siteNote
+}
+
+// siteBuilder writes the pages of a site
+type siteBuilder struct {
+	dir   string
+	title string
+}
+
+// page renders the content template named tmpl with data into the layout,
+// at url below the site root
+func (b *siteBuilder) page(url, title, tmpl string, data any) error {
+	root := strings.Repeat("../", strings.Count(url, "/"))
+	var content bytes.Buffer
+	if err := siteTmpl.ExecuteTemplate(&content, tmpl, struct {
+		Root, Title string
+		Data        any
+	}{root, title, data}); err != nil {
+		return err
+	}
+	p := filepath.Join(b.dir, filepath.FromSlash(url))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	err = siteTmpl.ExecuteTemplate(f, "layout", struct {
+		Root, Site, Title string
+		Content           template.HTML
+	}{root, b.title, title, template.HTML(content.String())})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// siteDoc is a note in search.json
+type siteDoc struct {
+	Title string   `json:"title"`
+	URL   string   `json:"url"`
+	Date  string   `json:"date"`
+	Tags  []string `json:"tags"`
+	Text  string   `json:"text"`
+}
+
+// search writes search.json: every note's title, tags and plain text
+func (b *siteBuilder) search(notes []*siteNote) error {
+	docs := make([]siteDoc, len(notes))
+	for i, n := range notes {
+		tags := n.Entry.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		docs[i] = siteDoc{
+			Title: n.Title,
+			URL:   n.URL,
+			Date:  n.Created.Format("2006-01-02"),
+			Tags:  tags,
+			Text:  n.Text,
+		}
+	}
+	data, err := json.Marshal(docs)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(b.dir, "search.json"), data, 0o644)
+}
+
+// uniqueURL is base.html, or base-2.html and so on when that is taken
+func uniqueURL(base string, taken map[string]bool) string {
+	name := base + ".html"
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s-%d.html", base, i)
+	}
+	taken[name] = true
+	return name
+}
+
+// tagPage is the page of a tag without .html; the slashes of nested tags
+// become dashes
+func tagPage(tag string) string {
+	return "tags/" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-' || r == '_' {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, tag)
+}
+
+// escapePath escapes the parts of a slash path for use in a link
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// siteStyle adds the site's navigation, lists and search to Stylesheet
+const siteStyle = `
+header.site { border-bottom: 1px solid rgba(127, 127, 127, .25); }
+header.site nav { max-width: 46rem; margin: 0 auto; padding: .75rem 1.25rem; display: flex; gap: 1rem; align-items: center; flex-wrap: wrap; }
+header.site .home { font-weight: bold; margin-right: auto; }
+#q { font: inherit; padding: .2rem .5rem; border-radius: .4rem; border: 1px solid rgba(127, 127, 127, .4); background: transparent; color: inherit; }
+#results { max-width: 46rem; margin: 0 auto; padding: 0 1.25rem 1rem 2.5rem; }
+ul.notes { list-style: none; padding: 0; }
+ul.notes li { margin: 0 0 1.25rem; }
+ul.notes li > a { font-weight: 600; }
+ul.notes p { margin: .25rem 0 0; color: var(--muted); }
+time, .date, .count { color: var(--muted); font-size: .9rem; }
+ul.tags { list-style: none; padding: 0; }
+.months a { margin-right: .75rem; }
+nav.pager { display: flex; justify-content: space-between; gap: 1rem; margin-top: 3rem; }
+nav.pager .next { margin-left: auto; text-align: right; }
+`
+
+var siteTmpl = template.Must(template.New("site").Funcs(template.FuncMap{
+	// section hands the notes of a section, and the way to the site root,
+	// to the items template
+	"section": func(root string, sec siteSection) any {
+		return struct {
+			Root  string
+			Items []*siteNote
+		}{root, sec.Items}
+	},
+}).Parse(`
+{{- define "layout" -}}
+<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Title}}{{.Title}} · {{end}}{{.Site}}</title>
+<link rel="stylesheet" href="{{.Root}}style.css">
+</head>
+<body>
+<header class="site">
+<nav><a class="home" href="{{.Root}}index.html">{{.Site}}</a> <a href="{{.Root}}archive.html">Archive</a> <a href="{{.Root}}tags/index.html">Tags</a>
+<input id="q" type="search" placeholder="Search…" autocomplete="off" aria-label="Search" data-root="{{.Root}}"></nav>
+<ul id="results" hidden></ul>
+</header>
+<main>
+{{.Content}}
+</main>
+<script>
+(function () {
+  var q = document.getElementById("q"), out = document.getElementById("results"), docs;
+  function show() {
+    var words = q.value.toLowerCase().split(/\s+/).filter(Boolean);
+    out.innerHTML = "";
+    out.hidden = !words.length;
+    if (!words.length) return;
+    var hits = docs.filter(function (d) {
+      var text = (d.title + " " + d.tags.join(" ") + " " + d.text).toLowerCase();
+      return words.every(function (w) { return text.indexOf(w) >= 0; });
+    });
+    hits.slice(0, 20).forEach(function (d) {
+      var li = document.createElement("li"), a = document.createElement("a"), date = document.createElement("span");
+      a.href = q.dataset.root + d.url;
+      a.textContent = d.title;
+      date.className = "date";
+      date.textContent = " " + d.date;
+      li.append(a, date);
+      out.append(li);
+    });
+    if (!hits.length) out.textContent = "No matches.";
+  }
+  q.addEventListener("input", function () {
+    if (docs) return show();
+    fetch(q.dataset.root + "search.json").then(function (r) { return r.json(); }).then(function (d) { docs = d; show(); });
+  });
+})();
+</script>
+</body>
+</html>
+{{end}}
+
+{{- define "items"}}
+<ul class="notes">
+{{- range .Items}}
+<li><a href="{{$.Root}}{{.URL}}">{{.Title}}</a> <time datetime="{{.Created.Format "2006-01-02"}}">{{.Created.Format "2 Jan 2006"}}</time>
+{{- range .Tags}} <a class="tag" href="{{$.Root}}{{.URL}}">#{{.Path}}</a>{{end}}
+{{- with .Summary}}<p>{{.}}</p>{{end}}</li>
+{{- else}}
+<li>No notes.</li>
+{{- end}}
+</ul>
+{{- end}}
+
+{{- define "list"}}
+{{- if .Title}}<h1>{{.Title}}</h1>{{end}}
+{{- range .Data}}{{template "items" (section $.Root .)}}{{end}}
+{{- end}}
+
+{{- define "archive"}}
+<h1>{{.Title}}</h1>
+<p class="months">{{range .Data}}<a href="#{{.ID}}">{{.Heading}}</a> {{end}}</p>
+{{- range .Data}}
+<h2 id="{{.ID}}">{{.Heading}} <span class="count">{{len .Items}}</span></h2>
+{{- template "items" (section $.Root .)}}
+{{- else}}
+<p>No notes.</p>
+{{- end}}
+{{- end}}
+
+{{- define "tags"}}
+<h1>{{.Title}}</h1>
+<ul class="tags">
+{{- range .Data}}
+<li style="padding-left: {{.Depth}}rem"><a class="tag" href="{{$.Root}}{{.URL}}">#{{.Path}}</a> <span class="count">{{.Count}}</span></li>
+{{- else}}
+<li>No tags.</li>
+{{- end}}
+</ul>
+{{- end}}
+
+{{- define "note"}}
+{{- with .Data}}
+<article>
+<h1>{{.Note.Title}}</h1>
+<p class="meta"><time datetime="{{.Note.Created.Format "2006-01-02T15:04:05Z07:00"}}">{{.Note.Created.Format "2 Jan 2006 15:04"}}</time>
+{{- if .Edited}} · updated {{.Note.Updated.Format "2 Jan 2006 15:04"}}{{end}}
+{{- with .Note.Folder}} · {{.}}{{end}}
+{{- range .Note.Tags}} <a class="tag" href="{{$.Root}}{{.URL}}">#{{.Path}}</a>{{end}}</p>
+{{.Note.Body}}
+</article>
+<nav class="pager">
+{{- with .Prev}}<a href="{{$.Root}}{{.URL}}">← {{.Title}}</a>{{end}}
+{{- with .Next}}<a class="next" href="{{$.Root}}{{.URL}}">{{.Title}} →</a>{{end}}</nav>
+{{- end}}
+{{- end}}
+`))
@@ -0,0 +1,262 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+func sample() storage.Entry {
+	created := time.Date(2025, 7, 1, 9, 30, 0, 0, time.UTC)
+	return storage.Entry{
+		ID:       "01J1Z5Y7Q8R9S0T1V2W3X4Y5Z6",
+		Title:    "Q3 retro",
+		Filename: "work/20250701-093000-q3-retro.md",
+		Created:  created,
+		Updated:  created.Add(time.Hour),
+		Tags:     []string{"work/retro", "q3"},
+		Content: "# Q3 retro\n\nWent **well**: the [launch](https://example.com).\n\n" +
+			"- shipped `v2`\n* <script>alert(1)</script>\n\n```\nkeep *this*\n```\n",
+	}
+}
+
+func TestFormats(t *testing.T) {
+	var names []string
+	for _, x := range Formats() {
+		names = append(names, x.Name()+x.Ext())
+		if got, ok := Lookup(strings.ToUpper(x.Name())); !ok || got != x {
+			t.Errorf("Lookup(%q) = %v, %v", x.Name(), got, ok)
+		}
+	}
+	if got := strings.Join(names, " "); got != "markdown.md html.html json.json text.txt" {
+		t.Errorf("formats = %s", got)
+	}
+	if _, ok := Lookup("pdf"); ok {
+		t.Error("Lookup(pdf) succeeded")
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	e := sample()
+	dir := t.TempDir()
+	read := func(x Exporter) string {
+		t.Helper()
+		p, err := File(x, e, dir)
+		if err != nil {
+			t.Fatalf("%s: %v", x.Name(), err)
+		}
+		if filepath.Base(p) != "20250701-093000-q3-retro"+x.Ext() {
+			t.Errorf("%s: exported to %s", x.Name(), p)
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// markdown keeps the front matter, so it reads back as the same entry
+	back, err := storage.UnmarshalEntry([]byte(read(Markdown{})))
+	if err != nil || back.ID != e.ID || !back.Created.Equal(e.Created) || strings.Join(back.Tags, ",") != "work/retro,q3" {
+		t.Errorf("markdown read back as %+v (%v)", back, err)
+	}
+
+	page := read(HTML{})
+	for _, want := range []string{"<!DOCTYPE html>", "<title>Q3 retro</title>", "<style>", "<strong>well</strong>",
+		`<a href="https://example.com">launch</a>`, `<span class="tag">#work/retro</span>`, "updated 1 Jul 2025 10:30"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("html lacks %q", want)
+		}
+	}
+	if strings.Contains(page, "<script>") || strings.Count(page, "<h1>") != 1 {
+		t.Errorf("html has raw script or a doubled title:\n%s", page)
+	}
+
+	var rec Record
+	if err := json.Unmarshal([]byte(read(JSON{})), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID != e.ID || rec.Folder != "work" || rec.Content != e.Content || len(rec.Tags) != 2 {
+		t.Errorf("json record = %+v", rec)
+	}
+
+	text := read(Text{})
+	for _, want := range []string{"Q3 retro\n========\n", "Tags: work/retro, q3", "Went well: the launch (https://example.com).",
+		"- shipped v2", "keep *this*"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text lacks %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestFileDoesNotOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	first, err := File(Text{}, sample(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := File(Text{}, sample(), dir)
+	if err != nil || second == first || filepath.Base(second) != "20250701-093000-q3-retro-1.txt" {
+		t.Errorf("second export = %s, %v", second, err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	s := storage.NewMemStore()
+	retro, _ := storage.SaveEntryIn(s, "work", "Q3 retro", "went well", []string{"retro", "q3"})
+	storage.SaveEntry(s, "Groceries", "milk", nil)
+	dir := t.TempDir()
+	p, err := ArchiveFile(dir, s, []storage.Entry{retro}, Markdown{}, "tag:retro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(filepath.Base(p), "journal-") || filepath.Ext(p) != ".zip" {
+		t.Errorf("archive at %s", p)
+	}
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+	if len(files) != 2 || files[retro.Filename] == nil || files[ManifestFile] == nil {
+		t.Fatalf("archive holds %v", files)
+	}
+	rc, _ := files[ManifestFile].Open()
+	var man Manifest
+	if err := json.NewDecoder(rc).Decode(&man); err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if man.Scope != "tag:retro" || man.Format != "markdown" || len(man.Entries) != 1 {
+		t.Fatalf("manifest = %+v", man)
+	}
+	got := man.Entries[0]
+	if got.Path != retro.Filename || got.ID != retro.ID || got.Title != "Q3 retro" || got.Folder != "work" ||
+		strings.Join(got.Tags, ",") != "retro,q3" || !got.Created.Equal(retro.Created) || got.Content != "" {
+		t.Errorf("manifest entry = %+v", got)
+	}
+
+	// other formats change the extensions, not the layout
+	p, err = ArchiveFile(dir, s, []storage.Entry{retro}, HTML{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr2, _ := zip.OpenReader(p)
+	defer zr2.Close()
+	if name := zr2.File[0].Name; name != strings.TrimSuffix(retro.Filename, ".md")+".html" {
+		t.Errorf("html archive holds %s", name)
+	}
+
+	// a root entry called manifest doesn't take the manifest's place
+	man2, _ := s.Put(storage.Entry{Title: "Manifest", Filename: "manifest.md", Content: "# Manifest\n\nlist"})
+	p, err = ArchiveFile(dir, s, []storage.Entry{man2}, JSON{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr3, _ := zip.OpenReader(p)
+	defer zr3.Close()
+	if len(zr3.File) != 2 || zr3.File[0].Name != "manifest-2.json" || zr3.File[1].Name != ManifestFile {
+		t.Errorf("json archive holds %s and %s", zr3.File[0].Name, zr3.File[len(zr3.File)-1].Name)
+	}
+}
+
+func TestSite(t *testing.T) {
+	data := t.TempDir()
+	s := storage.NewFSStore(data)
+	put := func(folder, title, content string, created time.Time, tags []string, fields map[string]any) {
+		t.Helper()
+		_, err := s.Put(storage.Entry{Title: title, Filename: storage.EntryPath(folder, title, created), Content: "# " + title + "\n\n" + content,
+			Created: created, Tags: tags, Fields: fields})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("team", "Retro", "Went **well**. ![chart](img/chart.png)", time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC), []string{"work/retro"}, nil)
+	put("team", "Kickoff", "Plans.", time.Date(2025, 6, 3, 9, 0, 0, 0, time.UTC), []string{"work"}, nil)
+	put("team", "Salaries", "secret", time.Date(2025, 7, 2, 9, 0, 0, 0, time.UTC), []string{"work"}, map[string]any{"private": "true"})
+	put("", "Diary", "dear diary", time.Date(2025, 7, 3, 9, 0, 0, 0, time.UTC), []string{"Private"}, nil)
+	put("", "Shared", "not private", time.Date(2025, 5, 3, 9, 0, 0, 0, time.UTC), nil, map[string]any{"exclude": "no"})
+	if err := os.MkdirAll(filepath.Join(data, "team", "img"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(data, "team", "img", "chart.png"), []byte("png"), 0o644)
+	ents, _ := s.List()
+
+	dir := filepath.Join(t.TempDir(), "site")
+	n, err := Site(dir, s, ents, SiteOptions{Title: "Team", Attachments: data})
+	if err != nil || n != 3 {
+		t.Fatalf("Site = %d, %v", n, err)
+	}
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	index := read("index.html")
+	if r, k := strings.Index(index, ">Retro<"), strings.Index(index, ">Kickoff<"); r < 0 || k < r || strings.Contains(index, "Salaries") ||
+		strings.Contains(index, "Diary") || !strings.Contains(index, "<title>Team</title>") {
+		t.Errorf("index:\n%s", index)
+	}
+	retro := read("notes/20250701-090000-retro.html")
+	for _, want := range []string{"<strong>well</strong>", `src="../files/team/img/chart.png"`, `href="../tags/work-retro.html"`, `href="../style.css"`} {
+		if !strings.Contains(retro, want) {
+			t.Errorf("retro page lacks %s", want)
+		}
+	}
+	if read("files/team/img/chart.png") != "png" {
+		t.Error("image not copied")
+	}
+	if work := read("tags/work.html"); !strings.Contains(work, "Retro") || !strings.Contains(work, "Kickoff") || strings.Contains(work, "Salaries") {
+		t.Errorf("work tag page:\n%s", work)
+	}
+	if archive := read("archive.html"); !strings.Contains(archive, `id="2025-07"`) || !strings.Contains(archive, "June 2025") {
+		t.Errorf("archive:\n%s", archive)
+	}
+	var docs []struct {
+		Title, URL string
+		Tags       []string
+	}
+	if err := json.Unmarshal([]byte(read("search.json")), &docs); err != nil || len(docs) != 3 || docs[0].Title != "Retro" || docs[2].Tags == nil {
+		t.Errorf("search.json = %+v, %v", docs, err)
+	}
+
+	// a new run replaces the site, but never a folder that isn't one
+	var kickoff []storage.Entry
+	for _, e := range ents {
+		if e.Title == "Kickoff" {
+			kickoff = append(kickoff, e)
+		}
+	}
+	if n, err := Site(dir, s, kickoff, SiteOptions{}); err != nil || n != 1 {
+		t.Errorf("second run = %d, %v", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes", "20250701-090000-retro.html")); err == nil {
+		t.Error("second run left the old pages")
+	}
+	other := t.TempDir()
+	os.WriteFile(filepath.Join(other, "keep.txt"), nil, 0o644)
+	if _, err := Site(other, s, ents, SiteOptions{}); err == nil {
+		t.Error("Site wrote over a folder that isn't a site")
+	}
+	file := filepath.Join(t.TempDir(), "precious")
+	os.WriteFile(file, []byte("precious"), 0o644)
+	if _, err := Site(file, s, ents, SiteOptions{}); err == nil {
+		t.Error("Site wrote over a file")
+	}
+	if b, err := os.ReadFile(file); err != nil || string(b) != "precious" {
+		t.Errorf("file lost: %q, %v", b, err)
+	}
+}
@@ -0,0 +1,115 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// ManifestFile is the name of the manifest at the root of an archive
+const ManifestFile = "manifest.json"
+
+// Manifest describes the entries in an archive, so their ids, tags and dates
+// survive whatever the files went through
+type Manifest struct {
+	Exported time.Time       `json:"exported"`
+	Scope    string          `json:"scope,omitempty"` // query the entries were picked by, if any
+	Format   string          `json:"format"`
+	Entries  []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry is an entry's metadata and where its file is in the archive
+type ManifestEntry struct {
+	Path string `json:"path"`
+	Record
+}
+
+// Archive writes entries to w as a zip: each entry read in full from s and
+// exported with x under its folder, plus a manifest.json describing them.
+// scope says how the entries were picked ("" for the whole journal).
+func Archive(w io.Writer, s storage.Store, entries []storage.Entry, x Exporter, scope string) error {
+	zw := zip.NewWriter(w)
+	man := Manifest{Exported: time.Now().UTC(), Scope: scope, Format: x.Name(), Entries: []ManifestEntry{}}
+	// entry file names are unique within a folder, but not once the
+	// extension changes, and a root entry could be called manifest
+	taken := map[string]bool{ManifestFile: true}
+	for _, e := range entries {
+		full, err := s.Get(e.ID)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Title, err)
+		}
+		name := uniquePath(path.Join(full.Folder(), FileName(x, full)), taken)
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: full.Updated})
+		if err != nil {
+			return err
+		}
+		if err := x.Export(f, full); err != nil {
+			return fmt.Errorf("%s: %w", full.Title, err)
+		}
+		rec := NewRecord(full)
+		rec.Content = ""
+		man.Entries = append(man.Entries, ManifestEntry{Path: name, Record: rec})
+	}
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: ManifestFile, Method: zip.Deflate, Modified: man.Exported})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(man); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// uniquePath is name, or name-2 and so on before its extension when taken
+func uniquePath(name string, taken map[string]bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	taken[name] = true
+	return name
+}
+
+// ArchiveFile writes the archive of entries into dir as
+// journal-<timestamp>.zip and returns its path
+func ArchiveFile(dir string, s storage.Store, entries []storage.Entry, x Exporter, scope string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating exports dir: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".journal-*.zip")
+	if err != nil {
+		return "", err
+	}
+	err = Archive(f, s, entries, x, scope)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	name := "journal-" + time.Now().Format("20060102-150405")
+	p := filepath.Join(dir, name+".zip")
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(p); os.IsNotExist(err) {
+			break
+		}
+		p = filepath.Join(dir, fmt.Sprintf("%s-%d.zip", name, i))
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return p, nil
+}
@@ -0,0 +1,325 @@
+// Package importer brings notes from other tools into the journal: folders
+// of Markdown files, jrnl text files, Day One JSON exports and the journal's
+// own export zips. Notes keep their dates and tags, and notes whose content
+// the journal already has are left out.
+package importer
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Note is a note read from another tool, ready to go into the journal
+type Note struct {
+	Source string        // file the note came from, with #N when it holds several
+	Entry  storage.Entry // Filename is the path it asks for, "" to name it after the title
+	Err    error         // why the note couldn't be read; Import skips it
+}
+
+// Importer reads notes in one format
+type Importer interface {
+	// Name is the format's name, e.g. "jrnl"
+	Name() string
+	// Read reads every note at p, a file or a folder depending on the format
+	Read(p string) ([]Note, error)
+}
+
+// Attacher is implemented by importers whose sources keep files other than
+// notes, such as images, that notes link to
+type Attacher interface {
+	// Attachments lists the files at p that aren't notes
+	Attachments(p string) ([]Attachment, error)
+}
+
+// Attachment is a file that notes link to
+type Attachment struct {
+	Source string // where it is, on disk
+	Path   string // where notes expect it, relative to the import root
+}
+
+var formats = []Importer{Markdown{}, Obsidian{}, Joplin{}, Jrnl{}, DayOne{}, Archive{}}
+
+// Formats lists the import formats
+func Formats() []Importer {
+	return append([]Importer(nil), formats...)
+}
+
+// Lookup finds an import format by name, ignoring case
+func Lookup(name string) (Importer, bool) {
+	for _, x := range formats {
+		if strings.EqualFold(x.Name(), name) {
+			return x, true
+		}
+	}
+	return nil, false
+}
+
+// Names lists the format names, comma separated
+func Names() string {
+	names := make([]string, len(formats))
+	for i, x := range formats {
+		names[i] = x.Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+// Detect guesses the format of p: a folder is an Obsidian vault (with its
+// .obsidian settings), a Joplin RAW export or else Markdown files, a .txt file
+// is jrnl, a .json file is Day One, and a zip is either the journal's own
+// export or Day One's, depending on what is in it
+func Detect(p string) (Importer, error) {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		if _, err := os.Stat(filepath.Join(p, ".obsidian")); err == nil {
+			return Obsidian{}, nil
+		}
+		if isJoplinDir(p) {
+			return Joplin{}, nil
+		}
+		return Markdown{}, nil
+	}
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".txt":
+		return Jrnl{}, nil
+	case ".json":
+		return DayOne{}, nil
+	case ".zip":
+		zr, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.Name == "manifest.json" || isMarkdown(f.Name) {
+				return Archive{}, nil
+			}
+		}
+		return DayOne{}, nil
+	}
+	return nil, fmt.Errorf("can't tell the format of %s (pick one of %s)", p, Names())
+}
+
+// Options control an import
+type Options struct {
+	Folder string // folder the notes go into, keeping any folders of their own below it
+	DryRun bool   // only report what would be imported
+}
+
+// Status is what became of a note
+type Status int
+
+const (
+	Imported  Status = iota
+	Duplicate        // the journal, or an earlier note, has the same content
+	Skipped          // the note couldn't be read or written
+)
+
+func (st Status) String() string {
+	switch st {
+	case Imported:
+		return "imported"
+	case Duplicate:
+		return "duplicate"
+	default:
+		return "skipped"
+	}
+}
+
+// Result is what became of one note
+type Result struct {
+	Source   string
+	Title    string
+	Status   Status
+	Filename string // where the note went, or the entry it duplicates
+	Reason   string // why it was skipped
+}
+
+// Report lists what an import did, or would do on a dry run
+type Report struct {
+	DryRun  bool
+	Results []Result
+}
+
+// Count returns how many notes ended up with status st
+func (r Report) Count(st Status) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == st {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary is a one line account of the import
+func (r Report) Summary() string {
+	verb := "imported"
+	if r.DryRun {
+		verb = "would import"
+	}
+	return fmt.Sprintf("%s %d, %d duplicates, %d skipped", verb, r.Count(Imported), r.Count(Duplicate), r.Count(Skipped))
+}
+
+// Import writes notes into s, leaving out those whose content s already has
+// (or an earlier note had) and those that couldn't be read. Entries keep the
+// ids they came with unless s already uses them.
+func Import(s storage.Store, notes []Note, opts Options) (Report, error) {
+	rep := Report{DryRun: opts.DryRun}
+	ents, err := s.List()
+	if err != nil {
+		return rep, err
+	}
+	have := map[string]string{} // content hash -> filename
+	ids := map[string]bool{}
+	names := map[string]bool{}
+	for _, e := range ents {
+		full, err := s.Get(e.ID)
+		if err != nil {
+			return rep, err
+		}
+		have[ContentHash(full.Content)] = full.Filename
+		ids[full.ID] = true
+		names[full.Filename] = true
+	}
+	folder := storage.CleanFolder(opts.Folder)
+	for _, n := range notes {
+		e := n.Entry
+		res := Result{Source: n.Source, Title: e.Title}
+		if n.Err != nil {
+			res.Status, res.Reason = Skipped, n.Err.Error()
+			rep.Results = append(rep.Results, res)
+			continue
+		}
+		sum := ContentHash(e.Content)
+		if name, ok := have[sum]; ok {
+			res.Status, res.Filename = Duplicate, name
+			rep.Results = append(rep.Results, res)
+			continue
+		}
+		if ids[e.ID] {
+			e.ID = ""
+		}
+		e.Filename = target(folder, e)
+		if opts.DryRun {
+			// the name Put would pick, given what this run took already
+			e.Filename = storage.UniqueFilename(e.Filename, func(name string) bool { return names[name] })
+		} else {
+			saved, err := s.Put(e)
+			if err != nil {
+				res.Status, res.Reason = Skipped, err.Error()
+				rep.Results = append(rep.Results, res)
+				continue
+			}
+			e = saved
+		}
+		have[sum] = e.Filename
+		names[e.Filename] = true
+		if e.ID != "" {
+			ids[e.ID] = true
+		}
+		res.Status, res.Filename = Imported, e.Filename
+		rep.Results = append(rep.Results, res)
+	}
+	return rep, nil
+}
+
+// CopyAttachments copies atts into dir, below opts.Folder and keeping their
+// paths, and returns how many it copied, or would copy on a dry run. Files
+// already there are left alone.
+func CopyAttachments(atts []Attachment, dir string, opts Options) (int, error) {
+	n := 0
+	for _, a := range atts {
+		rel := path.Join(storage.CleanFolder(opts.Folder), a.Path)
+		if !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return n, fmt.Errorf("attachment %s is outside the journal", a.Path)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		n++
+		if opts.DryRun {
+			continue
+		}
+		if err := copyFile(a.Source, dst); err != nil {
+			return n - 1, err
+		}
+	}
+	return n, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// ContentHash identifies a note by its content, ignoring line endings and
+// surrounding blank space
+func ContentHash(content string) string {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// target is the path e gets below folder: the one it asks for as a .md file,
+// else one named after its title and creation time
+func target(folder string, e storage.Entry) string {
+	if e.Filename == "" {
+		return storage.EntryPath(folder, e.Title, e.Created.Local())
+	}
+	name := strings.TrimSuffix(e.Filename, path.Ext(e.Filename)) + ".md"
+	return path.Join(folder, name)
+}
+
+// isMarkdown reports whether name is a markdown file
+func isMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// withTitle makes text, whose first line is the title, into entry content
+// starting with a "# title" heading, returning the title too
+func withTitle(text string) (title, content string) {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	first, rest, _ := strings.Cut(text, "\n")
+	title = strings.TrimSpace(strings.TrimLeft(first, "#"))
+	if title == "" {
+		return "", text + "\n"
+	}
+	content = "# " + title + "\n"
+	if rest = strings.TrimSpace(rest); rest != "" {
+		content += "\n" + rest + "\n"
+	}
+	return title, content
+}
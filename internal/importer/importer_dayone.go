@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DayOne reads a Day One JSON export: the .json file of a journal, or the
+// zip Day One exports with one per journal. The first line of an entry is
+// its title; starred entries get a starred field.
+type DayOne struct{}
+
+func (DayOne) Name() string { return "dayone" }
+
+// dayOneExport is the part of Day One's export format the journal uses
+type dayOneExport struct {
+	Entries []struct {
+		UUID         string    `json:"uuid"`
+		CreationDate time.Time `json:"creationDate"`
+		ModifiedDate time.Time `json:"modifiedDate"`
+		Text         string    `json:"text"`
+		Tags         []string  `json:"tags"`
+		Starred      bool      `json:"starred"`
+	} `json:"entries"`
+}
+
+func (DayOne) Read(p string) ([]Note, error) {
+	if strings.EqualFold(filepath.Ext(p), ".zip") {
+		zr, err := zip.OpenReader(p)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		var notes []Note
+		for _, f := range zr.File {
+			// journals are at the top, photos and such in folders
+			if strings.Contains(f.Name, "/") || !strings.EqualFold(path.Ext(f.Name), ".json") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			more, err := readDayOne(rc, f.Name)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			notes = append(notes, more...)
+		}
+		if notes == nil {
+			return nil, fmt.Errorf("%s: no Day One journals in it", filepath.Base(p))
+		}
+		return notes, nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readDayOne(f, filepath.Base(p))
+}
+
+// readDayOne reads the entries of the Day One journal in r, named src
+func readDayOne(r io.Reader, src string) ([]Note, error) {
+	var exp dayOneExport
+	if err := json.NewDecoder(r).Decode(&exp); err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	notes := make([]Note, 0, len(exp.Entries))
+	for i, de := range exp.Entries {
+		n := Note{Source: fmt.Sprintf("%s#%d", src, i+1)}
+		title, content := withTitle(de.Text)
+		if title == "" {
+			n.Err = fmt.Errorf("empty entry")
+		}
+		n.Entry.Title, n.Entry.Content = title, content
+		n.Entry.Created, n.Entry.Updated = de.CreationDate, de.ModifiedDate
+		if n.Entry.Updated.Before(n.Entry.Created) {
+			n.Entry.Updated = n.Entry.Created
+		}
+		n.Entry.Tags = de.Tags
+		if n.Entry.Tags == nil {
+			n.Entry.Tags = []string{}
+		}
+		if de.Starred {
+			n.Entry.Fields = map[string]any{"starred": "true"}
+		}
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
@@ -0,0 +1,237 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Joplin reads a Joplin RAW export: a folder of items, one per file, each
+// the item's title and body followed by its properties. Notebooks become
+// folders and Joplin's tags the notes' tags. Resources are attachments in
+// _resources, and the :/id links to them are rewritten to relative paths.
+type Joplin struct{}
+
+func (Joplin) Name() string { return "joplin" }
+
+// joplin item types
+const (
+	joplinNote     = "1"
+	joplinFolder   = "2"
+	joplinResource = "4"
+	joplinTag      = "5"
+	joplinNoteTag  = "6"
+)
+
+// joplinItem is one file of a RAW export
+type joplinItem struct {
+	file  string
+	title string
+	body  string
+	props map[string]string
+}
+
+var joplinFile = regexp.MustCompile(`^[0-9a-f]{32}\.md$`)
+
+// isJoplinDir reports whether dir looks like a RAW export
+func isJoplinDir(dir string) bool {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, de := range ents {
+		if !de.IsDir() && joplinFile.MatchString(de.Name()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (Joplin) Read(dir string) ([]Note, error) {
+	items, err := readJoplin(dir)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]joplinItem{}
+	tagsOf := map[string][]string{}
+	for _, it := range items {
+		byID[it.props["id"]] = it
+	}
+	for _, it := range items {
+		if it.props["type_"] == joplinNoteTag {
+			if tag, ok := byID[it.props["tag_id"]]; ok {
+				tagsOf[it.props["note_id"]] = append(tagsOf[it.props["note_id"]], tag.title)
+			}
+		}
+	}
+	var notes []Note
+	for _, it := range items {
+		if it.props["type_"] != joplinNote {
+			continue
+		}
+		n := Note{Source: it.file}
+		if it.props["encryption_applied"] == "1" {
+			n.Err = fmt.Errorf("encrypted")
+			notes = append(notes, n)
+			continue
+		}
+		folder := joplinFolderPath(byID, it.props["parent_id"])
+		e := &n.Entry
+		if id, ok := storage.IDFromHex(it.props["id"]); ok {
+			e.ID = id
+		}
+		e.Title = strings.TrimSpace(it.title)
+		if e.Title == "" {
+			e.Title = "Untitled"
+		}
+		e.Content = "# " + e.Title + "\n"
+		if body := strings.TrimSpace(joplinLinks(it.body, byID, folder)); body != "" {
+			e.Content += "\n" + body + "\n"
+		}
+		e.Created = joplinTime(it.props, "user_created_time", "created_time")
+		e.Updated = joplinTime(it.props, "user_updated_time", "updated_time")
+		// Joplin's tags have no order
+		e.Tags = addTags(nil, tagsOf[it.props["id"]])
+		sort.Strings(e.Tags)
+		if e.Created.IsZero() {
+			e.Created = time.Now()
+		}
+		e.Filename = storage.EntryPath(folder, e.Title, e.Created.Local())
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
+
+// Attachments lists the export's resources, as they go in _resources
+func (Joplin) Attachments(dir string) ([]Attachment, error) {
+	items, err := readJoplin(dir)
+	if err != nil {
+		return nil, err
+	}
+	var atts []Attachment
+	for _, it := range items {
+		if it.props["type_"] != joplinResource {
+			continue
+		}
+		name := joplinResourceName(it)
+		src := filepath.Join(dir, "resources", name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		atts = append(atts, Attachment{Source: src, Path: path.Join("_resources", name)})
+	}
+	return atts, nil
+}
+
+// readJoplin reads every item of the RAW export in dir
+func readJoplin(dir string) ([]joplinItem, error) {
+	ents, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var items []joplinItem
+	for _, de := range ents {
+		if de.IsDir() || !joplinFile.MatchString(de.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, de.Name()))
+		if err != nil {
+			return nil, err
+		}
+		it := parseJoplin(string(data))
+		it.file = de.Name()
+		if it.props["id"] == "" {
+			it.props["id"] = strings.TrimSuffix(de.Name(), ".md")
+		}
+		items = append(items, it)
+	}
+	return items, nil
+}
+
+// parseJoplin splits an item into its title, body and the properties after
+// the last blank line
+func parseJoplin(raw string) joplinItem {
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(raw, "\r\n", "\n"), "\n"), "\n")
+	start := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) == "" {
+			start = i + 1
+			break
+		}
+	}
+	it := joplinItem{props: map[string]string{}}
+	for _, line := range lines[start:] {
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			it.props[k] = strings.TrimSpace(v)
+		}
+	}
+	if start > 0 {
+		text := strings.Join(lines[:start-1], "\n")
+		title, body, _ := strings.Cut(text, "\n")
+		it.title, it.body = title, strings.TrimLeft(body, "\n")
+	}
+	return it
+}
+
+// joplinFolderPath is the folder for the notebook id, following its parents
+func joplinFolderPath(byID map[string]joplinItem, id string) string {
+	var parts []string
+	seen := map[string]bool{}
+	for id != "" && !seen[id] {
+		seen[id] = true
+		it, ok := byID[id]
+		if !ok || it.props["type_"] != joplinFolder {
+			break
+		}
+		name := strings.Trim(strings.ReplaceAll(strings.TrimSpace(it.title), "/", "-"), ".")
+		if name == "" {
+			name = "Untitled"
+		}
+		parts = append([]string{name}, parts...)
+		id = it.props["parent_id"]
+	}
+	return storage.CleanFolder(strings.Join(parts, "/"))
+}
+
+var joplinLink = regexp.MustCompile(`\(:/([0-9a-f]{32})\)`)
+
+// joplinLinks rewrites links to resources to paths relative to a note in
+// folder; links to notes are left alone
+func joplinLinks(body string, byID map[string]joplinItem, folder string) string {
+	up := ""
+	if folder != "" {
+		up = strings.Repeat("../", strings.Count(folder, "/")+1)
+	}
+	return joplinLink.ReplaceAllStringFunc(body, func(m string) string {
+		it, ok := byID[joplinLink.FindStringSubmatch(m)[1]]
+		if !ok || it.props["type_"] != joplinResource {
+			return m
+		}
+		return "(" + up + "_resources/" + joplinResourceName(it) + ")"
+	})
+}
+
+// joplinResourceName is the file name of a resource in resources/
+func joplinResourceName(it joplinItem) string {
+	if ext := it.props["file_extension"]; ext != "" {
+		return it.props["id"] + "." + ext
+	}
+	return it.props["id"]
+}
+
+// joplinTime reads the first of keys that holds a time
+func joplinTime(props map[string]string, keys ...string) time.Time {
+	for _, k := range keys {
+		if t, err := time.Parse(time.RFC3339Nano, props[k]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Jrnl reads a jrnl plain text journal: entries start with a "[date time]"
+// line (brackets optional) after a blank line, the title is the first
+// sentence and @words are tags
+type Jrnl struct{}
+
+func (Jrnl) Name() string { return "jrnl" }
+
+var (
+	jrnlHeader = regexp.MustCompile(`^\[?(\d{4}-\d{2}-\d{2}[ T]\d{1,2}:\d{2}(?::\d{2})?(?: ?[AaPp][Mm])?)\]? ?(.*)$`)
+	jrnlTitle  = regexp.MustCompile(`^.*?[.?!]+(?:\s|$)`)
+	jrnlTag    = regexp.MustCompile(`(?:^|[\s(])@([\p{L}\p{N}_][\p{L}\p{N}_/-]*)`)
+)
+
+// jrnlLayouts are the time formats jrnl writes, depending on its timeformat
+var jrnlLayouts = []string{
+	"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02 03:04 PM", "2006-01-02 3:04 PM",
+	"2006-01-02 03:04PM", "2006-01-02 3:04PM", "2006-01-02T15:04", "2006-01-02T15:04:05",
+}
+
+func (Jrnl) Read(p string) ([]Note, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	src := filepath.Base(p)
+	var notes []Note
+	var cur *jrnlEntry
+	flush := func() {
+		if cur != nil {
+			notes = append(notes, cur.note(fmt.Sprintf("%s#%d", src, len(notes)+1)))
+		}
+	}
+	blank := true
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if m := jrnlHeader.FindStringSubmatch(line); m != nil && blank {
+			if t, ok := jrnlTime(strings.ToUpper(m[1])); ok {
+				flush()
+				cur = &jrnlEntry{when: t, head: m[2]}
+				blank = false
+				continue
+			}
+		}
+		blank = strings.TrimSpace(line) == ""
+		if cur != nil {
+			cur.body = append(cur.body, line)
+		}
+	}
+	flush()
+	return notes, nil
+}
+
+// jrnlEntry is an entry as it is being read
+type jrnlEntry struct {
+	when time.Time
+	head string // rest of the date line
+	body []string
+}
+
+func (j *jrnlEntry) note(source string) Note {
+	head := strings.TrimSpace(j.head)
+	title := head
+	rest := ""
+	if m := jrnlTitle.FindString(head); m != "" {
+		title, rest = strings.TrimSpace(m), strings.TrimSpace(head[len(m):])
+	}
+	text := title + "\n\n" + rest + "\n" + strings.Join(j.body, "\n")
+	title, content := withTitle(text)
+	n := Note{Source: source}
+	n.Entry.Title = title
+	n.Entry.Content = content
+	n.Entry.Created, n.Entry.Updated = j.when, j.when
+	n.Entry.Tags = []string{}
+	seen := map[string]bool{}
+	for _, m := range jrnlTag.FindAllStringSubmatch(content, -1) {
+		tag := strings.ToLower(strings.TrimRight(m[1], "/-"))
+		if !seen[tag] {
+			seen[tag] = true
+			n.Entry.Tags = append(n.Entry.Tags, tag)
+		}
+	}
+	if title == "" {
+		n.Err = fmt.Errorf("empty entry")
+	}
+	return n
+}
+
+// jrnlTime parses a jrnl entry date in local time
+func jrnlTime(s string) (time.Time, bool) {
+	for _, layout := range jrnlLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
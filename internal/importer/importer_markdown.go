@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Markdown reads a folder of Markdown files, keeping its layout as folders.
+// Front matter is optional: without it the title comes from the first heading
+// or the file name, and the dates from a timestamp prefix or the file's mtime.
+type Markdown struct{}
+
+func (Markdown) Name() string { return "markdown" }
+
+func (Markdown) Read(dir string) ([]Note, error) {
+	var notes []Note
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			// .obsidian, .git, the journal's own .trash and .history
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !isMarkdown(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			notes = append(notes, Note{Source: rel, Err: err})
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		notes = append(notes, Note{Source: rel, Entry: markdownEntry(rel, data, fi.ModTime())})
+		return nil
+	})
+	return notes, err
+}
+
+// markdownEntry makes the entry for the markdown file name, falling back to
+// mtime for dates the file doesn't have
+func markdownEntry(name string, data []byte, mtime time.Time) storage.Entry {
+	// as in the journal, a malformed header is kept as part of the body
+	e, _ := storage.UnmarshalEntry(data)
+	e.Filename = name
+	base := strings.TrimSuffix(path.Base(name), path.Ext(name))
+	if e.Title == "" {
+		e.Title = heading(e.Content)
+	}
+	if e.Title == "" {
+		e.Title = base
+	}
+	if e.Created.IsZero() {
+		e.Created = mtime
+		if t, ok := storage.FilenameTime(name); ok {
+			e.Created = t
+		}
+	}
+	if e.Updated.IsZero() {
+		e.Updated = mtime
+		if e.Updated.Before(e.Created) {
+			e.Updated = e.Created
+		}
+	}
+	return e
+}
+
+// heading returns the "# " heading content starts with, if any
+func heading(content string) string {
+	first, _, _ := strings.Cut(strings.TrimLeft(content, "\n"), "\n")
+	if strings.HasPrefix(first, "# ") {
+		return strings.TrimSpace(first[2:])
+	}
+	return ""
+}
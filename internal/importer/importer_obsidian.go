@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Obsidian reads an Obsidian vault: Markdown files in nested folders, with
+// #inline-tags joining the front matter ones. Wikilinks are kept as they are;
+// notes keep their file names and attachments their paths, so the links
+// still resolve in a vault exported from the journal.
+type Obsidian struct{}
+
+func (Obsidian) Name() string { return "obsidian" }
+
+func (Obsidian) Read(dir string) ([]Note, error) {
+	notes, err := Markdown{}.Read(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range notes {
+		e := &notes[i].Entry
+		var tags []string
+		for _, t := range e.Tags {
+			// Obsidian allows tags: [#a] too
+			if t = strings.TrimPrefix(t, "#"); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		e.Tags = addTags(tags, InlineTags(e.Content))
+	}
+	return notes, nil
+}
+
+// Attachments lists the files of the vault other than notes, such as the
+// images in its attachments folder
+func (Obsidian) Attachments(dir string) ([]Attachment, error) {
+	var atts []Attachment
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || isMarkdown(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		atts = append(atts, Attachment{Source: p, Path: filepath.ToSlash(rel)})
+		return nil
+	})
+	return atts, err
+}
+
+var (
+	inlineTag  = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_/-]+)`)
+	inlineCode = regexp.MustCompile("`[^`\n]*`")
+)
+
+// InlineTags returns the #tags in markdown content, the way Obsidian finds
+// them: not in code, not headings, and not all digits
+func InlineTags(content string) []string {
+	var tags []string
+	fenced := false
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		line = inlineCode.ReplaceAllString(line, "")
+		for _, m := range inlineTag.FindAllStringSubmatch(line, -1) {
+			tag := strings.Trim(m[1], "/")
+			if strings.Trim(tag, "0123456789") != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return addTags(nil, tags)
+}
+
+// addTags appends the tags in more that tags lacks, ignoring case
+func addTags(tags, more []string) []string {
+	if tags == nil {
+		tags = []string{}
+	}
+	for _, t := range more {
+		dup := false
+		for _, have := range tags {
+			if strings.EqualFold(have, t) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
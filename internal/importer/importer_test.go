@@ -0,0 +1,390 @@
+package importer
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/export"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+func write(t *testing.T, p, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// byTitle loads the entries of s, content included, keyed by title
+func byTitle(t *testing.T, s storage.Store) map[string]storage.Entry {
+	t.Helper()
+	ents, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]storage.Entry{}
+	for _, e := range ents {
+		full, err := s.Get(e.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[full.Title] = full
+	}
+	return out
+}
+
+// sameTags reports whether a and b hold the same tags, in any order
+func sameTags(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func TestMarkdownFolder(t *testing.T) {
+	dir := t.TempDir()
+	write(t, filepath.Join(dir, "work", "retro.md"), "---\nid: 01J1Z5Y7Q8R9S0T1V2W3X4Y5Z6\ncreated: 2024-03-01T09:00:00Z\n"+
+		"updated: 2024-03-02T10:00:00Z\ntags: [work, retro]\n---\n# Retro\n\nWent well.\n")
+	write(t, filepath.Join(dir, "20240105-083000-plain.md"), "# Plain note\n\nno front matter\n")
+	write(t, filepath.Join(dir, "Scratch.markdown"), "just text\n")
+	write(t, filepath.Join(dir, ".obsidian", "hidden.md"), "# Hidden\n")
+	write(t, filepath.Join(dir, "image.png"), "png")
+
+	notes, err := Markdown{}.Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := storage.NewFSStore(t.TempDir())
+	rep, err := Import(s, notes, Options{Folder: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Count(Imported) != 3 || len(rep.Results) != 3 {
+		t.Fatalf("report = %+v", rep)
+	}
+	got := byTitle(t, s)
+	retro := got["Retro"]
+	if retro.ID != "01J1Z5Y7Q8R9S0T1V2W3X4Y5Z6" || retro.Filename != "old/work/retro.md" ||
+		!retro.Created.Equal(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)) ||
+		!retro.Updated.Equal(time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)) || strings.Join(retro.Tags, ",") != "work,retro" {
+		t.Errorf("retro = %+v", retro)
+	}
+	plain := got["Plain note"]
+	if !plain.Created.Equal(time.Date(2024, 1, 5, 8, 30, 0, 0, time.Local)) || plain.Filename != "old/20240105-083000-plain.md" {
+		t.Errorf("plain = %+v", plain)
+	}
+	if scratch, ok := got["Scratch"]; !ok || scratch.Filename != "old/Scratch.md" || scratch.Content != "just text\n" {
+		t.Errorf("scratch = %+v", scratch)
+	}
+}
+
+func TestDryRunAndDuplicates(t *testing.T) {
+	s := storage.NewMemStore()
+	storage.SaveEntry(s, "Existing", "already here", nil)
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	note := func(title, body string) Note {
+		_, content := withTitle(title + "\n\n" + body)
+		return Note{Source: title, Entry: storage.Entry{Title: title, Content: content, Created: at, Updated: at}}
+	}
+	notes := []Note{
+		note("Existing", "already here"),
+		note("New", "fresh"),
+		note("New again", "fresh"),
+		note("New", "fresh\r\n"), // same content as the second, give or take line endings
+		{Source: "broken.json#3", Err: os.ErrInvalid},
+		note("New", "other"), // same file name as the second
+	}
+
+	rep, err := Import(s, notes, Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rep.Summary(); got != "would import 3, 2 duplicates, 1 skipped" {
+		t.Errorf("dry run: %s", got)
+	}
+	if ents, _ := s.List(); len(ents) != 1 {
+		t.Errorf("dry run wrote %d entries", len(ents)-1)
+	}
+	if rep.Results[3].Status != Duplicate || rep.Results[3].Filename != rep.Results[1].Filename {
+		t.Errorf("duplicate within the import: %+v", rep.Results[3])
+	}
+
+	dry := rep
+	rep, _ = Import(s, notes, Options{})
+	if got := rep.Summary(); got != "imported 3, 2 duplicates, 1 skipped" {
+		t.Errorf("import: %s", got)
+	}
+	for i, res := range rep.Results {
+		if res.Filename != dry.Results[i].Filename {
+			t.Errorf("%s: dry run said %s, import wrote %s", res.Source, dry.Results[i].Filename, res.Filename)
+		}
+	}
+	if e := byTitle(t, s)["New again"]; !e.Created.Equal(at) || !e.Updated.Equal(at) {
+		t.Errorf("dates not kept: %+v", e)
+	}
+	// a second run finds nothing new
+	if rep, _ = Import(s, notes, Options{}); rep.Count(Imported) != 0 || rep.Count(Duplicate) != 5 {
+		t.Errorf("reimport: %s", rep.Summary())
+	}
+}
+
+func TestJrnl(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "journal.txt")
+	write(t, p, "[2024-02-01 09:15] Standup with @work folks. Talked about\n@q1 plans.\n\nMore notes.\n\n"+
+		"2024-02-02 07:05 PM Evening walk\n\n[2024-02-03 08:00] \n")
+	notes, err := Jrnl{}.Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 3 {
+		t.Fatalf("read %d notes", len(notes))
+	}
+	first := notes[0].Entry
+	if first.Title != "Standup with @work folks." ||
+		first.Content != "# Standup with @work folks.\n\nTalked about\n@q1 plans.\n\nMore notes.\n" ||
+		!first.Created.Equal(time.Date(2024, 2, 1, 9, 15, 0, 0, time.Local)) || strings.Join(first.Tags, ",") != "work,q1" {
+		t.Errorf("first = %+v", first)
+	}
+	second := notes[1].Entry
+	if second.Title != "Evening walk" || second.Created.Hour() != 19 || notes[1].Source != "journal.txt#2" {
+		t.Errorf("second = %+v from %s", second, notes[1].Source)
+	}
+	if notes[2].Err == nil {
+		t.Error("empty entry read without an error")
+	}
+}
+
+func TestDayOne(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "Journal.json")
+	write(t, p, `{"metadata": {"version": "1.0"}, "entries": [
+  {"uuid": "ABC", "creationDate": "2023-05-06T07:08:09Z", "modifiedDate": "2023-05-07T00:00:00Z",
+   "text": "# Trip\n\nWe went to the coast.", "tags": ["travel"], "starred": true},
+  {"uuid": "DEF", "creationDate": "2023-05-08T10:00:00Z", "modifiedDate": "2023-05-08T10:00:00Z",
+   "text": "Quick thought\nsecond line"}
+]}`)
+	if x, err := Detect(p); err != nil || x.Name() != "dayone" {
+		t.Errorf("Detect = %v, %v", x, err)
+	}
+	notes, err := DayOne{}.Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trip, quick := notes[0].Entry, notes[1].Entry
+	if trip.Title != "Trip" || trip.Content != "# Trip\n\nWe went to the coast.\n" || trip.Tags[0] != "travel" ||
+		trip.Fields["starred"] != "true" || !trip.Updated.Equal(time.Date(2023, 5, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("trip = %+v", trip)
+	}
+	if quick.Title != "Quick thought" || quick.Content != "# Quick thought\n\nsecond line\n" || len(quick.Tags) != 0 {
+		t.Errorf("quick = %+v", quick)
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	src := storage.NewMemStore()
+	retro, _ := storage.SaveEntryIn(src, "work", "Retro", "went well", []string{"work", "retro"})
+	storage.SaveEntry(src, "Groceries", "milk", nil)
+	dir := t.TempDir()
+
+	ents, _ := src.List()
+	all, err := export.ArchiveFile(dir, src, ents, export.Markdown{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, err := Detect(all); err != nil || x.Name() != "zip" {
+		t.Errorf("Detect = %v, %v", x, err)
+	}
+	notes, err := Archive{}.Read(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := storage.NewMemStore()
+	if rep, _ := Import(dst, notes, Options{}); rep.Count(Imported) != 2 {
+		t.Fatalf("import: %+v", rep)
+	}
+	back := byTitle(t, dst)["Retro"]
+	// the manifest keeps the dates the front matter rounds to seconds
+	if back.ID != retro.ID || back.Filename != retro.Filename || !back.Created.Equal(retro.Created) ||
+		!back.Updated.Equal(retro.Updated) || strings.Join(back.Tags, ",") != "work,retro" || back.Content != retro.Content {
+		t.Errorf("retro came back as %+v", back)
+	}
+
+	// json exports carry everything in their records; html ones can't come back
+	full, _ := src.Get(retro.ID)
+	js, err := export.ArchiveFile(dir, src, []storage.Entry{full}, export.JSON{}, "tag:retro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes, err = Archive{}.Read(js)
+	if err != nil || len(notes) != 1 || notes[0].Entry.ID != retro.ID || notes[0].Entry.Filename != retro.Filename {
+		t.Fatalf("json archive read as %+v, %v", notes, err)
+	}
+	html, _ := export.ArchiveFile(dir, src, []storage.Entry{full}, export.HTML{}, "")
+	if notes, err = (Archive{}).Read(html); err != nil || len(notes) != 1 || notes[0].Err == nil {
+		t.Errorf("html archive read as %+v, %v", notes, err)
+	}
+}
+
+func TestObsidianVault(t *testing.T) {
+	vault := t.TempDir()
+	write(t, filepath.Join(vault, ".obsidian", "app.json"), "{}")
+	write(t, filepath.Join(vault, "Projects", "Launch plan.md"), "---\ntags: [#work]\ncreated: 2024-04-01\n---\n"+
+		"Steps for the #launch, see [[Meeting notes]].\n\n![[diagram.png]]\n\n```\n#not-a-tag\n```\n#2024 is a year, #q2/goals a tag\n")
+	write(t, filepath.Join(vault, "Meeting notes.md"), "# Meeting notes\n\nTalked about `#code` and #Launch.\n")
+	write(t, filepath.Join(vault, "attachments", "diagram.png"), "png")
+	if x, err := Detect(vault); err != nil || x.Name() != "obsidian" {
+		t.Errorf("Detect = %v, %v", x, err)
+	}
+	notes, err := Obsidian{}.Read(vault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	atts, err := Obsidian{}.Attachments(vault)
+	if err != nil || len(atts) != 1 || atts[0].Path != "attachments/diagram.png" {
+		t.Fatalf("attachments = %+v, %v", atts, err)
+	}
+
+	data := t.TempDir()
+	s := storage.NewFSStore(data)
+	opts := Options{Folder: "vault"}
+	if _, err := Import(s, notes, opts); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := CopyAttachments(atts, data, opts); n != 1 || err != nil {
+		t.Fatalf("copied %d attachments: %v", n, err)
+	}
+	got := byTitle(t, s)
+	plan := got["Launch plan"]
+	if plan.Filename != "vault/Projects/Launch plan.md" || strings.Join(plan.Tags, ",") != "work,launch,q2/goals" ||
+		!plan.Created.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)) || !strings.Contains(plan.Content, "[[Meeting notes]]") {
+		t.Errorf("plan = %+v", plan)
+	}
+	if tags := got["Meeting notes"].Tags; strings.Join(tags, ",") != "Launch" {
+		t.Errorf("meeting notes tags = %v", tags)
+	}
+
+	// back out to a vault: same note names, so the links resolve, and the image
+	out := t.TempDir()
+	ents, _ := s.List()
+	if err := export.Obsidian(out, s, ents, data); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"vault/Projects/Launch plan.md", "vault/Meeting notes.md", "vault/attachments/diagram.png", ".obsidian"} {
+		if _, err := os.Stat(filepath.Join(out, f)); err != nil {
+			t.Errorf("exported vault lacks %s", f)
+		}
+	}
+}
+
+func TestJoplinRaw(t *testing.T) {
+	dir := t.TempDir()
+	props := func(id, typ string, more ...string) string {
+		return "id: " + id + "\n" + strings.Join(more, "\n") + "\ncreated_time: 2023-01-01T00:00:00.000Z\n" +
+			"updated_time: 2023-01-01T00:00:00.000Z\nencryption_applied: 0\ntype_: " + typ
+	}
+	const (
+		work  = "11111111111111111111111111111111"
+		sub   = "22222222222222222222222222222222"
+		note  = "0188d3c5a6f04b7e9a1b2c3d4e5f6a7b"
+		tag   = "33333333333333333333333333333333"
+		image = "44444444444444444444444444444444"
+	)
+	write(t, filepath.Join(dir, work+".md"), "Work\n\n"+props(work, "2", "parent_id: "))
+	write(t, filepath.Join(dir, sub+".md"), "Q3/Q4\n\n"+props(sub, "2", "parent_id: "+work))
+	write(t, filepath.Join(dir, note+".md"), "Planning\n\nFirst line.\n\n![chart](:/"+image+")\n\n"+
+		props(note, "1", "parent_id: "+sub, "user_created_time: 2022-06-01T10:00:00.000Z",
+			"user_updated_time: 2022-06-02T11:00:00.000Z"))
+	write(t, filepath.Join(dir, tag+".md"), "roadmap\n\n"+props(tag, "5"))
+	write(t, filepath.Join(dir, "55555555555555555555555555555555.md"), props("55555555555555555555555555555555", "6",
+		"note_id: "+note, "tag_id: "+tag))
+	write(t, filepath.Join(dir, image+".md"), "chart.png\n\n"+props(image, "4", "mime: image/png", "file_extension: png"))
+	write(t, filepath.Join(dir, "resources", image+".png"), "png")
+	if x, err := Detect(dir); err != nil || x.Name() != "joplin" {
+		t.Errorf("Detect = %v, %v", x, err)
+	}
+
+	notes, err := Joplin{}.Read(dir)
+	if err != nil || len(notes) != 1 {
+		t.Fatalf("read %+v, %v", notes, err)
+	}
+	e := notes[0].Entry
+	wantID, _ := storage.IDFromHex(note)
+	if e.ID != wantID || e.Title != "Planning" || !strings.HasPrefix(e.Filename, "Work/Q3-Q4/20220601-") ||
+		strings.Join(e.Tags, ",") != "roadmap" || !e.Updated.Equal(time.Date(2022, 6, 2, 11, 0, 0, 0, time.UTC)) ||
+		e.Content != "# Planning\n\nFirst line.\n\n![chart](../../_resources/"+image+".png)\n" {
+		t.Errorf("note = %+v", e)
+	}
+	atts, err := Joplin{}.Attachments(dir)
+	if err != nil || len(atts) != 1 || atts[0].Path != "_resources/"+image+".png" {
+		t.Errorf("attachments = %+v, %v", atts, err)
+	}
+}
+
+func TestRoundTrips(t *testing.T) {
+	data := t.TempDir()
+	src := storage.NewFSStore(data)
+	write(t, filepath.Join(data, "work", "pic.png"), "png")
+	retro, _ := storage.SaveEntryIn(src, "work/q3", "Q3 retro", "Went well. ![pic](../pic.png)", []string{"work/retro", "q3"})
+	plain, _ := storage.SaveEntry(src, "Groceries", "milk", nil)
+	ents, _ := src.List()
+
+	for _, tc := range []struct {
+		name  string
+		out   func(dir string) error
+		in    Importer
+		names func(e storage.Entry) string // file name the entry comes back with
+	}{
+		{"obsidian", func(dir string) error { return export.Obsidian(dir, src, ents, data) }, Obsidian{},
+			func(e storage.Entry) string { return path.Join(e.Folder(), e.Title+".md") }},
+		{"joplin", func(dir string) error { return export.Joplin(dir, src, ents, data) }, Joplin{},
+			func(e storage.Entry) string { return e.Filename }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := tc.out(dir); err != nil {
+				t.Fatal(err)
+			}
+			notes, err := tc.in.Read(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back := t.TempDir()
+			dst := storage.NewFSStore(back)
+			if rep, err := Import(dst, notes, Options{}); err != nil || rep.Count(Imported) != 2 {
+				t.Fatalf("import: %+v, %v", rep, err)
+			}
+			atts, _ := tc.in.(Attacher).Attachments(dir)
+			if _, err := CopyAttachments(atts, back, Options{}); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []storage.Entry{retro, plain} {
+				got, err := dst.Get(want.ID)
+				if err != nil {
+					t.Fatalf("%s: %v", want.Title, err)
+				}
+				if got.Title != want.Title || got.Filename != tc.names(want) || !sameTags(got.Tags, want.Tags) ||
+					!got.Created.Equal(want.Created.Truncate(time.Second)) || !got.Updated.Equal(want.Updated.Truncate(time.Second)) {
+					t.Errorf("%s came back as %+v", want.Title, got)
+				}
+				if got.Title == "Q3 retro" && ContentHash(got.Content) != ContentHash(retro.Content) {
+					// the image link changes form, but must still find the image
+					m := regexp.MustCompile(`\]\(([^)]+)\)`).FindStringSubmatch(got.Content)
+					if m == nil {
+						t.Fatalf("retro content = %q", got.Content)
+					}
+					if _, err := os.Stat(filepath.Join(back, filepath.FromSlash(path.Join(got.Folder(), m[1])))); err != nil {
+						t.Errorf("retro links to a missing %s", m[1])
+					}
+				}
+			}
+		})
+	}
+}
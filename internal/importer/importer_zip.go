@@ -0,0 +1,147 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/NekoLambda/journal-tui/internal/export"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Archive reads a zip the journal exported with export.Archive, in the
+// markdown or json format, or a plain zip of entry files. The manifest, when
+// there is one, fills in what a file lacks.
+type Archive struct{}
+
+func (Archive) Name() string { return "zip" }
+
+func (Archive) Read(p string) ([]Note, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	listed := map[string]export.Record{}
+	for _, f := range zr.File {
+		if f.Name != export.ManifestFile {
+			continue
+		}
+		var man export.Manifest
+		if err := readJSON(f, &man); err != nil {
+			return nil, fmt.Errorf("%s: %w", export.ManifestFile, err)
+		}
+		for _, me := range man.Entries {
+			listed[me.Path] = me.Record
+		}
+	}
+	var notes []Note
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || f.Name == export.ManifestFile || hidden(f.Name) {
+			continue
+		}
+		rec, ok := listed[f.Name]
+		n := Note{Source: f.Name}
+		switch {
+		case isMarkdown(f.Name):
+			data, err := readAll(f)
+			if err != nil {
+				n.Err = err
+				break
+			}
+			n.Entry = markdownEntry(f.Name, data, f.Modified)
+			if ok {
+				fill(&n.Entry, rec)
+			}
+		case strings.EqualFold(path.Ext(f.Name), ".json"):
+			if err := readJSON(f, &rec); err != nil {
+				n.Err = err
+			} else if rec.Content == "" {
+				n.Err = fmt.Errorf("no content")
+			} else {
+				n.Entry = storage.Entry{Content: rec.Content}
+				fill(&n.Entry, rec)
+			}
+		case ok:
+			n.Err = fmt.Errorf("%s exports can't be read back", strings.TrimPrefix(path.Ext(f.Name), "."))
+		default:
+			// attachments and such
+			continue
+		}
+		notes = append(notes, n)
+	}
+	if notes == nil {
+		return nil, fmt.Errorf("%s: no notes in it", filepath.Base(p))
+	}
+	return notes, nil
+}
+
+// fill sets what e lacks from rec, and takes its file name from rec so the
+// note goes back where it was
+func fill(e *storage.Entry, rec export.Record) {
+	if rec.Filename != "" {
+		e.Filename = rec.Filename
+	}
+	if e.ID == "" {
+		e.ID = rec.ID
+	}
+	if rec.Title != "" && heading(e.Content) == "" {
+		e.Title = rec.Title
+	}
+	if !rec.Created.IsZero() {
+		e.Created = rec.Created
+	}
+	if !rec.Updated.IsZero() {
+		e.Updated = rec.Updated
+	}
+	if len(e.Tags) == 0 && rec.Tags != nil {
+		e.Tags = rec.Tags
+	}
+	if e.Fields == nil && len(rec.Fields) > 0 {
+		// front matter values are strings or lists of them
+		e.Fields = map[string]any{}
+		for k, v := range rec.Fields {
+			switch v := v.(type) {
+			case []any:
+				list := make([]string, len(v))
+				for i, x := range v {
+					list[i] = fmt.Sprint(x)
+				}
+				e.Fields[k] = list
+			default:
+				e.Fields[k] = fmt.Sprint(v)
+			}
+		}
+	}
+}
+
+// hidden reports whether name is in a hidden folder or is a hidden file
+func hidden(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func readAll(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func readJSON(f *zip.File, v any) error {
+	data, err := readAll(f)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
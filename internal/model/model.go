@@ -1,108 +1,362 @@
 package model
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
-	"sort"
+	"os/exec"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
-	"unicode"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	textinput "github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/lithammer/fuzzysearch/fuzzy"
 
+	"github.com/NekoLambda/journal-tui/internal/config"
+	"github.com/NekoLambda/journal-tui/internal/query"
+	"github.com/NekoLambda/journal-tui/internal/search"
 	"github.com/NekoLambda/journal-tui/internal/storage"
 	"github.com/NekoLambda/journal-tui/ui"
+	"github.com/NekoLambda/journal-tui/ui/components"
 )
 
 type Mode int
 
+const timeLayout = "2006-01-02 15:04"
+
 const (
 	ModeList Mode = iota
 	ModeView
 	ModeSearch
 	ModeHelp
 	ModeAbout
+	ModeNew
+	ModeSaved      // saved search picker
+	ModeSaveSearch // naming the current search
+	ModeTags       // tag browser
+	ModeTagEdit    // editing the tags of an entry
+	ModeTagOp      // renaming, merging or deleting a tag everywhere
+	ModeFolders    // folder pane
+	ModeFolderOp   // naming a folder, or the folder to move an entry to
+	ModeTrash      // deleted entries, to restore or purge
+	ModeHistory    // revisions of an entry
+	ModeExport     // picking the format to export an entry in
+)
+
+// newEntryStep tracks the prompts of the ModeNew flow
+type newEntryStep int
+
+const (
+	stepTitle newEntryStep = iota
+	stepTags
 )
 
+// editorFinishedMsg is sent when the editor opened on an existing entry exits
+type editorFinishedMsg struct {
+	old  storage.Entry // entry as it was before editing
+	path string        // temp file holding the edited copy
+	err  error
+}
+
+// newEntryEditedMsg is sent when the editor for a new draft exits
+type newEntryEditedMsg struct {
+	path  string
+	title string
+	tags  []string
+	err   error
+}
+
+// entriesLoadedMsg carries the result of a background load
+type entriesLoadedMsg struct {
+	gen      int
+	entries  []storage.Entry
+	folders  []string
+	selectID string // entry to put the cursor on once listed
+	skipped  error  // files that couldn't be read, if any
+}
+
+// loadErrorMsg is sent when a background load fails
+type loadErrorMsg struct {
+	gen int
+	err error
+}
+
+// indexSyncedMsg is sent once the search index caught up with a load
+type indexSyncedMsg struct {
+	gen int
+	err error
+}
+
+// loadProgress is written by the background load and read by View
+type loadProgress struct {
+	scanned atomic.Int64 // files listed
+	indexed atomic.Int64 // changed entries indexed so far...
+	toIndex atomic.Int64 // ...out of this many
+}
+
 type Model struct {
+	store    storage.Store
+	cfg      config.Config
 	mode     Mode
 	entries  []storage.Entry
 	filtered []storage.Entry
 	cursor   int
 	ti       textinput.Model // title / small inputs
-	searchTI textinput.Model // used for search / tag input reuse
+	searchTI textinput.Model // search box; also holds tag browser filters
 	vp       viewport.Model
 	viewText string
+	viewing  storage.Entry // entry shown in ModeView
+	height   int           // terminal height, 0 until the first WindowSizeMsg
+	sortBy   storage.SortKey
+	index    *search.Index
+	queryErr error // parse error of the search box, shown inline
 	err      error
 	msg      string
 
+	settings    config.Settings // per-journal settings, holding the saved searches
+	savedCursor int
+	activeSaved string // name of the saved search shown in the list, if any
+	savedCounts []int  // entries each saved search matches, see countSaved
+
+	// tag editor and browser
+	tagEdit       storage.Entry // entry whose tags are being edited
+	tagReturn     Mode          // mode to go back to from the editor
+	tagSugg       []string      // completions for the tag being typed
+	tagSuggCursor int
+	tagAll        []storage.TagNode // the whole tag tree
+	tagKids       map[string]bool   // lowercased paths with tags below them
+	tagList       []storage.TagNode // visible rows of the tag tree
+	tagCursor     int
+	tagPicked     map[string]bool // by path
+	tagFolded     map[string]bool // by lowercased path
+	tagOp         string          // "r", "m" or "d" in ModeTagOp
+	tagOpTag      string
+
+	// folders (notebooks)
+	folder           string   // folder the list is narrowed to, "" for all
+	folders          []string // every folder, sorted
+	folderCursor     int
+	folderOp         string // "new", "rename" or "move" in ModeFolderOp
+	folderOpSubject  string // folder renamed or id of the entry moved
+	folderReturn     Mode
+	folderSugg       []string
+	folderSuggCursor int
+
+	// confirmation modal, shown over any mode; "y" carries out confirmOp
+	confirm   components.Modal
+	confirmOp string // "trash", "purge", "empty" or "revert"
+	confirmID string // entry id, or revision hash for "revert"
+
+	// trash view
+	trash       []storage.Trashed
+	trashCursor int
+
+	// revision history of an entry
+	histEntry  storage.Entry // current version, with content
+	histRevs   []storage.Revision
+	histStats  []histStat // by revision
+	histCursor int
+	histReturn Mode
+	histVP     viewport.Model // diff of the selected revision
+
+	// export format picker
+	exportEntry  storage.Entry
+	exportAll    bool // the whole filtered list as a zip, not exportEntry
+	exportCursor int  // format, kept between exports
+	exportReturn Mode
+
+	// highlighting of the active query; hl is nil without one
+	hl        *search.Highlighter
+	titleHits map[string][]int       // fuzzy matched title offsets, by id
+	snippets  map[string]snippetLine // result excerpts, by id
+	viewRaw   string                 // content shown in ModeView, before rendering
+	viewSpans []search.Span          // matches in viewRaw
+	viewLines []int                  // rendered line of each match
+	viewMatch int                    // current match for n/N
+
+	// background loading and indexing; results from anything but the latest
+	// load are dropped
+	spinner     spinner.Model
+	loading     bool
+	indexing    bool
+	indexLoaded bool // the saved index was read by a sync
+	loadGen     int
+	progress    *loadProgress
+
+	// new entry flow
+	newStep    newEntryStep
+	draftTitle string
+
 	// styles
 	headerStyle   lipgloss.Style
 	selectedStyle lipgloss.Style
 	normalStyle   lipgloss.Style
 	helpStyle     lipgloss.Style
 	inputStyle    lipgloss.Style
-}
 
-func New() Model {
-	entries, _ := storage.LoadEntries()
+	matchStyle        lipgloss.Style
+	currentMatchStyle lipgloss.Style
+	tagStyle          lipgloss.Style
+}
 
+// New builds the model on top of the given store and journal config
+func New(store storage.Store, cfg config.Config) Model {
 	// textinputs
 	ti := textinput.New()
 	ti.Placeholder = "Title..."
 	ti.CharLimit = 200
 	ti.Width = 40
 
-	search := textinput.New()
-	search.Placeholder = "Search..."
-	search.CharLimit = 200
-	search.Width = 40
+	sti := textinput.New()
+	sti.Placeholder = "Search..."
+	sti.CharLimit = 200
+	sti.Width = 40
 
 	// viewport (height tuned later on WindowSizeMsg)
 	vp := viewport.New(80, 12)
 	vp.SetContent("")
 
+	sp := spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(ui.HelpStyle))
+
 	// styles are assigned directly in the Model struct initialization
 
 	m := Model{
+		store:         store,
+		cfg:           cfg,
+		index:         search.New(),
 		mode:          ModeList,
-		entries:       entries,
-		filtered:      entries,
 		ti:            ti,
-		searchTI:      search,
+		searchTI:      sti,
 		vp:            vp,
+		histVP:        viewport.New(80, 10),
+		spinner:       sp,
+		loading:       true,
+		loadGen:       1,
+		progress:      new(loadProgress),
 		headerStyle:   ui.HeaderStyle,
 		selectedStyle: ui.SelectedStyle,
 		normalStyle:   ui.NormalStyle,
 		helpStyle:     ui.HelpStyle,
 		inputStyle:    ui.InputStyle,
+
+		matchStyle:        ui.MatchStyle,
+		currentMatchStyle: ui.CurrentMatchStyle,
+		tagStyle:          ui.TagStyle,
+	}
+	if settings, err := config.LoadSettings(cfg.SettingsFile()); err != nil {
+		m.err = fmt.Errorf("reading settings: %w", err)
+	} else {
+		m.settings = settings
 	}
 	return m
 }
 
-func (m Model) Init() tea.Cmd { return nil }
+// Init starts the first load; entries show up once it finishes
+func (m Model) Init() tea.Cmd {
+	keep, age := m.settings.HistoryRetention()
+	return tea.Batch(m.spinner.Tick, listEntries(m.store, m.loadGen, m.progress, ""),
+		purgeTrash(m.store, m.settings.TrashRetention()), pruneHistory(m.store, keep, age))
+}
 
 // -------------------- Update --------------------
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// handle resizing for viewport
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		// reserve ~6 lines for header/help/etc
-		h := msg.Height - 6
+		// reserve ~7 lines for header/timestamps/help/etc
+		h := msg.Height - 7
 		if h < 6 {
 			h = 6
 		}
 		m.vp.Width = msg.Width
 		m.vp.Height = h
+		m.height = msg.Height
 		if m.mode == ModeView {
 			m.vp.SetContent(m.viewText)
 		}
 		// continue
+	case newEntryEditedMsg:
+		return m, m.finishNewEntry(msg)
+	case editorFinishedMsg:
+		return m, m.finishEdit(msg)
+	case entriesLoadedMsg:
+		if msg.gen != m.loadGen {
+			return m, nil
+		}
+		m.loading = false
+		m.folders = msg.folders
+		m.setEntries(msg.entries)
+		if msg.skipped != nil {
+			// the rest of the journal is still usable
+			m.err = msg.skipped
+		}
+		if strings.TrimSpace(m.searchTI.Value()) != "" {
+			// keep showing the search, saved ones included, as entries change
+			m.applyFilter(m.searchTI.Value())
+		}
+		if m.mode == ModeTags {
+			m.refreshTags()
+		}
+		// counted again once the index caught up
+		m.savedCounts = nil
+		if msg.selectID != "" {
+			m.selectEntry(msg.selectID)
+		}
+		m.indexing = true
+		return m, m.syncIndex(msg.entries)
+	case loadErrorMsg:
+		if msg.gen == m.loadGen {
+			m.loading, m.indexing = false, false
+			m.err = fmt.Errorf("loading entries: %w", msg.err)
+		}
+		return m, nil
+	case indexSyncedMsg:
+		if msg.gen != m.loadGen {
+			return m, nil
+		}
+		m.indexing = false
+		if msg.err != nil {
+			m.err = fmt.Errorf("indexing entries: %w", msg.err)
+		}
+		// results typed while indexing may have been incomplete, as may the counts
+		if strings.TrimSpace(m.searchTI.Value()) != "" {
+			m.applyFilter(m.searchTI.Value())
+		}
+		return m, m.countSaved()
+	case savedCountedMsg:
+		// counts for another load, folder or set of saved searches are stale
+		if msg.gen == m.loadGen && msg.folder == m.folder && slices.Equal(msg.queries, m.savedQueries()) {
+			m.savedCounts = msg.counts
+		}
+		return m, nil
+	case trashPurgedMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("purging the trash: %w", msg.err)
+		} else if msg.n > 0 {
+			m.msg = fmt.Sprintf("Purged %d notes deleted over %s ago", msg.n, days(m.settings.TrashRetention()))
+		}
+		return m, nil
+	case historyPrunedMsg:
+		if msg.err != nil {
+			m.err = fmt.Errorf("pruning history: %w", msg.err)
+		}
+		return m, nil
+	case spinner.TickMsg:
+		// let the tick chain die once nothing is loading
+		if !m.loading && !m.indexing {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+
+	if key, ok := msg.(tea.KeyMsg); ok && m.confirm.Visible {
+		return m.updateConfirm(key)
 	}
 
 	switch m.mode {
@@ -123,64 +377,117 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "enter":
 				if len(m.filtered) > 0 {
 					ent := m.filtered[m.cursor]
-					content, err := storage.LoadEntryContent(ent)
+					content, err := storage.LoadEntryContent(m.store, ent)
 					if err != nil {
 						m.err = err
 					} else {
-						m.viewText = renderSimpleMarkdown(content, m.headerStyle, m.normalStyle)
-						m.vp.SetContent(m.viewText)
+						m.viewing = ent
+						m.viewMatch = 0
+						m.renderView(content)
 						m.vp.GotoTop()
+						m.scrollToMatch()
 						m.mode = ModeView
 					}
 				}
 			case "n":
-				// create new entry workflow: ask title, open editor, save
-				m.mode = ModeSearch // reuse searchTI as title input step (short)
-				m.searchTI.SetValue("")
-				m.searchTI.Placeholder = "New title (type and Enter)..."
-				m.searchTI.Focus()
+				// create new entry workflow: ask title, then tags, open editor, save
+				m.mode = ModeNew
+				m.newStep = stepTitle
+				m.draftTitle = ""
+				m.err, m.msg = nil, ""
+				m.ti.SetValue("")
+				m.ti.Placeholder = "Title..."
+				m.ti.Focus()
 			case "d":
 				if len(m.filtered) > 0 {
 					ent := m.filtered[m.cursor]
-					_ = storage.DeleteEntry(ent)
-					m.reloadEntries()
+					m.askConfirm("trash", ent.ID, "Delete note?",
+						"\""+ent.Title+"\" goes to the trash, where it can be restored (D).")
+				}
+			case "D":
+				m.openTrash()
+			case "H":
+				if len(m.filtered) > 0 {
+					m.openHistory(m.filtered[m.cursor])
 				}
 			case "e":
-				// edit in-place (opens editor on the file)
+				// edit selected entry; editorFinishedMsg reloads and maybe renames
 				if len(m.filtered) > 0 {
-					ent := m.filtered[m.cursor]
-					path := filepath.Join("data", ent.Filename)
-					if err := storage.EditEntry(path); err != nil {
-						m.err = err
-					} else {
-						// after editing, reload and maybe rename
-						old := ent // keep original
-						m.reloadEntries()
-						m.renameIfTitleChanged(old)
-					}
+					return m, m.editEntry(m.filtered[m.cursor])
 				}
 			case "x":
-				// export selected entry (single)
+				// export selected entry (single), in a format picked next
 				if len(m.filtered) > 0 {
-					ent := m.filtered[m.cursor]
-					path := filepath.Join("data", ent.Filename)
-					if _, err := storage.ExportEntry(path); err != nil {
-						m.err = err
-					} else {
-						m.msg = "Exported."
-					}
+					m.startExport(m.filtered[m.cursor])
+				}
+			case "X":
+				// export every listed entry as a zip, in a format picked next
+				if len(m.filtered) > 0 {
+					m.startBulkExport()
 				}
 			case "/":
 				m.mode = ModeSearch
 				m.searchTI.SetValue("")
+				m.activeSaved = ""
 				m.searchTI.Placeholder = "Search..."
 				m.searchTI.Focus()
+			case "o":
+				// toggle ordering between created and updated
+				if m.sortBy == storage.SortCreated {
+					m.sortBy = storage.SortUpdated
+				} else {
+					m.sortBy = storage.SortCreated
+				}
+				m.setEntries(m.entries)
+				m.msg = "Sorted by " + m.sortBy.String()
+			case "t":
+				if len(m.filtered) > 0 {
+					m.startTagEdit(m.filtered[m.cursor])
+				}
+			case "T":
+				m.openTags()
+			case "s":
+				m.openSaved()
+			case "f":
+				m.openFolders()
+			case "m":
+				if len(m.filtered) > 0 {
+					ent := m.filtered[m.cursor]
+					m.startFolderOp("move", ent.ID, ent.Folder())
+				}
+			case "esc":
+				// clear the search first, then the folder
+				if strings.TrimSpace(m.searchTI.Value()) != "" {
+					m.clearSearch()
+				} else if m.folder != "" {
+					return m, m.setFolder("")
+				}
 			case "h":
 				m.mode = ModeHelp
 			case "a":
 				m.mode = ModeAbout
 			}
 		}
+	case ModeSaved:
+		return m.updateSaved(msg)
+	case ModeSaveSearch:
+		return m.updateSaveSearch(msg)
+	case ModeTags:
+		return m.updateTags(msg)
+	case ModeTagEdit:
+		return m.updateTagEdit(msg)
+	case ModeTagOp:
+		return m.updateTagOp(msg)
+	case ModeFolders:
+		return m.updateFolders(msg)
+	case ModeFolderOp:
+		return m.updateFolderOp(msg)
+	case ModeTrash:
+		return m.updateTrash(msg)
+	case ModeHistory:
+		return m.updateHistory(msg)
+	case ModeExport:
+		return m.updateExport(msg)
 	case ModeSearch:
 		// text input driven (live filter)
 		var cmd tea.Cmd
@@ -189,13 +496,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "enter":
-				// finalise search (already applied)
-				m.mode = ModeList
+				// finalise search (already applied) unless it doesn't parse
+				if m.queryErr == nil {
+					m.mode = ModeList
+				}
 			case "esc":
 				// cancel search -> clear
-				m.searchTI.SetValue("")
-				m.applyFilter("")
+				m.clearSearch()
 				m.mode = ModeList
+			case "ctrl+s":
+				m.startSaveSearch()
 			default:
 				// live filtering
 				m.applyFilter(m.searchTI.Value())
@@ -207,10 +517,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "q", "esc":
-				// when leaving view, reset any active search so user returns to full list
-				if strings.TrimSpace(m.searchTI.Value()) != "" {
-					m.searchTI.SetValue("")
-					m.applyFilter("")
+				// when leaving view, reset any active search so user returns to full
+				// list, unless it is a saved search the user picked to browse
+				if strings.TrimSpace(m.searchTI.Value()) != "" && m.activeSaved == "" {
+					m.clearSearch()
 				}
 				m.mode = ModeList
 			case "j", "down":
@@ -225,33 +535,21 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.vp.GotoTop()
 			case "G":
 				m.vp.GotoBottom()
+			case "n":
+				m.jumpMatch(1)
+			case "N":
+				m.jumpMatch(-1)
+			case "t":
+				m.startTagEdit(m.viewing)
+			case "H":
+				m.openHistory(m.viewing)
 			case "e":
-				// edit current entry
-				if m.cursor < len(m.filtered) {
-					ent := m.filtered[m.cursor]
-					path := filepath.Join("data", ent.Filename)
-					if err := storage.EditEntry(path); err != nil {
-						m.err = err
-					} else {
-						old := ent
-						m.reloadEntries()
-						m.renameIfTitleChanged(old)
-						// refresh view content for this item
-						for _, e := range m.entries {
-							if e.Filename == ent.Filename {
-								content, err := storage.LoadEntryContent(e)
-								if err == nil {
-									m.viewText = renderSimpleMarkdown(content, m.headerStyle, m.normalStyle)
-									m.vp.SetContent(m.viewText)
-									m.vp.GotoTop()
-								}
-								break
-							}
-						}
-					}
-				}
+				// edit the entry shown
+				return m, m.editEntry(m.viewing)
 			}
 		}
+	case ModeNew:
+		return m.updateNew(msg)
 	case ModeHelp:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -277,23 +575,30 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m Model) View() string {
 	var b strings.Builder
 	b.WriteString(m.headerStyle.Render("📓 Journal-TUI") + "\n\n")
+	if m.confirm.Visible {
+		b.WriteString(m.confirm.View())
+		return b.String()
+	}
 
 	switch m.mode {
 	case ModeList:
-		if len(m.filtered) == 0 {
-			b.WriteString(m.normalStyle.Render("(no entries)") + "\n")
+		if status := m.loadStatus(); status != "" {
+			b.WriteString(status + "\n\n")
 		}
-		for i, e := range m.filtered {
-			if i == m.cursor {
-				line := m.selectedStyle.Render("> " + e.Title)
-				b.WriteString(line + "\n")
-			} else {
-				line := m.normalStyle.Render("  " + e.Title)
-				b.WriteString(line + "\n")
-			}
+		if m.folder != "" {
+			b.WriteString(m.normalStyle.Render("📁 "+m.folder) + m.helpStyle.Render("  (f: folders)") + "\n\n")
+		}
+		if m.activeSaved != "" {
+			b.WriteString(m.normalStyle.Render("Saved search: "+m.activeSaved) +
+				m.helpStyle.Render("  "+strings.TrimSpace(m.searchTI.Value())+"  (esc: show all)") + "\n\n")
 		}
+		if len(m.filtered) == 0 && !m.loading {
+			b.WriteString(m.normalStyle.Render("(no entries)") + "\n")
+		}
+		// leave room for the header, status, help and messages
+		b.WriteString(m.renderResults(m.height - 12))
 		b.WriteString("\n")
-		b.WriteString(m.helpStyle.Render("n: new  e: edit  d: delete  enter: view  /: search  x: export  h: help  a: about  q: quit"))
+		b.WriteString(m.helpStyle.Render("n: new  e: edit  H: history  d: delete  D: trash  enter: view  /: search  s: saved  t: tag  T: tags  f: folders  m: move  o: order  x/X: export  h: help  a: about  q: quit"))
 		if m.err != nil {
 			b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
 		}
@@ -303,20 +608,96 @@ func (m Model) View() string {
 	case ModeSearch:
 		b.WriteString(m.normalStyle.Render("Search (live):\n\n"))
 		b.WriteString(m.inputStyle.Render(m.searchTI.View()) + "\n\n")
-		b.WriteString(m.renderListSnippet())
+		if m.queryErr != nil {
+			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("✗ "+m.queryErr.Error()) +
+				m.helpStyle.Render("  (showing the last valid results)") + "\n\n")
+		}
+		if status := m.loadStatus(); status != "" {
+			b.WriteString(status + m.helpStyle.Render(" (results may be incomplete)") + "\n\n")
+		}
+		b.WriteString(m.renderResults(m.height - 14))
+		b.WriteString("\n" + m.helpStyle.Render("enter: done  esc: clear  ctrl+s: save search"))
+		if m.msg != "" {
+			b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B")).Render(m.msg))
+		}
+	case ModeSaveSearch:
+		b.WriteString(m.normalStyle.Render("Save search \"" + strings.TrimSpace(m.searchTI.Value()) + "\" as:\n\n"))
+		b.WriteString(m.inputStyle.Render(m.ti.View()) + "\n\n")
+		b.WriteString(m.helpStyle.Render("enter: save (replaces a search of the same name)  esc: cancel"))
+		if m.err != nil {
+			b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+		}
+	case ModeSaved:
+		b.WriteString(m.renderSaved())
+	case ModeTags:
+		b.WriteString(m.renderTags())
+	case ModeTagEdit:
+		b.WriteString(m.renderTagEdit())
+	case ModeTagOp:
+		b.WriteString(m.renderTagOp())
+	case ModeFolders:
+		b.WriteString(m.renderFolders())
+	case ModeFolderOp:
+		b.WriteString(m.renderFolderOp())
+	case ModeTrash:
+		b.WriteString(m.renderTrash())
+	case ModeHistory:
+		b.WriteString(m.renderHistory())
+	case ModeExport:
+		b.WriteString(m.renderExport())
+	case ModeNew:
+		if m.newStep == stepTitle {
+			b.WriteString(m.normalStyle.Render("New entry — title:\n\n"))
+		} else {
+			b.WriteString(m.normalStyle.Render("New entry — tags for \"" + m.draftTitle + "\" (comma separated, optional):\n\n"))
+		}
+		b.WriteString(m.inputStyle.Render(m.ti.View()) + "\n\n")
+		if m.newStep == stepTags {
+			b.WriteString(m.renderTagSuggestions())
+			b.WriteString(m.helpStyle.Render("tab: complete  enter: next  esc: cancel"))
+		} else {
+			b.WriteString(m.helpStyle.Render("enter: next  esc: cancel"))
+		}
+		if m.err != nil {
+			b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+		}
 	case ModeView:
-		b.WriteString(m.normalStyle.Render("[Viewing — press q to go back]\n\n"))
+		b.WriteString(m.normalStyle.Render("[Viewing — press q to go back]") + "\n")
+		folder := ""
+		if f := m.viewing.Folder(); f != "" {
+			folder = "📁 " + f + " · "
+		}
+		b.WriteString(m.helpStyle.Render(fmt.Sprintf("%sCreated %s · Updated %s%s", folder,
+			m.viewing.Created.Format(timeLayout), m.viewing.Updated.Format(timeLayout), m.matchStatus())) + "\n")
+		if len(m.viewing.Tags) > 0 {
+			b.WriteString(m.chips(m.viewing.Tags) + "\n")
+		}
+		b.WriteString("\n")
 		b.WriteString(m.vp.View())
 		b.WriteString("\n")
 	case ModeHelp:
 		b.WriteString(lipgloss.NewStyle().Padding(1, 2).Render(
 			"Help\n\n" +
-				"n : new note (asks for title, then opens editor)\n" +
+				"n : new note (asks for title and tags, then opens editor)\n" +
 				"e : edit selected note\n" +
-				"d : delete selected note\n" +
+				"H : history of the selected or viewed note; r restores a revision\n" +
+				"d : delete selected note (it goes to the trash)\n" +
+				"D : trash; r restores a note, d deletes it for good, E empties the trash\n" +
 				"Enter : view selected note\n" +
-				"/ : search notes (live)\n" +
-				"x : export selected note\n" +
+				"n/N : next/previous match while viewing a search result\n" +
+				"/ : search notes (live); try tag:work -tag:draft after:2025-01-01\n" +
+				"    before:2025-06-30 title:\"standup\" folder:work \"exact phrase\" OR retro\n" +
+				"ctrl+s : save the current search under a name (while searching)\n" +
+				"s : saved searches; esc clears the one shown\n" +
+				"t : edit the tags of the selected note (tab completes known tags)\n" +
+				"T : tag browser; space picks tags, enter shows notes with all of them,\n" +
+				"    ←/→ fold nested tags, r/m/d rename, merge or delete a tag everywhere\n" +
+				"f : folders; enter shows a folder's notes (new ones are created there),\n" +
+				"    n makes a folder, r renames or moves one; esc in the list shows all\n" +
+				"m : move the selected note to another folder (tab completes)\n" +
+				"o : order by created / updated\n" +
+				"x : export selected note as Markdown, HTML, JSON or plain text\n" +
+				"X : export every listed note (search, tag or folder) as a zip with a manifest\n" +
 				"h : help\n" +
 				"a : about\n" +
 				"q : quit\n\n" +
@@ -335,12 +716,79 @@ func (m Model) View() string {
 }
 
 // -------------------- Helpers --------------------
-func (m *Model) reloadEntries() {
-	ents, _ := storage.LoadEntries()
+
+// loadStatus is the spinner line shown while loading or indexing, if any
+func (m *Model) loadStatus() string {
+	switch {
+	case m.loading:
+		return m.spinner.View() + m.helpStyle.Render(fmt.Sprintf(" Loading entries… %d scanned", m.progress.scanned.Load()))
+	case m.indexing && m.progress.toIndex.Load() > 0:
+		return m.spinner.View() + m.helpStyle.Render(fmt.Sprintf(" Indexing… %d/%d", m.progress.indexed.Load(), m.progress.toIndex.Load()))
+	}
+	return ""
+}
+
+// listEntries lists the store off the Update loop, counting scanned files
+// into progress as it goes
+func listEntries(store storage.Store, gen int, progress *loadProgress, selectID string) tea.Cmd {
+	return func() tea.Msg {
+		ents, err := storage.LoadEntriesProgress(store, func(n int) { progress.scanned.Store(int64(n)) })
+		var skipped *storage.UnreadableError
+		if err != nil && !errors.As(err, &skipped) {
+			return loadErrorMsg{gen: gen, err: err}
+		}
+		folders, ferr := store.Folders()
+		if ferr != nil {
+			return loadErrorMsg{gen: gen, err: ferr}
+		}
+		return entriesLoadedMsg{gen: gen, entries: ents, folders: folders, selectID: selectID, skipped: err}
+	}
+}
+
+// loadEntries starts a reload that supersedes any still in flight; the
+// cursor moves to selectID when it is set and listed
+func (m *Model) loadEntries(selectID string) tea.Cmd {
+	m.loadGen++
+	m.progress = new(loadProgress)
+	load := listEntries(m.store, m.loadGen, m.progress, selectID)
+	if m.loading || m.indexing {
+		// the spinner is already ticking
+		return load
+	}
+	m.loading = true
+	return tea.Batch(load, m.spinner.Tick)
+}
+
+// syncIndex updates the search index from freshly listed entries in the
+// background, reading the saved index first on the initial load
+func (m *Model) syncIndex(ents []storage.Entry) tea.Cmd {
+	ix, store, path := m.index, m.store, m.cfg.SearchIndex()
+	gen, progress, load := m.loadGen, m.progress, !m.indexLoaded
+	m.indexLoaded = true
+	// the model re-sorts its slice in place, so hand over a copy
+	ents = append([]storage.Entry(nil), ents...)
+	return func() tea.Msg {
+		if load && path != "" {
+			ix.Load(path)
+		}
+		err := ix.Sync(store, ents, func(done, total int) {
+			progress.toIndex.Store(int64(total))
+			progress.indexed.Store(int64(done))
+		})
+		if err == nil && path != "" && ix.Dirty() {
+			// the index is only a cache; failing to persist it costs a slower next start
+			_ = ix.Save(path)
+		}
+		return indexSyncedMsg{gen: gen, err: err}
+	}
+}
+
+// setEntries sorts ents and shows all of them in the current folder
+func (m *Model) setEntries(ents []storage.Entry) {
+	storage.SortEntries(ents, m.sortBy)
 	m.entries = ents
 	// default filtered set
-	m.filtered = make([]storage.Entry, len(ents))
-	copy(m.filtered, ents)
+	m.filtered = append([]storage.Entry(nil), m.scoped()...)
 	// clamp cursor
 	if m.cursor >= len(m.filtered) && len(m.filtered) > 0 {
 		m.cursor = len(m.filtered) - 1
@@ -350,36 +798,24 @@ func (m *Model) reloadEntries() {
 	}
 }
 
-func (m *Model) applyFilter(query string) {
-	q := strings.TrimSpace(query)
-	if q == "" {
-		m.filtered = make([]storage.Entry, len(m.entries))
-		copy(m.filtered, m.entries)
+// applyFilter shows the entries matching the search box query (see package
+// query). A query that doesn't parse keeps the previous results and sets
+// m.queryErr instead.
+func (m *Model) applyFilter(input string) {
+	m.queryErr = nil
+	if strings.TrimSpace(input) == "" {
+		m.setHighlights(nil, nil)
+		m.filtered = append([]storage.Entry(nil), m.scoped()...)
 		return
 	}
-	// fuzzy-rank on titles (best matches first). We'll include content matches by fallback.
-	titles := make([]string, len(m.entries))
-	for i := range m.entries {
-		titles[i] = m.entries[i].Title
-	}
-	ranked := fuzzy.RankFindFold(q, titles)
-	sort.Sort(ranked)
-	out := []storage.Entry{}
-	added := map[int]bool{}
-	for _, r := range ranked {
-		out = append(out, m.entries[r.OriginalIndex])
-		added[r.OriginalIndex] = true
-	}
-	// also include any entries where content contains q (case-insensitive) but not already included
-	lq := strings.ToLower(q)
-	for i, e := range m.entries {
-		if added[i] {
-			continue
-		}
-		if strings.Contains(strings.ToLower(e.Content), lq) {
-			out = append(out, e)
-		}
+	// trailing space matters: it ends the last word, which is otherwise a prefix
+	node, err := query.Parse(input)
+	if err != nil {
+		m.queryErr = err
+		return
 	}
+	out, titleHits := query.Match(node, input, m.scoped(), m.queryEnv())
+	m.setHighlights(node, titleHits)
 	m.filtered = out
 	if m.cursor >= len(m.filtered) && len(m.filtered) > 0 {
 		m.cursor = len(m.filtered) - 1
@@ -388,69 +824,283 @@ func (m *Model) applyFilter(query string) {
 	}
 }
 
-func (m *Model) renderListSnippet() string {
-	var b strings.Builder
+// updateNew drives the title -> tags -> editor prompts of ModeNew
+func (m Model) updateNew(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		var cmd tea.Cmd
+		m.ti, cmd = m.ti.Update(msg)
+		return m, cmd
+	}
+	switch key.String() {
+	case "esc", "ctrl+c":
+		m.ti.Blur()
+		m.err = nil
+		m.mode = ModeList
+		return m, nil
+	case "enter":
+		if m.newStep == stepTitle {
+			title := strings.TrimSpace(m.ti.Value())
+			if title == "" {
+				m.err = errors.New("title can't be empty")
+				return m, nil
+			}
+			m.err = nil
+			m.draftTitle = title
+			m.newStep = stepTags
+			m.ti.SetValue("")
+			m.ti.Placeholder = "tag1, tag2..."
+			m.tagSuggCursor = 0
+			m.suggestTags()
+			return m, nil
+		}
+		tags := parseTags(m.ti.Value())
+		m.ti.Blur()
+		m.mode = ModeList
+		return m, m.editDraft(m.draftTitle, tags)
+	}
+	if m.newStep == stepTags && m.updateTagInput(key) {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.ti, cmd = m.ti.Update(msg)
+	if m.newStep == stepTags {
+		m.suggestTags()
+	}
+	return m, cmd
+}
+
+// editDraft writes a skeleton note to a temp file and opens it in the editor
+func (m *Model) editDraft(title string, tags []string) tea.Cmd {
+	tmp, err := os.CreateTemp("", "journal-new-*.md")
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	_, err = tmp.WriteString("# " + title + "\n\n")
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		m.err = err
+		return nil
+	}
+	path := tmp.Name()
+	cmd, err := storage.EditorCmd(m.cfg.Editor, path)
+	if err != nil {
+		os.Remove(path)
+		m.err = err
+		return nil
+	}
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return newEntryEditedMsg{path: path, title: title, tags: tags, err: err}
+	})
+}
+
+// finishNewEntry saves the edited draft, discarding it when the note was
+// left empty. A draft that can't be saved is kept, and the error says where.
+func (m *Model) finishNewEntry(msg newEntryEditedMsg) tea.Cmd {
+	m.mode = ModeList
+	raw, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.err = keptAt(err, msg.path)
+		return nil
+	}
+	content := string(raw)
+	body := strings.TrimSpace(content)
+	if body == "" || body == "# "+msg.title {
+		os.Remove(msg.path)
+		if msg.err != nil {
+			m.err = fmt.Errorf("editor: %w (draft discarded)", msg.err)
+		} else {
+			m.msg = "Empty note discarded."
+		}
+		return nil
+	}
+	if msg.err != nil {
+		m.err = keptAt(fmt.Errorf("editor: %w", msg.err), msg.path)
+		return nil
+	}
+	draft := storage.Entry{Title: msg.title, Content: content, Tags: msg.tags}
+	if m.folder != "" {
+		// new notes go into the folder being shown
+		draft.Filename = storage.EntryPath(m.folder, msg.title, time.Now())
+	}
+	ent, err := m.store.Put(draft)
+	if err != nil {
+		m.err = keptAt(err, msg.path)
+		return nil
+	}
+	os.Remove(msg.path)
+	m.index.Add(ent)
+	m.err = nil
+	m.msg = "Created " + ent.Title
+	m.searchTI.SetValue("")
+	return m.loadEntries(ent.ID)
+}
+
+// keptAt adds to err where the text that couldn't be saved was left
+func keptAt(err error, path string) error {
+	return fmt.Errorf("%w (your text is kept in %s)", err, path)
+}
+
+// selectEntry moves the cursor to the entry with the given id, if listed
+func (m *Model) selectEntry(id string) {
 	for i, e := range m.filtered {
-		cursor := " "
-		if i == m.cursor {
-			cursor = ">"
+		if e.ID == id {
+			m.cursor = i
+			return
 		}
-		b.WriteString(fmt.Sprintf("%s %s\n", cursor, e.Title))
 	}
-	return b.String()
 }
 
-// renameIfTitleChanged: if title in file header changed to a different value, rename file accordingly
-func (m *Model) renameIfTitleChanged(old storage.Entry) {
-	// reload entries and find matching filename
-	for _, e := range m.entries {
-		if e.Filename == old.Filename {
-			// if title differs, rename file
-			if e.Title != old.Title {
-				oldPath := filepath.Join("data", old.Filename)
-				newBase := slugify(e.Title)
-				newFilename := newBase + ".md"
-				newPath := filepath.Join("data", newFilename)
-
-				// avoid clobbering existing file
-				if _, err := os.Stat(newPath); err == nil {
-					// file exists — append timestamp
-					newFilename = fmt.Sprintf("%s-%d.md", newBase, time.Now().Unix())
-					newPath = filepath.Join("data", newFilename)
-				}
-				if err := os.Rename(oldPath, newPath); err != nil {
-					m.err = err
-				} else {
-					// reload to pick up new filename
-					m.reloadEntries()
-				}
-			}
-			break
+// loadContent returns an entry's body, for phrase queries
+func (m *Model) loadContent(id string) (string, error) {
+	e, err := m.store.Get(id)
+	return e.Content, err
+}
+
+// queryEnv is what evaluating a query against the listed entries needs; it
+// holds on to nothing that Update changes, so it can be used off the UI loop
+func (m *Model) queryEnv() query.Env {
+	store := m.store
+	return query.Env{Index: m.index, Content: func(id string) (string, error) {
+		e, err := store.Get(id)
+		return e.Content, err
+	}}
+}
+
+// parseTags splits a comma separated tag list, dropping blanks and duplicates
+func parseTags(s string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, t := range strings.Split(s, ",") {
+		t = storage.CleanTag(t)
+		if t == "" || seen[strings.ToLower(t)] {
+			continue
 		}
+		seen[strings.ToLower(t)] = true
+		tags = append(tags, t)
+	}
+	return tags
+}
+
+// editEntry copies the entry (with front matter) to a temp file and opens it
+// in the editor; the result comes back as an editorFinishedMsg
+func (m *Model) editEntry(ent storage.Entry) tea.Cmd {
+	full, err := m.store.Get(ent.ID)
+	if err != nil {
+		m.err = err
+		return nil
+	}
+	tmp, err := os.CreateTemp("", "journal-*.md")
+	if err != nil {
+		m.err = err
+		return nil
 	}
+	_, err = tmp.Write(storage.MarshalEntry(full))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	var cmd *exec.Cmd
+	if err == nil {
+		cmd, err = storage.EditorCmd(m.cfg.Editor, tmp.Name())
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		m.err = err
+		return nil
+	}
+	path := tmp.Name()
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return editorFinishedMsg{old: full, path: path, err: err}
+	})
 }
 
-func slugify(title string) string {
-	t := strings.ToLower(title)
-	t = strings.ReplaceAll(t, " ", "_")
-	t = strings.Map(func(r rune) rune {
-		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
-			return r
+// finishEdit writes the edited copy back to the store, renames the entry if
+// its title changed and refreshes the list and view. A copy that can't be
+// saved is kept, and the error says where.
+func (m *Model) finishEdit(msg editorFinishedMsg) tea.Cmd {
+	edited, err := os.ReadFile(msg.path)
+	if err != nil {
+		m.err = keptAt(err, msg.path)
+		return nil
+	}
+	if string(edited) == string(storage.MarshalEntry(msg.old)) {
+		os.Remove(msg.path)
+		if msg.err != nil {
+			m.err = fmt.Errorf("editor: %w", msg.err)
 		}
-		return -1
-	}, t)
-	if t == "" {
-		t = "untitled"
+		return nil
+	}
+	if msg.err != nil {
+		m.err = keptAt(fmt.Errorf("editor: %w", msg.err), msg.path)
+		return nil
+	}
+	updated, err := storage.UnmarshalEntry(edited)
+	if err != nil {
+		m.err = keptAt(err, msg.path)
+		return nil
 	}
-	return t
+	// the id is what ties the copy back to the entry, whatever the editor did to it
+	updated.ID = msg.old.ID
+	if updated.Created.IsZero() {
+		updated.Created = msg.old.Created
+	}
+	saved, err := m.store.Put(updated)
+	if err != nil {
+		m.err = keptAt(err, msg.path)
+		return nil
+	}
+	os.Remove(msg.path)
+	m.index.Add(saved)
+	m.err = nil
+	if saved.Title != msg.old.Title {
+		// keep the filename in step with the title
+		if _, err := storage.RenameEntry(m.store, saved.ID); err != nil {
+			m.err = err
+		}
+	}
+	if m.mode == ModeView {
+		m.viewing = saved
+		// refresh view content for this item
+		content, err := storage.LoadEntryContent(m.store, saved)
+		if err == nil {
+			m.renderView(content)
+		}
+	}
+	return m.loadEntries(saved.ID)
 }
 
-// very small markdown -> styled plaintext renderer (headings, code fences, paragraphs)
-func renderSimpleMarkdown(raw string, headerStyle, normalStyle lipgloss.Style) string {
+// highlight marks search matches in rendered markdown; spans are offsets
+// into the raw text and cur is the one drawn in current
+type highlight struct {
+	spans          []search.Span
+	cur            int
+	match, current lipgloss.Style
+}
+
+// very small markdown -> styled plaintext renderer (headings, code fences, paragraphs).
+// Also returns the rendered line of each highlighted span.
+func renderSimpleMarkdown(raw string, headerStyle, normalStyle lipgloss.Style, hl highlight) (string, []int) {
 	var b strings.Builder
 	lines := strings.Split(raw, "\n")
+	spanLines := make([]int, len(hl.spans))
 	inCode := false
+	off, row, next := 0, 0, 0
+	// render draws text, found at raw offset at, with its matches highlighted
+	render := func(text string, at int, base lipgloss.Style) string {
+		return renderSpans(text, at, hl.spans, hl.cur, base, hl.match, hl.current)
+	}
 	for _, L := range lines {
+		for next < len(hl.spans) && hl.spans[next].Start <= off+len(L) {
+			spanLines[next] = row
+			next++
+		}
+		lineOff := off
+		off += len(L) + 1
+		row++
 		trim := strings.TrimSpace(L)
 		if strings.HasPrefix(trim, "```") {
 			inCode = !inCode
@@ -462,24 +1112,25 @@ func renderSimpleMarkdown(raw string, headerStyle, normalStyle lipgloss.Style) s
 			continue
 		}
 		if inCode {
-			b.WriteString(lipgloss.NewStyle().Background(lipgloss.Color("#1E1F29")).Render(L) + "\n")
+			b.WriteString(render(L, lineOff, lipgloss.NewStyle().Background(lipgloss.Color("#1E1F29"))) + "\n")
 			continue
 		}
 		if strings.HasPrefix(trim, "# ") {
 			h := strings.TrimSpace(strings.TrimPrefix(trim, "# "))
-			b.WriteString(headerStyle.Render(h) + "\n\n")
+			b.WriteString(render(h, lineOff+strings.Index(L, h), headerStyle) + "\n\n")
+			row++
 			continue
 		}
 		if strings.HasPrefix(trim, "## ") {
 			h := strings.TrimSpace(strings.TrimPrefix(trim, "## "))
-			b.WriteString(lipgloss.NewStyle().Bold(true).Render(h) + "\n")
+			b.WriteString(render(h, lineOff+strings.Index(L, h), lipgloss.NewStyle().Bold(true)) + "\n")
 			continue
 		}
 		if trim == "" {
 			b.WriteString("\n")
 		} else {
-			b.WriteString(normalStyle.Render(L) + "\n")
+			b.WriteString(render(L, lineOff, normalStyle) + "\n")
 		}
 	}
-	return b.String()
+	return b.String(), spanLines
 }
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/NekoLambda/journal-tui/internal/export"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// formatHelp describes the export formats in the picker
+var formatHelp = map[string]string{
+	"markdown": "the note with its front matter",
+	"html":     "a standalone web page",
+	"json":     "metadata and content as JSON",
+	"text":     "plain text without markup",
+}
+
+// startExport asks which format to export ent in; the picker starts on the
+// format used last
+func (m *Model) startExport(ent storage.Entry) {
+	m.err, m.msg = nil, ""
+	m.exportEntry, m.exportAll = ent, false
+	m.exportReturn = m.mode
+	m.mode = ModeExport
+}
+
+// startBulkExport asks which format to export the listed entries in, as a
+// zip with a manifest
+func (m *Model) startBulkExport() {
+	m.startExport(storage.Entry{})
+	m.exportAll = true
+}
+
+// exportScope describes how the listed entries were picked, as a query: the
+// folder shown and the search, "" when the list is the whole journal
+func (m *Model) exportScope() string {
+	var parts []string
+	if m.folder != "" {
+		if strings.ContainsAny(m.folder, " \t\"") {
+			parts = append(parts, fmt.Sprintf("folder:%q", m.folder))
+		} else {
+			parts = append(parts, "folder:"+m.folder)
+		}
+	}
+	if q := strings.TrimSpace(m.searchTI.Value()); q != "" {
+		parts = append(parts, q)
+	}
+	return strings.Join(parts, " ")
+}
+
+// updateExport handles keys in the export format picker
+func (m Model) updateExport(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	formats := export.Formats()
+	switch key.String() {
+	case "q", "esc":
+		m.mode = m.exportReturn
+	case "j", "down":
+		if m.exportCursor < len(formats)-1 {
+			m.exportCursor++
+		}
+	case "k", "up":
+		if m.exportCursor > 0 {
+			m.exportCursor--
+		}
+	case "enter":
+		m.mode = m.exportReturn
+		if m.exportAll {
+			p, err := export.ArchiveFile(m.cfg.ExportDir(), m.store, m.filtered, formats[m.exportCursor], m.exportScope())
+			if err != nil {
+				m.err = err
+			} else {
+				m.msg = fmt.Sprintf("Exported %d notes to %s", len(m.filtered), p)
+			}
+			return m, nil
+		}
+		// listed entries come without their content
+		full, err := m.store.Get(m.exportEntry.ID)
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		if p, err := export.File(formats[m.exportCursor], full, m.cfg.ExportDir()); err != nil {
+			m.err = err
+		} else {
+			m.msg = "Exported to " + p
+		}
+	}
+	return m, nil
+}
+
+// renderExport renders the export format picker
+func (m *Model) renderExport() string {
+	var b strings.Builder
+	if m.exportAll {
+		title := fmt.Sprintf("Export %d notes", len(m.filtered))
+		if scope := m.exportScope(); scope != "" {
+			title += " (" + scope + ")"
+		}
+		b.WriteString(m.normalStyle.Render(title+" as a zip of:") + "\n\n")
+	} else {
+		b.WriteString(m.normalStyle.Render("Export \""+m.exportEntry.Title+"\" as:") + "\n\n")
+	}
+	for i, x := range export.Formats() {
+		line := x.Name() + m.helpStyle.Render("  "+x.Ext()+"  "+formatHelp[x.Name()])
+		if i == m.exportCursor {
+			b.WriteString(m.selectedStyle.Render("> ") + line + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString("\n" + m.helpStyle.Render("Files go to "+m.cfg.ExportDir()) + "\n\n")
+	b.WriteString(m.helpStyle.Render("enter: export  esc: cancel"))
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	return b.String()
+}
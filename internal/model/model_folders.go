@@ -0,0 +1,329 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// folderPaneWidth is the width of the folder tree next to the notes preview
+const folderPaneWidth = 32
+
+// folderRow is a line of the folder tree; the root "" stands for all notes
+type folderRow struct {
+	path  string
+	depth int
+	count int // entries in the folder and the folders below it
+}
+
+// scoped returns the entries in the current folder
+func (m *Model) scoped() []storage.Entry {
+	if m.folder == "" {
+		return m.entries
+	}
+	var out []storage.Entry
+	for _, e := range m.entries {
+		if storage.InFolder(e.Filename, m.folder) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// folderRows lays out the folder tree with entry counts
+func (m *Model) folderRows() []folderRow {
+	rows := []folderRow{{path: "", count: len(m.entries)}}
+	for _, f := range m.folders {
+		n := 0
+		for _, e := range m.entries {
+			if storage.InFolder(e.Filename, f) {
+				n++
+			}
+		}
+		rows = append(rows, folderRow{path: f, depth: strings.Count(f, "/") + 1, count: n})
+	}
+	return rows
+}
+
+// openFolders shows the folder pane with the current folder selected
+func (m *Model) openFolders() {
+	m.msg, m.err = "", nil
+	m.folderCursor = 0
+	for i, r := range m.folderRows() {
+		if r.path == m.folder {
+			m.folderCursor = i
+		}
+	}
+	m.mode = ModeFolders
+}
+
+// setFolder narrows the list to folder ("" for every note), keeping the search
+func (m *Model) setFolder(folder string) tea.Cmd {
+	m.folder = folder
+	m.cursor = 0
+	m.applyFilter(m.searchTI.Value())
+	return m.countSaved()
+}
+
+// updateFolders handles keys in the folder pane
+func (m Model) updateFolders(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	rows := m.folderRows()
+	m.folderCursor = min(m.folderCursor, len(rows)-1)
+	cur := rows[m.folderCursor]
+	switch key.String() {
+	case "q", "esc":
+		m.err = nil
+		m.mode = ModeList
+	case "j", "down":
+		if m.folderCursor < len(rows)-1 {
+			m.folderCursor++
+		}
+	case "k", "up":
+		if m.folderCursor > 0 {
+			m.folderCursor--
+		}
+	case "enter":
+		m.mode = ModeList
+		return m, m.setFolder(cur.path)
+	case "n":
+		value := cur.path
+		if value != "" {
+			value += "/"
+		}
+		m.startFolderOp("new", cur.path, value)
+	case "r":
+		if cur.path != "" {
+			m.startFolderOp("rename", cur.path, cur.path)
+		}
+	}
+	return m, nil
+}
+
+// startFolderOp prompts for the folder of a new folder ("new"), the new path
+// of folder subject ("rename") or the folder to move entry subject to ("move")
+func (m *Model) startFolderOp(op, subject, value string) {
+	m.folderOp = op
+	m.folderOpSubject = subject
+	m.folderReturn = m.mode
+	m.err = nil
+	m.ti.SetValue(value)
+	m.ti.CursorEnd()
+	m.ti.Placeholder = "folder/subfolder..."
+	m.ti.Focus()
+	m.folderSuggCursor = 0
+	m.suggestFolders()
+	m.mode = ModeFolderOp
+}
+
+// suggestFolders refreshes the folders offered for the folder prompt
+func (m *Model) suggestFolders() {
+	prefix := strings.ToLower(strings.TrimSpace(m.ti.Value()))
+	m.folderSugg = m.folderSugg[:0]
+	for _, f := range m.folders {
+		lower := strings.ToLower(f)
+		if lower == prefix || !strings.HasPrefix(lower, prefix) {
+			continue
+		}
+		m.folderSugg = append(m.folderSugg, f)
+		if len(m.folderSugg) == maxTagSuggestions {
+			break
+		}
+	}
+	m.folderSuggCursor = min(m.folderSuggCursor, max(len(m.folderSugg)-1, 0))
+}
+
+// updateFolderOp drives the folder prompt; tab completes known folders
+func (m Model) updateFolderOp(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		var cmd tea.Cmd
+		m.ti, cmd = m.ti.Update(msg)
+		return m, cmd
+	}
+	switch key.String() {
+	case "esc", "ctrl+c":
+		m.ti.Blur()
+		m.err = nil
+		m.mode = m.folderReturn
+		return m, nil
+	case "tab":
+		if len(m.folderSugg) > 0 {
+			m.ti.SetValue(m.folderSugg[m.folderSuggCursor] + "/")
+			m.ti.CursorEnd()
+			m.folderSuggCursor = 0
+			m.suggestFolders()
+		}
+		return m, nil
+	case "down", "ctrl+n":
+		if m.folderSuggCursor < len(m.folderSugg)-1 {
+			m.folderSuggCursor++
+		}
+		return m, nil
+	case "up", "ctrl+p":
+		if m.folderSuggCursor > 0 {
+			m.folderSuggCursor--
+		}
+		return m, nil
+	case "enter":
+		return m.finishFolderOp(storage.CleanFolder(m.ti.Value()))
+	}
+	var cmd tea.Cmd
+	m.ti, cmd = m.ti.Update(msg)
+	m.suggestFolders()
+	return m, cmd
+}
+
+// finishFolderOp carries out the folder prompt's operation and reloads
+func (m Model) finishFolderOp(folder string) (tea.Model, tea.Cmd) {
+	selectID := ""
+	if m.cursor < len(m.filtered) {
+		selectID = m.filtered[m.cursor].ID
+	}
+	switch m.folderOp {
+	case "new":
+		if folder == "" {
+			m.err = fmt.Errorf("folder needs a name")
+			return m, nil
+		}
+		if err := m.store.MakeFolder(folder); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.msg = "Created folder " + folder
+	case "rename":
+		old := m.folderOpSubject
+		if folder == "" {
+			m.err = fmt.Errorf("folder needs a name")
+			return m, nil
+		}
+		if err := m.store.RenameFolder(old, folder); err != nil {
+			m.err = err
+			return m, nil
+		}
+		if m.folder == old || strings.HasPrefix(m.folder, old+"/") {
+			// the folder being shown moved along
+			m.folder = folder + strings.TrimPrefix(m.folder, old)
+		}
+		m.msg = "Moved folder " + old + " to " + folder
+	case "move":
+		moved, err := storage.MoveEntry(m.store, m.folderOpSubject, folder)
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		selectID = moved.ID
+		where := folder
+		if where == "" {
+			where = "the top level"
+		}
+		m.msg = "Moved " + moved.Title + " to " + where
+	}
+	m.ti.Blur()
+	m.err = nil
+	m.mode = m.folderReturn
+	return m, m.loadEntries(selectID)
+}
+
+// renderFolders renders the folder tree next to the notes of the selected folder
+func (m *Model) renderFolders() string {
+	rows := m.folderRows()
+	height := len(rows)
+	if m.height > 0 {
+		height = min(height, max(m.height-10, 1))
+	}
+	cursor := min(m.folderCursor, len(rows)-1)
+	start := min(max(cursor-height/2, 0), len(rows)-height)
+	var left strings.Builder
+	for i := start; i < start+height; i++ {
+		r := rows[i]
+		name := "All notes"
+		if r.path != "" {
+			name = strings.Repeat("  ", r.depth-1) + "📁 " + path.Base(r.path)
+		}
+		line := fmt.Sprintf("%s %s", name, m.helpStyle.Render(fmt.Sprint(r.count)))
+		if i == cursor {
+			left.WriteString(m.selectedStyle.Render("> ") + line + "\n")
+		} else {
+			left.WriteString("  " + line + "\n")
+		}
+	}
+
+	// preview the notes of the folder under the cursor
+	var right strings.Builder
+	n := 0
+	for _, e := range m.entries {
+		if !storage.InFolder(e.Filename, rows[cursor].path) {
+			continue
+		}
+		if n == height {
+			right.WriteString(m.helpStyle.Render("…") + "\n")
+			break
+		}
+		title := e.Title
+		if sub := strings.TrimPrefix(strings.TrimPrefix(e.Folder(), rows[cursor].path), "/"); sub != "" {
+			title = m.helpStyle.Render(sub+"/") + title
+		}
+		right.WriteString(m.normalStyle.Render(title) + "\n")
+		n++
+	}
+	if n == 0 {
+		right.WriteString(m.helpStyle.Render("(empty)") + "\n")
+	}
+
+	var b strings.Builder
+	b.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+		lipgloss.NewStyle().Width(folderPaneWidth).Render(left.String()),
+		lipgloss.NewStyle().PaddingLeft(2).Render(right.String())) + "\n")
+	b.WriteString(m.helpStyle.Render("enter: show  n: new folder  r: rename/move folder  esc: back"))
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	if m.msg != "" {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B")).Render(m.msg))
+	}
+	return b.String()
+}
+
+// renderFolderOp renders the folder prompt
+func (m *Model) renderFolderOp() string {
+	var b strings.Builder
+	switch m.folderOp {
+	case "new":
+		b.WriteString(m.normalStyle.Render("New folder:"))
+	case "rename":
+		b.WriteString(m.normalStyle.Render("Rename or move folder " + m.folderOpSubject + " to:"))
+	case "move":
+		title := ""
+		for _, e := range m.entries {
+			if e.ID == m.folderOpSubject {
+				title = e.Title
+			}
+		}
+		b.WriteString(m.normalStyle.Render("Move \"" + title + "\" to folder (empty for the top level):"))
+	}
+	b.WriteString("\n\n" + m.inputStyle.Render(m.ti.View()) + "\n\n")
+	for i, f := range m.folderSugg {
+		if i == m.folderSuggCursor {
+			b.WriteString(m.selectedStyle.Render("> "+f) + "\n")
+		} else {
+			b.WriteString(m.helpStyle.Render("  "+f) + "\n")
+		}
+	}
+	if len(m.folderSugg) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString(m.helpStyle.Render("tab: complete  enter: ok  esc: cancel"))
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	return b.String()
+}
@@ -0,0 +1,238 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// historyRows is how many revisions the history view lists at once
+const historyRows = 8
+
+// historyPrunedMsg reports the pruning of old revisions done on startup
+type historyPrunedMsg struct {
+	err error
+}
+
+// pruneHistory applies the history retention policy off the Update loop
+func pruneHistory(store storage.Store, keep int, age time.Duration) tea.Cmd {
+	if keep <= 0 && age <= 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		var before time.Time
+		if age > 0 {
+			before = time.Now().Add(-age)
+		}
+		_, err := store.PruneHistory(keep, before)
+		return historyPrunedMsg{err: err}
+	}
+}
+
+// histStat is how a revision differs from the current version
+type histStat struct {
+	added, removed int
+}
+
+// openHistory shows the revisions of ent
+func (m *Model) openHistory(ent storage.Entry) {
+	m.err, m.msg = nil, ""
+	if m.mode != ModeHistory {
+		m.histReturn = m.mode
+	}
+	cur, err := m.store.Get(ent.ID)
+	if err != nil {
+		m.err = err
+		return
+	}
+	revs, err := m.store.History(ent.ID)
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.histEntry, m.histRevs = cur, revs
+	m.histStats = make([]histStat, len(revs))
+	for i, r := range revs {
+		if old, err := m.store.Revision(cur.ID, r.Hash); err == nil {
+			m.histStats[i].added, m.histStats[i].removed = storage.DiffStat(storage.DiffLines(old.Content, cur.Content))
+		}
+	}
+	m.histCursor = 0
+	m.showRevisionDiff()
+	m.mode = ModeHistory
+}
+
+// showRevisionDiff puts the diff from the selected revision to the current
+// version in the history viewport
+func (m *Model) showRevisionDiff() {
+	m.histVP.Width = m.vp.Width
+	m.histVP.Height = max(m.height-historyRows-14, 5)
+	if len(m.histRevs) == 0 {
+		m.histVP.SetContent("")
+		return
+	}
+	old, err := m.store.Revision(m.histEntry.ID, m.histRevs[m.histCursor].Hash)
+	if err != nil {
+		m.histVP.SetContent(lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: " + err.Error()))
+		return
+	}
+	m.histVP.SetContent(m.renderDiff(old, m.histEntry))
+	m.histVP.GotoTop()
+}
+
+// diffContext is how many unchanged lines are shown around each change
+const diffContext = 3
+
+// renderDiff renders the line diff from old to cur, unchanged stretches
+// folded away
+func (m *Model) renderDiff(old, cur storage.Entry) string {
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B"))
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555"))
+	var b strings.Builder
+	if strings.Join(old.Tags, ",") != strings.Join(cur.Tags, ",") {
+		b.WriteString(removed.Render("- tags: "+strings.Join(old.Tags, ", ")) + "\n")
+		b.WriteString(added.Render("+ tags: "+strings.Join(cur.Tags, ", ")) + "\n\n")
+	}
+	diff := storage.DiffLines(old.Content, cur.Content)
+	// keep[i] is set for lines close enough to a change to show
+	keep := make([]bool, len(diff))
+	for i, l := range diff {
+		if l.Op == ' ' {
+			continue
+		}
+		for j := max(i-diffContext, 0); j <= min(i+diffContext, len(diff)-1); j++ {
+			keep[j] = true
+		}
+	}
+	changed := false
+	for i, l := range diff {
+		if !keep[i] {
+			if i == 0 || keep[i-1] {
+				b.WriteString(m.helpStyle.Render("  ⋯") + "\n")
+			}
+			continue
+		}
+		switch l.Op {
+		case '+':
+			changed = true
+			b.WriteString(added.Render("+ "+l.Text) + "\n")
+		case '-':
+			changed = true
+			b.WriteString(removed.Render("- "+l.Text) + "\n")
+		default:
+			b.WriteString(m.normalStyle.Render("  "+l.Text) + "\n")
+		}
+	}
+	if !changed {
+		b.WriteString(m.helpStyle.Render("(same text as the current version)") + "\n")
+	}
+	return b.String()
+}
+
+// updateHistory handles keys in the history view
+func (m Model) updateHistory(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "q", "esc":
+		m.err = nil
+		m.mode = m.histReturn
+	case "j", "down":
+		if m.histCursor < len(m.histRevs)-1 {
+			m.histCursor++
+			m.showRevisionDiff()
+		}
+	case "k", "up":
+		if m.histCursor > 0 {
+			m.histCursor--
+			m.showRevisionDiff()
+		}
+	case "J":
+		m.histVP.LineDown(1)
+	case "K":
+		m.histVP.LineUp(1)
+	case "pgdown":
+		m.histVP.SetYOffset(m.histVP.YOffset + m.histVP.Height)
+	case "pgup":
+		m.histVP.SetYOffset(m.histVP.YOffset - m.histVP.Height)
+	case "r":
+		if len(m.histRevs) > 0 {
+			rev := m.histRevs[m.histCursor]
+			m.askConfirm("revert", rev.Hash, "Restore this revision?",
+				"\""+m.histEntry.Title+"\" goes back to how it was on "+rev.Updated.Format(timeLayout)+
+					".\nThe current version stays in the history.")
+		}
+	}
+	return m, nil
+}
+
+// revertEntry restores the revision with hash of the entry in the history view
+func (m Model) revertEntry(hash string) (tea.Model, tea.Cmd) {
+	var from time.Time
+	for _, r := range m.histRevs {
+		if r.Hash == hash {
+			from = r.Updated
+		}
+	}
+	saved, err := storage.RestoreRevision(m.store, m.histEntry.ID, hash)
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.index.Add(saved)
+	if saved.Title != m.histEntry.Title {
+		// keep the filename in step with the title, as edits do
+		if renamed, err := storage.RenameEntry(m.store, saved.ID); err != nil {
+			m.err = err
+		} else {
+			saved.Filename = renamed.Filename
+		}
+	}
+	if m.histReturn == ModeView {
+		m.viewing = saved
+		m.renderView(saved.Content)
+	}
+	m.openHistory(saved)
+	m.msg = "Restored the revision from " + from.Format(timeLayout)
+	return m, m.loadEntries(saved.ID)
+}
+
+// renderHistory renders the revision list above the diff of the selected one
+func (m *Model) renderHistory() string {
+	var b strings.Builder
+	b.WriteString(m.normalStyle.Render("History of \""+m.histEntry.Title+"\"") +
+		m.helpStyle.Render("  current version from "+m.histEntry.Updated.Format(timeLayout)) + "\n\n")
+	if len(m.histRevs) == 0 {
+		b.WriteString(m.helpStyle.Render("(no earlier versions yet; every save keeps the one it replaces)") + "\n\n")
+	}
+	start := min(max(m.histCursor-historyRows/2, 0), max(len(m.histRevs)-historyRows, 0))
+	for i := start; i < min(start+historyRows, len(m.histRevs)); i++ {
+		r, st := m.histRevs[i], m.histStats[i]
+		line := fmt.Sprintf("%s  %s", r.Updated.Format(timeLayout), r.Title) +
+			m.helpStyle.Render(fmt.Sprintf("  +%d -%d · %d bytes", st.added, st.removed, r.Size))
+		if i == m.histCursor {
+			b.WriteString(m.selectedStyle.Render("> ") + line + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	if len(m.histRevs) > 0 {
+		b.WriteString("\n" + m.helpStyle.Render("Changes since this revision:") + "\n")
+		b.WriteString(m.histVP.View() + "\n")
+	}
+	b.WriteString("\n" + m.helpStyle.Render("j/k: revision  J/K, pgup/pgdown: scroll diff  r: restore revision  esc: back"))
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	if m.msg != "" {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B")).Render(m.msg))
+	}
+	return b.String()
+}
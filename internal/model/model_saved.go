@@ -0,0 +1,228 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/NekoLambda/journal-tui/internal/config"
+	"github.com/NekoLambda/journal-tui/internal/query"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// startSaveSearch asks for a name for the query in the search box
+func (m *Model) startSaveSearch() {
+	q := strings.TrimSpace(m.searchTI.Value())
+	switch {
+	case q == "":
+		m.queryErr = nil
+		m.msg = "Type a query to save first"
+		return
+	case m.queryErr != nil:
+		return
+	}
+	m.msg = ""
+	m.err = nil
+	m.ti.SetValue(m.activeSaved)
+	m.ti.Placeholder = "Name..."
+	m.ti.Focus()
+	m.mode = ModeSaveSearch
+}
+
+// updateSaveSearch drives the name prompt of ModeSaveSearch
+func (m Model) updateSaveSearch(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		var cmd tea.Cmd
+		m.ti, cmd = m.ti.Update(msg)
+		return m, cmd
+	}
+	switch key.String() {
+	case "esc", "ctrl+c":
+		m.ti.Blur()
+		m.err = nil
+		m.mode = ModeSearch
+		return m, nil
+	case "enter":
+		name := strings.TrimSpace(m.ti.Value())
+		q := strings.TrimSpace(m.searchTI.Value())
+		next := m.editSettings()
+		if err := next.SetSaved(name, q); err != nil {
+			m.err = err
+			return m, nil
+		}
+		if err := next.Save(m.cfg.SettingsFile()); err != nil {
+			m.err = fmt.Errorf("saving search: %w", err)
+			return m, nil
+		}
+		m.settings = next
+		m.ti.Blur()
+		m.err = nil
+		m.activeSaved = name
+		m.msg = fmt.Sprintf("Saved search %q", name)
+		m.mode = ModeList
+		return m, m.countSaved()
+	}
+	var cmd tea.Cmd
+	m.ti, cmd = m.ti.Update(msg)
+	return m, cmd
+}
+
+// openSaved shows the saved search picker
+func (m *Model) openSaved() {
+	if len(m.settings.Saved) == 0 {
+		m.msg = "No saved searches yet; press ctrl+s while searching to save one"
+		return
+	}
+	m.msg = ""
+	m.savedCursor = min(m.savedCursor, len(m.settings.Saved)-1)
+	m.mode = ModeSaved
+}
+
+// updateSaved handles keys in the saved search picker
+func (m Model) updateSaved(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "q", "esc":
+		m.err = nil
+		m.mode = ModeList
+	case "j", "down":
+		if m.savedCursor < len(m.settings.Saved)-1 {
+			m.savedCursor++
+		}
+	case "k", "up":
+		if m.savedCursor > 0 {
+			m.savedCursor--
+		}
+	case "enter":
+		ss := m.settings.Saved[m.savedCursor]
+		m.searchTI.SetValue(ss.Query)
+		m.applyFilter(ss.Query)
+		if m.queryErr != nil {
+			m.err = fmt.Errorf("saved search %q: %w", ss.Name, m.queryErr)
+			m.searchTI.SetValue("")
+			m.applyFilter("")
+			return m, nil
+		}
+		m.activeSaved = ss.Name
+		m.cursor = 0
+		m.err = nil
+		m.mode = ModeList
+	case "d":
+		name := m.settings.Saved[m.savedCursor].Name
+		m.askConfirm("unsave", name, "Delete saved search?",
+			"\""+name+"\" is forgotten; the notes it finds are kept.")
+	}
+	return m, nil
+}
+
+// editSettings returns a copy of the settings to change, so that m.settings
+// only changes once the copy has been saved
+func (m *Model) editSettings() config.Settings {
+	next := m.settings
+	// SetSaved and RemoveSaved work in place on the slice
+	next.Saved = slices.Clone(m.settings.Saved)
+	return next
+}
+
+// removeSaved deletes the saved search called name
+func (m Model) removeSaved(name string) (tea.Model, tea.Cmd) {
+	next := m.editSettings()
+	next.RemoveSaved(name)
+	if err := next.Save(m.cfg.SettingsFile()); err != nil {
+		m.err = fmt.Errorf("saving searches: %w", err)
+		return m, nil
+	}
+	m.settings = next
+	if strings.EqualFold(m.activeSaved, name) {
+		m.activeSaved = ""
+	}
+	if len(m.settings.Saved) == 0 {
+		m.mode = ModeList
+	}
+	m.savedCursor = max(min(m.savedCursor, len(m.settings.Saved)-1), 0)
+	return m, m.countSaved()
+}
+
+// clearSearch drops the search box query and any saved search it came from
+func (m *Model) clearSearch() {
+	m.searchTI.SetValue("")
+	m.activeSaved = ""
+	m.applyFilter("")
+}
+
+// savedCountedMsg carries the counts worked out by countSaved
+type savedCountedMsg struct {
+	gen     int
+	folder  string
+	queries []string
+	counts  []int
+}
+
+// savedQueries returns the query of each saved search, in order
+func (m *Model) savedQueries() []string {
+	queries := make([]string, len(m.settings.Saved))
+	for i, ss := range m.settings.Saved {
+		queries[i] = ss.Query
+	}
+	return queries
+}
+
+// countSaved works out in the background how many entries in the folder
+// shown each saved search matches, -1 for queries that no longer parse. It
+// runs as the index, the folder or the saved searches change, not on every
+// render; until it is done the picker shows no counts.
+func (m *Model) countSaved() tea.Cmd {
+	m.savedCounts = nil
+	if len(m.settings.Saved) == 0 {
+		return nil
+	}
+	gen, folder, queries, env := m.loadGen, m.folder, m.savedQueries(), m.queryEnv()
+	// the model re-sorts its slice in place, so hand over a copy
+	scoped := append([]storage.Entry(nil), m.scoped()...)
+	return func() tea.Msg {
+		counts := make([]int, len(queries))
+		for i, q := range queries {
+			node, err := query.Parse(q)
+			if err != nil {
+				counts[i] = -1
+				continue
+			}
+			out, _ := query.Match(node, q, scoped, env)
+			counts[i] = len(out)
+		}
+		return savedCountedMsg{gen: gen, folder: folder, queries: queries, counts: counts}
+	}
+}
+
+// renderSaved renders the saved search picker with the counts of countSaved
+func (m *Model) renderSaved() string {
+	var b strings.Builder
+	b.WriteString(m.normalStyle.Render("Saved searches:") + "\n\n")
+	for i, ss := range m.settings.Saved {
+		count := "…"
+		if i < len(m.savedCounts) {
+			count = "invalid"
+			if m.savedCounts[i] >= 0 {
+				count = fmt.Sprint(m.savedCounts[i])
+			}
+		}
+		marker, style := "  ", m.normalStyle
+		if i == m.savedCursor {
+			marker, style = "> ", m.selectedStyle
+		}
+		b.WriteString(style.Render(fmt.Sprintf("%s%s (%s)", marker, ss.Name, count)) +
+			m.helpStyle.Render("  "+ss.Query) + "\n")
+	}
+	b.WriteString("\n" + m.helpStyle.Render("enter: show  d: delete  esc: back"))
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	return b.String()
+}
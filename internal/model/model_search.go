@@ -0,0 +1,183 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/NekoLambda/journal-tui/internal/query"
+	"github.com/NekoLambda/journal-tui/internal/search"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// snippetLine is the body excerpt shown under a search result
+type snippetLine struct {
+	text  string
+	spans []search.Span
+}
+
+// setHighlights prepares result snippets and highlighting for the query n;
+// nil means no query. titleHits holds fuzzy matched title offsets by id.
+func (m *Model) setHighlights(n query.Node, titleHits map[string][]int) {
+	m.snippets = map[string]snippetLine{}
+	m.titleHits = titleHits
+	m.hl = nil
+	if n != nil {
+		m.hl = search.NewHighlighter(query.Highlights(n))
+	}
+}
+
+// titleSpans returns what to highlight in e's title: the query's words, or
+// else the characters a fuzzy match picked
+func (m *Model) titleSpans(e storage.Entry) []search.Span {
+	if m.hl == nil {
+		return nil
+	}
+	if spans := m.hl.Spans(e.Title); len(spans) > 0 {
+		return spans
+	}
+	var spans []search.Span
+	for _, i := range m.titleHits[e.ID] {
+		_, size := utf8.DecodeRuneInString(e.Title[i:])
+		spans = append(spans, search.Span{Start: i, End: i + size})
+	}
+	return spans
+}
+
+// snippet returns the excerpt of e's body around its matches, reading the
+// body on first use for the current query
+func (m *Model) snippet(e storage.Entry) snippetLine {
+	if s, ok := m.snippets[e.ID]; ok {
+		return s
+	}
+	var s snippetLine
+	if body, err := m.loadContent(e.ID); err == nil {
+		body = stripHeading(body)
+		s.text, s.spans = search.Snippet(body, m.hl.Spans(body), m.snippetWidth())
+	}
+	m.snippets[e.ID] = s
+	return s
+}
+
+func (m *Model) snippetWidth() int {
+	if w := m.vp.Width - 6; w > 20 {
+		return w
+	}
+	return 20
+}
+
+// stripHeading drops the leading "# title" line, which the list already shows
+func stripHeading(body string) string {
+	if strings.HasPrefix(body, "# ") {
+		if i := strings.IndexByte(body, '\n'); i >= 0 {
+			body = body[i+1:]
+		} else {
+			body = ""
+		}
+	}
+	return strings.TrimLeft(body, " \t\r\n")
+}
+
+// renderResults renders the filtered entries that fit in rows lines, keeping
+// the cursor in view. With a query active each result gets a snippet line.
+func (m *Model) renderResults(rows int) string {
+	per := 1
+	if m.hl != nil {
+		per = 2
+	}
+	count := len(m.filtered)
+	if m.height > 0 && rows/per < count {
+		count = max(rows/per, 1)
+	}
+	start := min(max(m.cursor-count/2, 0), len(m.filtered)-count)
+	var b strings.Builder
+	for i := start; i < start+count; i++ {
+		e := m.filtered[i]
+		marker, style := "  ", m.normalStyle
+		if i == m.cursor {
+			marker, style = "> ", m.selectedStyle
+		}
+		line := style.Render(marker) + renderSpans(e.Title, 0, m.titleSpans(e), -1, style, m.matchStyle, m.matchStyle)
+		if len(e.Tags) > 0 {
+			line += " " + m.chips(e.Tags)
+		}
+		b.WriteString(line + "\n")
+		if m.hl != nil {
+			s := m.snippet(e)
+			b.WriteString(m.helpStyle.Render("    ") + renderSpans(s.text, 0, s.spans, -1, m.helpStyle, m.matchStyle, m.matchStyle) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// renderSpans renders text, which starts at offset off of the text spans
+// refer to, in base with the spans in match (or current, for span cur)
+func renderSpans(text string, off int, spans []search.Span, cur int, base, match, current lipgloss.Style) string {
+	var b strings.Builder
+	pos := 0
+	for i, s := range spans {
+		start, end := s.Start-off, s.End-off
+		if end <= pos || start >= len(text) {
+			continue
+		}
+		start, end = max(start, pos), min(end, len(text))
+		if start > pos {
+			b.WriteString(base.Render(text[pos:start]))
+		}
+		style := match
+		if i == cur {
+			style = current
+		}
+		b.WriteString(style.Render(text[start:end]))
+		pos = end
+	}
+	if pos < len(text) {
+		b.WriteString(base.Render(text[pos:]))
+	}
+	return b.String()
+}
+
+// renderView shows content in the viewport with the query's matches
+// highlighted, the current one (m.viewMatch) stronger than the rest
+func (m *Model) renderView(content string) {
+	m.viewRaw = content
+	m.viewSpans = nil
+	if m.hl != nil {
+		m.viewSpans = m.hl.Spans(content)
+	}
+	if m.viewMatch >= len(m.viewSpans) {
+		m.viewMatch = 0
+	}
+	hl := highlight{spans: m.viewSpans, cur: m.viewMatch, match: m.matchStyle, current: m.currentMatchStyle}
+	m.viewText, m.viewLines = renderSimpleMarkdown(content, m.headerStyle, m.normalStyle, hl)
+	m.vp.SetContent(m.viewText)
+}
+
+// jumpMatch moves to the next (delta 1) or previous (-1) match in the view,
+// wrapping around, and scrolls it into view
+func (m *Model) jumpMatch(delta int) {
+	n := len(m.viewSpans)
+	if n == 0 {
+		return
+	}
+	m.viewMatch = ((m.viewMatch+delta)%n + n) % n
+	m.renderView(m.viewRaw)
+	m.scrollToMatch()
+}
+
+// scrollToMatch puts the current match a third of the way down the viewport
+func (m *Model) scrollToMatch() {
+	if m.viewMatch < len(m.viewLines) {
+		m.vp.SetYOffset(max(m.viewLines[m.viewMatch]-m.vp.Height/3, 0))
+	}
+}
+
+// matchStatus is the "match 2/5" part of the view header, if there are matches
+func (m *Model) matchStatus() string {
+	if len(m.viewSpans) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" · match %d/%d (n/N)", m.viewMatch+1, len(m.viewSpans))
+}
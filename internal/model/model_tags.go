@@ -0,0 +1,440 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// maxTagSuggestions is how many completions the tag prompts offer
+const maxTagSuggestions = 5
+
+// chips renders tags as chips, or "" without tags
+func (m *Model) chips(tags []string) string {
+	parts := make([]string, len(tags))
+	for i, t := range tags {
+		parts[i] = m.tagStyle.Render(t)
+	}
+	return strings.Join(parts, " ")
+}
+
+// tagFragment splits a comma separated tag prompt into the finished part and
+// the tag being typed
+func tagFragment(value string) (done, typing string) {
+	i := strings.LastIndexByte(value, ',')
+	if i < 0 {
+		return "", strings.TrimSpace(value)
+	}
+	return value[:i+1], strings.TrimSpace(value[i+1:])
+}
+
+// suggestTags refreshes the completions for the tag prompt in m.ti: known
+// tags starting with what is being typed, most used first, leaving out those
+// already entered
+func (m *Model) suggestTags() {
+	done, typing := tagFragment(m.ti.Value())
+	entered := map[string]bool{}
+	for _, t := range parseTags(done) {
+		entered[strings.ToLower(t)] = true
+	}
+	counts := storage.CountTags(m.entries)
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+	prefix := strings.ToLower(typing)
+	m.tagSugg = m.tagSugg[:0]
+	for _, c := range counts {
+		lower := strings.ToLower(c.Name)
+		if entered[lower] || lower == prefix || !strings.HasPrefix(lower, prefix) {
+			continue
+		}
+		m.tagSugg = append(m.tagSugg, c.Name)
+		if len(m.tagSugg) == maxTagSuggestions {
+			break
+		}
+	}
+	m.tagSuggCursor = min(m.tagSuggCursor, max(len(m.tagSugg)-1, 0))
+}
+
+// updateTagInput handles the completion keys of a tag prompt, reporting
+// whether it used msg. Other keys go to the text input as usual.
+func (m *Model) updateTagInput(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "tab":
+		if len(m.tagSugg) == 0 {
+			return true
+		}
+		done, _ := tagFragment(m.ti.Value())
+		if done != "" {
+			done += " "
+		}
+		m.ti.SetValue(done + m.tagSugg[m.tagSuggCursor] + ", ")
+		m.ti.CursorEnd()
+		m.tagSuggCursor = 0
+		m.suggestTags()
+		return true
+	case "down", "ctrl+n":
+		if m.tagSuggCursor < len(m.tagSugg)-1 {
+			m.tagSuggCursor++
+		}
+		return true
+	case "up", "ctrl+p":
+		if m.tagSuggCursor > 0 {
+			m.tagSuggCursor--
+		}
+		return true
+	}
+	return false
+}
+
+// renderTagSuggestions lists the completions under a tag prompt
+func (m *Model) renderTagSuggestions() string {
+	if len(m.tagSugg) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, t := range m.tagSugg {
+		if i == m.tagSuggCursor {
+			b.WriteString(m.selectedStyle.Render("> "+t) + "\n")
+		} else {
+			b.WriteString(m.helpStyle.Render("  "+t) + "\n")
+		}
+	}
+	return b.String() + "\n"
+}
+
+// startTagEdit opens the tag editor on e, returning to the current mode after
+func (m *Model) startTagEdit(e storage.Entry) {
+	m.tagEdit = e
+	m.tagReturn = m.mode
+	m.err, m.msg = nil, ""
+	value := strings.Join(e.Tags, ", ")
+	if value != "" {
+		value += ", "
+	}
+	m.ti.SetValue(value)
+	m.ti.CursorEnd()
+	m.ti.Placeholder = "tag1, tag2..."
+	m.ti.Focus()
+	m.tagSuggCursor = 0
+	m.suggestTags()
+	m.mode = ModeTagEdit
+}
+
+// updateTagEdit drives the tag editor of ModeTagEdit
+func (m Model) updateTagEdit(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		var cmd tea.Cmd
+		m.ti, cmd = m.ti.Update(msg)
+		return m, cmd
+	}
+	switch key.String() {
+	case "esc", "ctrl+c":
+		m.ti.Blur()
+		m.err = nil
+		m.mode = m.tagReturn
+		return m, nil
+	case "enter":
+		saved, err := storage.SetTags(m.store, m.tagEdit.ID, parseTags(m.ti.Value()))
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.index.Add(saved)
+		m.ti.Blur()
+		m.err = nil
+		m.msg = "Tagged " + saved.Title
+		m.mode = m.tagReturn
+		if m.mode == ModeView {
+			m.viewing = saved
+		}
+		return m, m.loadEntries(saved.ID)
+	}
+	if m.updateTagInput(key) {
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.ti, cmd = m.ti.Update(msg)
+	m.suggestTags()
+	return m, cmd
+}
+
+// openTags shows the tag browser
+func (m *Model) openTags() {
+	if len(storage.TagTree(m.entries)) == 0 {
+		m.msg = "No tags yet; press t to tag the selected note"
+		return
+	}
+	m.msg, m.err = "", nil
+	m.tagPicked = map[string]bool{}
+	m.tagFolded = map[string]bool{}
+	m.tagCursor = 0
+	m.refreshTags()
+	m.mode = ModeTags
+}
+
+// refreshTags rebuilds the visible tag tree from the listed entries, leaving
+// out the children of folded tags
+func (m *Model) refreshTags() {
+	m.tagAll = storage.TagTree(m.entries)
+	m.tagKids = map[string]bool{}
+	for _, n := range m.tagAll {
+		if i := strings.LastIndexByte(n.Path, '/'); i >= 0 {
+			m.tagKids[strings.ToLower(n.Path[:i])] = true
+		}
+	}
+	m.tagList = nil
+	folded := ""
+	for _, n := range m.tagAll {
+		if folded != "" && storage.TagHas(n.Path, folded) {
+			continue
+		}
+		folded = ""
+		if m.tagFolded[strings.ToLower(n.Path)] {
+			folded = n.Path
+		}
+		m.tagList = append(m.tagList, n)
+	}
+	m.tagCursor = min(m.tagCursor, max(len(m.tagList)-1, 0))
+}
+
+// updateTags handles keys in the tag browser: space picks tags, enter lists
+// the entries carrying all picked tags (or the one under the cursor), and
+// r/m/d rename, merge or delete the tag under the cursor on every entry
+func (m Model) updateTags(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok || len(m.tagList) == 0 {
+		if ok && (key.String() == "q" || key.String() == "esc") {
+			m.mode = ModeList
+		}
+		return m, nil
+	}
+	cur := m.tagList[m.tagCursor]
+	switch key.String() {
+	case "q", "esc":
+		m.err = nil
+		m.mode = ModeList
+	case "j", "down":
+		if m.tagCursor < len(m.tagList)-1 {
+			m.tagCursor++
+		}
+	case "k", "up":
+		if m.tagCursor > 0 {
+			m.tagCursor--
+		}
+	case "left", "h":
+		if m.tagFolded[strings.ToLower(cur.Path)] || !m.hasChildren(m.tagCursor) {
+			// go to the parent instead
+			for i := m.tagCursor - 1; i >= 0; i-- {
+				if m.tagList[i].Depth < cur.Depth {
+					m.tagCursor = i
+					break
+				}
+			}
+			return m, nil
+		}
+		m.tagFolded[strings.ToLower(cur.Path)] = true
+		m.refreshTags()
+	case "right", "l":
+		delete(m.tagFolded, strings.ToLower(cur.Path))
+		m.refreshTags()
+	case " ", "x":
+		m.tagPicked[cur.Path] = !m.tagPicked[cur.Path]
+	case "enter":
+		var clauses []string
+		for _, t := range m.tagAll {
+			if m.tagPicked[t.Path] {
+				clauses = append(clauses, tagClause(t.Path))
+			}
+		}
+		if len(clauses) == 0 {
+			clauses = []string{tagClause(cur.Path)}
+		}
+		// a filter like any other search, so esc clears it and it survives reloads
+		q := strings.Join(clauses, " ")
+		m.searchTI.SetValue(q)
+		m.activeSaved = ""
+		m.applyFilter(q)
+		m.cursor = 0
+		m.msg = "Showing " + q + " (esc: show all)"
+		m.mode = ModeList
+	case "r", "m":
+		m.tagOp = key.String()
+		m.tagOpTag = cur.Path
+		m.err = nil
+		m.ti.SetValue("")
+		if m.tagOp == "r" {
+			m.ti.SetValue(cur.Path)
+		}
+		m.ti.CursorEnd()
+		m.ti.Placeholder = "tag..."
+		m.ti.Focus()
+		m.mode = ModeTagOp
+	case "d":
+		m.tagOp = "d"
+		m.tagOpTag = cur.Path
+		m.err = nil
+		m.mode = ModeTagOp
+	}
+	return m, nil
+}
+
+// hasChildren reports whether the visible or folded tag at i has tags below it
+func (m *Model) hasChildren(i int) bool {
+	return m.tagKids[strings.ToLower(m.tagList[i].Path)]
+}
+
+// updateTagOp drives the prompts of the tag browser's rename, merge and
+// delete; each runs as one storage transaction across all entries
+func (m Model) updateTagOp(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if m.tagOp == "d" {
+		if !ok {
+			return m, nil
+		}
+		switch key.String() {
+		case "y", "Y":
+			n, err := storage.DeleteTag(m.store, m.tagOpTag)
+			return m.finishTagOp(n, err, fmt.Sprintf("Deleted %s from %d notes", m.tagOpTag, n))
+		case "n", "N", "esc", "q":
+			m.mode = ModeTags
+		}
+		return m, nil
+	}
+	if !ok {
+		var cmd tea.Cmd
+		m.ti, cmd = m.ti.Update(msg)
+		return m, cmd
+	}
+	switch key.String() {
+	case "esc", "ctrl+c":
+		m.ti.Blur()
+		m.err = nil
+		m.mode = ModeTags
+		return m, nil
+	case "enter":
+		to := m.ti.Value()
+		if m.tagOp == "r" {
+			n, err := storage.RenameTag(m.store, m.tagOpTag, to)
+			return m.finishTagOp(n, err, fmt.Sprintf("Renamed %s to %s on %d notes", m.tagOpTag, storage.CleanTag(to), n))
+		}
+		n, err := storage.MergeTags(m.store, m.tagOpTag, to)
+		return m.finishTagOp(n, err, fmt.Sprintf("Merged %s into %s on %d notes", m.tagOpTag, storage.CleanTag(to), n))
+	}
+	var cmd tea.Cmd
+	m.ti, cmd = m.ti.Update(msg)
+	return m, cmd
+}
+
+// finishTagOp reports a bulk tag operation and reloads; the browser follows
+// the reload, as does the search index
+func (m Model) finishTagOp(n int, err error, done string) (tea.Model, tea.Cmd) {
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.ti.Blur()
+	m.err = nil
+	m.msg = done
+	m.mode = ModeTags
+	var id string
+	if m.cursor < len(m.filtered) {
+		id = m.filtered[m.cursor].ID
+	}
+	return m, m.loadEntries(id)
+}
+
+// tagClause is the query clause matching tag, which CleanTag keeps free of
+// double quotes
+func tagClause(tag string) string {
+	if strings.ContainsAny(tag, " \t()") {
+		return `tag:"` + tag + `"`
+	}
+	return "tag:" + tag
+}
+
+// renderTags renders the tag browser as a tree
+func (m *Model) renderTags() string {
+	var b strings.Builder
+	b.WriteString(m.normalStyle.Render("Tags:") + "\n\n")
+	rows := len(m.tagList)
+	if m.height > 0 {
+		rows = min(rows, max(m.height-12, 1))
+	}
+	start := min(max(m.tagCursor-rows/2, 0), len(m.tagList)-rows)
+	for i := start; i < start+rows; i++ {
+		t := m.tagList[i]
+		check := "[ ]"
+		if m.tagPicked[t.Path] {
+			check = "[x]"
+		}
+		fold := "  "
+		if m.tagFolded[strings.ToLower(t.Path)] {
+			fold = "▸ "
+		} else if m.hasChildren(i) {
+			fold = "▾ "
+		}
+		count := fmt.Sprint(t.Count)
+		if t.Own != t.Count {
+			count = fmt.Sprintf("%d (%d own)", t.Count, t.Own)
+		}
+		line := fmt.Sprintf("%s %s%s%s ", check, strings.Repeat("  ", t.Depth), fold, m.tagStyle.Render(t.Name)) + m.helpStyle.Render(count)
+		if i == m.tagCursor {
+			b.WriteString(m.selectedStyle.Render("> ") + line + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString("\n" + m.helpStyle.Render("space: pick  enter: show notes with every picked tag  ←/→: fold  r: rename  m: merge  d: delete  esc: back"))
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	if m.msg != "" {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B")).Render(m.msg))
+	}
+	return b.String()
+}
+
+// renderTagOp renders the rename, merge and delete prompts
+func (m *Model) renderTagOp() string {
+	var b strings.Builder
+	switch m.tagOp {
+	case "d":
+		n := 0
+		for _, t := range m.tagAll {
+			if strings.EqualFold(t.Path, m.tagOpTag) {
+				n = t.Count
+			}
+		}
+		b.WriteString(m.normalStyle.Render(fmt.Sprintf("Delete %s and the tags below it from %d notes? (y/n)", m.tagOpTag, n)))
+	case "r":
+		b.WriteString(m.normalStyle.Render("Rename "+m.tagOpTag+" (tags below it move along) to:") + "\n\n")
+		b.WriteString(m.inputStyle.Render(m.ti.View()) + "\n\n")
+		b.WriteString(m.helpStyle.Render("enter: rename  esc: cancel"))
+	case "m":
+		b.WriteString(m.normalStyle.Render("Merge "+m.tagOpTag+" into:") + "\n\n")
+		b.WriteString(m.inputStyle.Render(m.ti.View()) + "\n\n")
+		b.WriteString(m.helpStyle.Render("enter: merge  esc: cancel"))
+	}
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	return b.String()
+}
+
+// renderTagEdit renders the tag editor
+func (m *Model) renderTagEdit() string {
+	var b strings.Builder
+	b.WriteString(m.normalStyle.Render("Tags for \""+m.tagEdit.Title+"\" (comma separated):") + "\n\n")
+	b.WriteString(m.inputStyle.Render(m.ti.View()) + "\n\n")
+	b.WriteString(m.renderTagSuggestions())
+	b.WriteString(m.helpStyle.Render("tab: complete  ↑/↓: pick completion  enter: save  esc: cancel"))
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	return b.String()
+}
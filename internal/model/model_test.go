@@ -0,0 +1,503 @@
+package model
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/NekoLambda/journal-tui/internal/config"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// failingStore is a store whose Put always fails
+type failingStore struct {
+	storage.Store
+}
+
+func (failingStore) Put(storage.Entry) (storage.Entry, error) {
+	return storage.Entry{}, errors.New("disk full")
+}
+
+// newTestModel returns a model over store with its entries loaded
+func newTestModel(t *testing.T, store storage.Store) Model {
+	t.Helper()
+	m := New(store, config.Config{Root: t.TempDir(), Editor: "true"})
+	// a blinking cursor would keep settle waiting on its timer
+	m.ti.Cursor.SetMode(cursor.CursorStatic)
+	m.searchTI.Cursor.SetMode(cursor.CursorStatic)
+	return settle(t, m, listEntries(m.store, m.loadGen, m.progress, ""))
+}
+
+// settle runs cmd and everything it leads to, feeding the messages back to
+// m; spinner ticks are dropped so nothing waits on a timer
+func settle(t *testing.T, m Model, cmd tea.Cmd) Model {
+	t.Helper()
+	queue := []tea.Cmd{cmd}
+	for len(queue) > 0 {
+		cmd, queue = queue[0], queue[1:]
+		if cmd == nil {
+			continue
+		}
+		switch msg := cmd().(type) {
+		case tea.BatchMsg:
+			queue = append(queue, msg...)
+		case spinner.TickMsg:
+		default:
+			next, c := m.Update(msg)
+			m = next.(Model)
+			queue = append(queue, c)
+		}
+	}
+	return m
+}
+
+// send feeds msg to m and settles what it starts
+func send(t *testing.T, m Model, msg tea.Msg) Model {
+	t.Helper()
+	return settle(t, m, func() tea.Msg { return msg })
+}
+
+// press sends keys to m one at a time, settling what each one starts.
+// Named keys are given as "enter", "esc" or "ctrl+u"; anything else is typed.
+func press(t *testing.T, m Model, keys ...string) Model {
+	t.Helper()
+	for _, k := range keys {
+		var msg tea.KeyMsg
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case "ctrl+u":
+			msg = tea.KeyMsg{Type: tea.KeyCtrlU}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		}
+		next, cmd := m.Update(msg)
+		m = settle(t, next.(Model), cmd)
+	}
+	return m
+}
+
+// draftFile starts a new note titled title through the n prompt and returns
+// the model and the draft file handed to the editor
+func draftFile(t *testing.T, m Model, title, tags string) (Model, string) {
+	t.Helper()
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	m = press(t, m, "n", title, "enter")
+	if tags != "" {
+		m = press(t, m, tags)
+	}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(Model)
+	if cmd == nil {
+		t.Fatalf("no editor started: %v", m.err)
+	}
+	drafts, _ := filepath.Glob(filepath.Join(tmp, "journal-new-*.md"))
+	if len(drafts) != 1 {
+		t.Fatalf("expected one draft, got %v", drafts)
+	}
+	return m, drafts[0]
+}
+
+func TestNewEntry(t *testing.T) {
+	store := storage.NewMemStore()
+	m, path := draftFile(t, newTestModel(t, store), "Trip", "travel, summer")
+	if err := os.WriteFile(path, []byte("# Trip\n\nPacked the tent.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m = send(t, m, newEntryEditedMsg{path: path, title: "Trip", tags: []string{"travel", "summer"}})
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if len(m.filtered) != 1 || m.filtered[0].Title != "Trip" || strings.Join(m.filtered[0].Tags, ",") != "travel,summer" {
+		t.Fatalf("unexpected list %+v", m.filtered)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("draft left behind: %v", err)
+	}
+}
+
+func TestNewEntryDiscardsEmptyDraft(t *testing.T) {
+	m, path := draftFile(t, newTestModel(t, storage.NewMemStore()), "Nothing", "")
+	m = send(t, m, newEntryEditedMsg{path: path, title: "Nothing", tags: []string{}})
+	if len(m.entries) != 0 || m.msg != "Empty note discarded." {
+		t.Fatalf("expected the empty draft discarded, got %d entries, %q", len(m.entries), m.msg)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("draft left behind: %v", err)
+	}
+}
+
+func TestNewEntryKeepsDraftOnError(t *testing.T) {
+	for name, tc := range map[string]struct {
+		store     storage.Store
+		editorErr error
+	}{
+		"put fails":    {store: failingStore{storage.NewMemStore()}},
+		"editor fails": {store: storage.NewMemStore(), editorErr: errors.New("exit status 1")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, path := draftFile(t, newTestModel(t, tc.store), "Lost", "")
+			text := "# Lost\n\nWords worth keeping.\n"
+			if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			m = send(t, m, newEntryEditedMsg{path: path, title: "Lost", err: tc.editorErr})
+			if m.err == nil || !strings.Contains(m.err.Error(), path) {
+				t.Fatalf("expected an error naming %s, got %v", path, m.err)
+			}
+			if got, err := os.ReadFile(path); err != nil || string(got) != text {
+				t.Fatalf("draft lost: %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestLoadShowsUnreadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.NewFSStore(dir)
+	storage.NewEntry(store, "fine", "x")
+	if err := os.Symlink(filepath.Join(dir, "gone.md"), filepath.Join(dir, "broken.md")); err != nil {
+		t.Skip(err)
+	}
+	m := newTestModel(t, store)
+	if len(m.filtered) != 1 || m.err == nil || !strings.Contains(m.err.Error(), "broken.md") {
+		t.Errorf("got %d entries and error %v", len(m.filtered), m.err)
+	}
+}
+
+// editCopy writes text as the edited copy of the entry with id, the way
+// editEntry hands it to the editor, and returns the finished message
+func editCopy(t *testing.T, store storage.Store, id, text string) editorFinishedMsg {
+	t.Helper()
+	old, err := store.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "journal-edit.md")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return editorFinishedMsg{old: old, path: path}
+}
+
+func TestEditSaves(t *testing.T) {
+	store := storage.NewMemStore()
+	ent, _ := store.Put(storage.Entry{Title: "Draft", Content: "# Draft\n\nfirst\n"})
+	m := newTestModel(t, store)
+	msg := editCopy(t, store, ent.ID, "---\ntitle: Final\n---\n# Final\n\nsecond\n")
+	m = send(t, m, msg)
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	got, _ := store.Get(ent.ID)
+	if got.Title != "Final" || got.Content != "# Final\n\nsecond\n" || !strings.HasSuffix(got.Filename, "-final.md") {
+		t.Errorf("edit not saved: %+v", got)
+	}
+	if _, err := os.Stat(msg.path); !os.IsNotExist(err) {
+		t.Errorf("edited copy left behind: %v", err)
+	}
+}
+
+func TestEditKeepsCopyOnError(t *testing.T) {
+	good := "---\ntitle: Draft\n---\n# Draft\n\nhours of work\n"
+	for name, tc := range map[string]struct {
+		text      string
+		failPut   bool
+		editorErr error
+	}{
+		"malformed front matter": {text: "---\ntitle: Draft\ncreated: not-a-date\n---\n# Draft\n\nhours of work\n"},
+		"list item without key":  {text: "---\n- stray\n---\nhours of work\n"},
+		"put fails":              {text: good, failPut: true},
+		"editor fails":           {text: good, editorErr: errors.New("exit status 1")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mem := storage.NewMemStore()
+			ent, _ := mem.Put(storage.Entry{Title: "Draft", Content: "# Draft\n\nfirst\n"})
+			var store storage.Store = mem
+			if tc.failPut {
+				store = failingStore{mem}
+			}
+			m := newTestModel(t, store)
+			msg := editCopy(t, mem, ent.ID, tc.text)
+			msg.err = tc.editorErr
+			m = send(t, m, msg)
+			if m.err == nil || !strings.Contains(m.err.Error(), msg.path) {
+				t.Fatalf("expected an error naming %s, got %v", msg.path, m.err)
+			}
+			if got, err := os.ReadFile(msg.path); err != nil || string(got) != tc.text {
+				t.Fatalf("edited copy lost: %q, %v", got, err)
+			}
+			if got, _ := mem.Get(ent.ID); got.Content != ent.Content {
+				t.Errorf("entry changed to %q", got.Content)
+			}
+		})
+	}
+}
+
+func TestSavedCountsFollowSearch(t *testing.T) {
+	store := storage.NewMemStore()
+	storage.SaveEntryIn(store, "work", "Standup notes", "talked", nil)
+	storage.SaveEntryIn(store, "home", "Standup at home", "quiet", nil)
+	storage.SaveEntry(store, "Groceries", "milk", nil)
+	m := newTestModel(t, store)
+	// an abbreviation only the fuzzy title match finds, and a query gone bad
+	for _, ss := range [][2]string{{"standups", "stndp"}, {"broken", "(work"}} {
+		if err := m.settings.SetSaved(ss[0], ss[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m = settle(t, m, m.loadEntries(""))
+	if want := []int{2, -1}; !reflect.DeepEqual(m.savedCounts, want) {
+		t.Errorf("counts = %v, want %v", m.savedCounts, want)
+	}
+	cmd := m.setFolder("work")
+	if m.savedCounts != nil {
+		t.Errorf("counts of another folder kept: %v", m.savedCounts)
+	}
+	if m = settle(t, m, cmd); m.savedCounts[0] != 1 {
+		t.Errorf("count in work = %d, want 1", m.savedCounts[0])
+	}
+	// the counts are what picking the search lists
+	m = press(t, m, "s", "enter")
+	if m.activeSaved != "standups" || len(m.filtered) != m.savedCounts[0] {
+		t.Errorf("picked %q, listing %d", m.activeSaved, len(m.filtered))
+	}
+}
+
+func TestSavedCountsDropStaleResults(t *testing.T) {
+	store := storage.NewMemStore()
+	storage.SaveEntryIn(store, "work", "Standup", "talked", nil)
+	m := newTestModel(t, store)
+	m.settings.SetSaved("standups", "standup")
+	// counts started before a reload arrive after it
+	stale := m.countSaved()
+	storage.SaveEntryIn(store, "work", "Standup again", "talked more", nil)
+	m = settle(t, m, m.loadEntries(""))
+	m = settle(t, m, stale)
+	if want := []int{2}; !reflect.DeepEqual(m.savedCounts, want) {
+		t.Errorf("counts = %v, want %v", m.savedCounts, want)
+	}
+	fresh := m.countSaved()
+	m.settings.SetSaved("standups", "nothing-matches")
+	if m = settle(t, m, fresh); m.savedCounts != nil {
+		t.Errorf("counts of an old query taken: %v", m.savedCounts)
+	}
+}
+
+func TestDeleteSavedSearch(t *testing.T) {
+	m := newTestModel(t, storage.NewMemStore())
+	for _, name := range []string{"first", "second"} {
+		m.settings.SetSaved(name, "x")
+	}
+	m = press(t, m, "s", "d")
+	if !m.confirm.Visible || len(m.settings.Saved) != 2 {
+		t.Fatalf("deleted without asking: %v", m.settings.Saved)
+	}
+	if m = press(t, m, "n"); len(m.settings.Saved) != 2 {
+		t.Fatalf("deleted after no: %v", m.settings.Saved)
+	}
+	// a search that couldn't be deleted from config.json stays listed
+	root := m.cfg.Root
+	m.cfg.Root = ""
+	if m = press(t, m, "d", "y"); m.err == nil || len(m.settings.Saved) != 2 {
+		t.Fatalf("failed save: %v, %v", m.err, m.settings.Saved)
+	}
+	m.cfg.Root = root
+	if m = press(t, m, "d", "y"); m.err != nil || len(m.settings.Saved) != 1 || m.settings.Saved[0].Name != "second" {
+		t.Fatalf("delete: %v, %v", m.err, m.settings.Saved)
+	}
+	saved, err := config.LoadSettings(m.cfg.SettingsFile())
+	if err != nil || !reflect.DeepEqual(saved.Saved, m.settings.Saved) {
+		t.Errorf("config.json holds %v, %v", saved.Saved, err)
+	}
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	store := storage.NewMemStore()
+	ent, _ := storage.SaveEntry(store, "Old plan", "scrapped", nil)
+	m := newTestModel(t, store)
+
+	// d asks first; n keeps the note
+	m = press(t, m, "d", "n")
+	if len(m.filtered) != 1 {
+		t.Fatalf("declined delete left %d entries", len(m.filtered))
+	}
+	m = press(t, m, "d", "y")
+	if len(m.filtered) != 0 {
+		t.Fatalf("trashed note still listed: %+v", m.filtered)
+	}
+	if trash, _ := store.Trashed(); len(trash) != 1 || trash[0].ID != ent.ID {
+		t.Fatalf("trash = %+v", trash)
+	}
+
+	m = press(t, m, "D")
+	if m.mode != ModeTrash || len(m.trash) != 1 {
+		t.Fatalf("trash view: mode %v, %d notes", m.mode, len(m.trash))
+	}
+	m = press(t, m, "r")
+	if len(m.trash) != 0 || len(m.filtered) != 1 || m.filtered[0].ID != ent.ID {
+		t.Fatalf("restore: %d in trash, listing %+v", len(m.trash), m.filtered)
+	}
+
+	// purging asks too, and is for good
+	m = press(t, m, "esc", "d", "y", "D", "d", "y")
+	if len(m.trash) != 0 {
+		t.Errorf("purge left %d in the trash", len(m.trash))
+	}
+	if _, err := store.Get(ent.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("purged note still there: %v", err)
+	}
+}
+
+func TestHistoryRevert(t *testing.T) {
+	store := storage.NewMemStore()
+	ent, _ := storage.SaveEntry(store, "Plan", "first draft", nil)
+	ent, _ = store.Get(ent.ID)
+	ent.Content = "# Plan B\n\nsecond thoughts"
+	if _, err := store.Put(ent); err != nil {
+		t.Fatal(err)
+	}
+	storage.RenameEntry(store, ent.ID)
+	m := newTestModel(t, store)
+
+	m = press(t, m, "H")
+	if m.mode != ModeHistory || len(m.histRevs) != 1 {
+		t.Fatalf("history view: mode %v, %d revisions", m.mode, len(m.histRevs))
+	}
+	m = press(t, m, "r", "n")
+	if got, _ := store.Get(ent.ID); got.Title != "Plan B" {
+		t.Fatalf("declined revert changed the note to %q", got.Title)
+	}
+	m = press(t, m, "r", "y")
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	got, _ := store.Get(ent.ID)
+	if got.Title != "Plan" || got.Content != "# Plan\n\nfirst draft" || !strings.HasSuffix(got.Filename, "-plan.md") {
+		t.Errorf("reverted to %+v", got)
+	}
+	// the version reverted from stays in the history
+	if len(m.histRevs) != 2 || len(m.filtered) != 1 || m.filtered[0].Title != "Plan" {
+		t.Errorf("%d revisions, listing %+v", len(m.histRevs), m.filtered)
+	}
+}
+
+// tagsOf returns the tags of every entry in store, by title
+func tagsOf(t *testing.T, store storage.Store) map[string]string {
+	t.Helper()
+	ents, err := storage.LoadEntries(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := map[string]string{}
+	for _, e := range ents {
+		out[e.Title] = strings.Join(e.Tags, ",")
+	}
+	return out
+}
+
+// toTag moves the tag browser's cursor to the tag at path
+func toTag(t *testing.T, m Model, path string) Model {
+	t.Helper()
+	m.tagCursor = 0
+	for m.tagList[m.tagCursor].Path != path {
+		if m.tagCursor == len(m.tagList)-1 {
+			t.Fatalf("no tag %s in %+v", path, m.tagList)
+		}
+		m = press(t, m, "j")
+	}
+	return m
+}
+
+func TestTagEditRenameAndMerge(t *testing.T) {
+	store := storage.NewMemStore()
+	storage.SaveEntry(store, "Standup", "talked", []string{"work"})
+	storage.SaveEntry(store, "Interview", "went well", []string{"job"})
+	m := newTestModel(t, store)
+
+	for _, e := range m.entries {
+		if e.Title == "Standup" {
+			m.selectEntry(e.ID)
+		}
+	}
+	m = press(t, m, "t", "urgent", "enter")
+	if got := tagsOf(t, store)["Standup"]; got != "work,urgent" {
+		t.Fatalf("Standup tagged %q", got)
+	}
+
+	m = press(t, m, "T")
+	m = press(t, toTag(t, m, "job"), "r", "ctrl+u", "career", "enter")
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	m = press(t, toTag(t, m, "urgent"), "m", "work", "enter")
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	want := map[string]string{"Standup": "work", "Interview": "career"}
+	if got := tagsOf(t, store); !reflect.DeepEqual(got, want) {
+		t.Errorf("tags = %v, want %v", got, want)
+	}
+	// the browser follows the reload
+	if m.mode != ModeTags || len(m.tagList) != 2 {
+		t.Errorf("browser shows %+v", m.tagList)
+	}
+}
+
+// toFolder moves the folder pane's cursor to folder
+func toFolder(t *testing.T, m Model, folder string) Model {
+	t.Helper()
+	for i, r := range m.folderRows() {
+		if r.path == folder {
+			m.folderCursor = i
+			return m
+		}
+	}
+	t.Fatalf("no folder %s in %q", folder, m.folders)
+	return m
+}
+
+func TestMoveEntryAndFolder(t *testing.T) {
+	store := storage.NewMemStore()
+	ent, _ := storage.SaveEntry(store, "Trip", "packed", nil)
+	storage.SaveEntry(store, "Groceries", "milk", nil)
+	m := newTestModel(t, store)
+
+	m.selectEntry(ent.ID)
+	m = press(t, m, "m", "travel/2026", "enter")
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if got, _ := store.Get(ent.ID); got.Folder() != "travel/2026" {
+		t.Fatalf("moved to %s", got.Filename)
+	}
+	if want := []string{"travel", "travel/2026"}; !reflect.DeepEqual(m.folders, want) {
+		t.Errorf("folders = %q, want %q", m.folders, want)
+	}
+
+	// renaming a folder takes its notes and subfolders along
+	m = press(t, m, "f")
+	m = press(t, toFolder(t, m, "travel"), "r", "ctrl+u", "trips", "enter")
+	if m.err != nil {
+		t.Fatal(m.err)
+	}
+	if got, _ := store.Get(ent.ID); got.Folder() != "trips/2026" {
+		t.Errorf("after the folder rename the note is at %s", got.Filename)
+	}
+
+	// picking a folder narrows the list to it
+	m = press(t, toFolder(t, m, "trips/2026"), "enter")
+	if m.mode != ModeList || m.folder != "trips/2026" || len(m.filtered) != 1 || m.filtered[0].ID != ent.ID {
+		t.Errorf("folder %q lists %+v", m.folder, m.filtered)
+	}
+}
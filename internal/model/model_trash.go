@@ -0,0 +1,210 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+	"github.com/NekoLambda/journal-tui/ui/components"
+)
+
+// trashPurgedMsg reports the purge of old trash done on startup
+type trashPurgedMsg struct {
+	n   int
+	err error
+}
+
+// purgeTrash purges entries kept in the trash past the retention period
+// (none when it is 0) off the Update loop
+func purgeTrash(store storage.Store, retention time.Duration) tea.Cmd {
+	if retention <= 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		n, err := storage.PurgeTrash(store, time.Now().Add(-retention))
+		return trashPurgedMsg{n: n, err: err}
+	}
+}
+
+// askConfirm shows a yes/no modal; a "y" carries out op ("trash", "purge",
+// "empty" or "revert") on the entry or revision with id
+func (m *Model) askConfirm(op, id, title, content string) {
+	m.confirm = *components.NewModal(components.ModalConfirm, title, content)
+	m.confirm.Show()
+	m.confirmOp, m.confirmID = op, id
+}
+
+// updateConfirm answers the confirmation modal
+func (m Model) updateConfirm(key tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key.String() {
+	case "y", "Y":
+		m.confirm.Hide()
+		return m.runConfirmed()
+	case "n", "N", "esc", "q":
+		m.confirm.Hide()
+	}
+	return m, nil
+}
+
+// runConfirmed carries out the operation the user just confirmed
+func (m Model) runConfirmed() (tea.Model, tea.Cmd) {
+	m.err, m.msg = nil, ""
+	switch m.confirmOp {
+	case "trash":
+		title := m.confirmID
+		for _, e := range m.entries {
+			if e.ID == m.confirmID {
+				title = e.Title
+			}
+		}
+		if err := m.store.Trash(m.confirmID); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.index.Remove(m.confirmID)
+		m.msg = "Moved \"" + title + "\" to the trash (D: trash)"
+		return m, m.loadEntries("")
+	case "purge":
+		if err := m.store.Purge(m.confirmID); err != nil {
+			m.err = err
+		}
+		m.refreshTrash()
+	case "empty":
+		n, err := storage.EmptyTrash(m.store)
+		if err != nil {
+			m.err = err
+		} else {
+			m.msg = fmt.Sprintf("Purged %d notes", n)
+		}
+		m.refreshTrash()
+	case "revert":
+		return m.revertEntry(m.confirmID)
+	case "unsave":
+		return m.removeSaved(m.confirmID)
+	}
+	return m, nil
+}
+
+// openTrash shows the trash view
+func (m *Model) openTrash() {
+	m.err, m.msg = nil, ""
+	m.trashCursor = 0
+	m.refreshTrash()
+	m.mode = ModeTrash
+}
+
+// refreshTrash re-reads the trash, keeping the cursor in range
+func (m *Model) refreshTrash() {
+	trash, err := m.store.Trashed()
+	if err != nil {
+		m.err = err
+	}
+	m.trash = trash
+	m.trashCursor = max(min(m.trashCursor, len(m.trash)-1), 0)
+}
+
+// updateTrash handles keys in the trash view
+func (m Model) updateTrash(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "q", "esc":
+		m.err = nil
+		m.mode = ModeList
+	case "j", "down":
+		if m.trashCursor < len(m.trash)-1 {
+			m.trashCursor++
+		}
+	case "k", "up":
+		if m.trashCursor > 0 {
+			m.trashCursor--
+		}
+	case "r", "enter":
+		if len(m.trash) == 0 {
+			break
+		}
+		e, err := m.store.Restore(m.trash[m.trashCursor].ID)
+		if err != nil {
+			m.err = err
+			break
+		}
+		m.index.Add(e)
+		m.err = nil
+		m.msg = "Restored \"" + e.Title + "\" to " + e.Filename
+		m.refreshTrash()
+		return m, m.loadEntries(e.ID)
+	case "d":
+		if len(m.trash) > 0 {
+			t := m.trash[m.trashCursor]
+			m.askConfirm("purge", t.ID, "Delete for good?", "\""+t.Title+"\" will be gone for good.")
+		}
+	case "E":
+		if len(m.trash) > 0 {
+			m.askConfirm("empty", "", "Empty the trash?",
+				fmt.Sprintf("All %d notes in the trash will be gone for good.", len(m.trash)))
+		}
+	}
+	return m, nil
+}
+
+// days renders a duration in whole days
+func days(d time.Duration) string {
+	switch n := int(d.Hours() / 24); n {
+	case 0:
+		return "today"
+	case 1:
+		return "1 day"
+	default:
+		return fmt.Sprintf("%d days", n)
+	}
+}
+
+// renderTrash renders the trash view
+func (m *Model) renderTrash() string {
+	var b strings.Builder
+	retention := m.settings.TrashRetention()
+	b.WriteString(m.normalStyle.Render("Trash"))
+	if retention > 0 {
+		b.WriteString(m.helpStyle.Render("  notes are purged " + days(retention) + " after deletion"))
+	} else {
+		b.WriteString(m.helpStyle.Render("  notes are kept until purged"))
+	}
+	b.WriteString("\n\n")
+	if len(m.trash) == 0 {
+		b.WriteString(m.helpStyle.Render("(the trash is empty)") + "\n")
+	}
+	now := time.Now()
+	for i, t := range m.trash {
+		age := now.Sub(t.Deleted)
+		when := "deleted " + days(age)
+		if age >= 24*time.Hour {
+			when += " ago"
+		}
+		if left := retention - age; retention > 0 && left <= 24*time.Hour {
+			when += ", purged within a day"
+		} else if retention > 0 {
+			// round up, so a note deleted today has the whole period left
+			when += ", purged in " + days(left+24*time.Hour-1)
+		}
+		line := t.Title + m.helpStyle.Render("  "+t.Filename+" · "+when)
+		if i == m.trashCursor {
+			b.WriteString(m.selectedStyle.Render("> ") + line + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString("\n" + m.helpStyle.Render("r/enter: restore  d: delete for good  E: empty trash  esc: back"))
+	if m.err != nil {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")).Render("Error: "+m.err.Error()))
+	}
+	if m.msg != "" {
+		b.WriteString("\n\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B")).Render(m.msg))
+	}
+	return b.String()
+}
@@ -0,0 +1,326 @@
+// Package query parses the search box language into an AST:
+//
+//	tag:work -tag:draft after:2025-01-01 before:2025-06-30 title:"standup" "exact phrase" OR retro
+//
+// Words and clauses separated by spaces must all match. OR binds tighter than
+// that, so the example above finds work entries from the first half of 2025
+// titled standup that contain either the exact phrase or "retro". A leading -
+// negates a clause and parentheses group clauses.
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/search"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Node is a parsed query clause
+type Node interface {
+	String() string
+}
+
+// And matches entries matching every clause
+type And struct{ Nodes []Node }
+
+// Or matches entries matching any clause
+type Or struct{ Nodes []Node }
+
+// Not matches entries not matching its clause
+type Not struct{ Node Node }
+
+// Term is a bare word, matched against the full-text index
+type Term struct {
+	Text   string
+	Prefix bool // last word of the query while typing; matches as a prefix too
+}
+
+// Phrase is a quoted run of words that must appear in order
+type Phrase struct{ Text string }
+
+// Tag matches entries carrying the tag or one nested below it (case-insensitive)
+type Tag struct{ Name string }
+
+// Title matches entries whose title contains the text (case and accent insensitive)
+type Title struct{ Text string }
+
+// Folder matches entries in the folder or a folder below it
+type Folder struct{ Path string }
+
+// Date matches entries by creation time: after: includes the given day,
+// month or year onwards and before: everything up to the end of it
+type Date struct {
+	Before bool
+	Time   time.Time // start of the period for after:, start of the next one for before:
+	Raw    string
+}
+
+func (n And) String() string    { return "(and " + join(n.Nodes) + ")" }
+func (n Or) String() string     { return "(or " + join(n.Nodes) + ")" }
+func (n Not) String() string    { return "-" + n.Node.String() }
+func (n Phrase) String() string { return fmt.Sprintf("%q", n.Text) }
+func (n Tag) String() string    { return "tag:" + n.Name }
+func (n Title) String() string  { return fmt.Sprintf("title:%q", n.Text) }
+func (n Folder) String() string { return "folder:" + n.Path }
+
+func (n Term) String() string {
+	if n.Prefix {
+		return n.Text + "*"
+	}
+	return n.Text
+}
+
+func (n Date) String() string {
+	if n.Before {
+		return "before:" + n.Raw
+	}
+	return "after:" + n.Raw
+}
+
+func join(nodes []Node) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+// Plain reports whether n is only bare words, i.e. the query has no syntax
+// and may also be matched fuzzily against titles
+func Plain(n Node) bool {
+	switch n := n.(type) {
+	case Term:
+		return true
+	case And:
+		for _, c := range n.Nodes {
+			if _, ok := c.(Term); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Highlights returns what to highlight for matches of n: the index terms of
+// its words, phrases and title: clauses that aren't negated, and the folded
+// prefix of a word still being typed. Use with search.NewHighlighter.
+func Highlights(n Node) (terms, prefixes []string) {
+	var walk func(n Node, negated bool)
+	walk = func(n Node, negated bool) {
+		switch n := n.(type) {
+		case And:
+			for _, c := range n.Nodes {
+				walk(c, negated)
+			}
+		case Or:
+			for _, c := range n.Nodes {
+				walk(c, negated)
+			}
+		case Not:
+			walk(n.Node, !negated)
+		case Term:
+			if negated {
+				return
+			}
+			toks := search.Tokenize(n.Text)
+			for _, t := range toks {
+				terms = append(terms, t.Term)
+			}
+			if n.Prefix && len(toks) > 0 {
+				last := toks[len(toks)-1]
+				prefixes = append(prefixes, search.Fold(n.Text[last.Start:last.End]))
+			}
+		case Phrase:
+			if !negated {
+				terms = append(terms, search.Terms(n.Text)...)
+			}
+		case Title:
+			if !negated {
+				terms = append(terms, search.Terms(n.Text)...)
+			}
+		}
+	}
+	walk(n, false)
+	return terms, prefixes
+}
+
+// Error is a malformed query; Pos is the byte offset of the problem
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("column %d: %s", e.Pos+1, e.Msg) }
+
+// fields lists the known field: prefixes, for error messages
+const fields = "tag:, title:, folder:, after: or before:"
+
+// Parse parses a query. An empty query parses to an empty And, which
+// matches everything.
+func Parse(s string) (Node, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := parser{toks: toks, end: len(s)}
+	n, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokRParen {
+		return nil, &Error{t.pos, "unmatched )"}
+	}
+	return n, nil
+}
+
+type parser struct {
+	toks []token
+	i    int
+	end  int // length of the input, for errors at the end
+}
+
+func (p *parser) peek() token {
+	if p.i < len(p.toks) {
+		return p.toks[p.i]
+	}
+	return token{kind: tokEOF, pos: p.end}
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if p.i < len(p.toks) {
+		p.i++
+	}
+	return t
+}
+
+// and = or { or }
+func (p *parser) and() (Node, error) {
+	var nodes []Node
+	for {
+		switch p.peek().kind {
+		case tokEOF, tokRParen:
+			if len(nodes) == 1 {
+				return nodes[0], nil
+			}
+			return And{nodes}, nil
+		}
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+}
+
+// or = unary { "OR" unary }
+func (p *parser) or() (Node, error) {
+	if t := p.peek(); t.kind == tokOr {
+		return nil, &Error{t.pos, "OR needs something on its left"}
+	}
+	n, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{n}
+	for p.peek().kind == tokOr {
+		or := p.next()
+		switch t := p.peek(); t.kind {
+		case tokEOF, tokRParen, tokOr:
+			return nil, &Error{or.pos, "OR needs something on its right"}
+		}
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	if len(nodes) == 1 {
+		return n, nil
+	}
+	return Or{nodes}, nil
+}
+
+// unary = "-" unary | "(" and ")" | field | phrase | word
+func (p *parser) unary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNot:
+		switch next := p.peek(); next.kind {
+		case tokEOF, tokRParen, tokOr:
+			return nil, &Error{t.pos, "- needs something to exclude"}
+		}
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{n}, nil
+	case tokLParen:
+		n, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		if and, ok := n.(And); ok && len(and.Nodes) == 0 {
+			return nil, &Error{t.pos, "empty ( )"}
+		}
+		if p.next().kind != tokRParen {
+			return nil, &Error{t.pos, "unclosed ("}
+		}
+		return n, nil
+	case tokWord:
+		return Term{Text: t.text, Prefix: t.last}, nil
+	case tokPhrase:
+		return Phrase{t.text}, nil
+	case tokField:
+		return field(t)
+	}
+	return nil, &Error{t.pos, "unexpected " + t.String()}
+}
+
+// field turns name:value into its node
+func field(t token) (Node, error) {
+	valuePos := t.pos + len(t.name) + 1
+	if t.text == "" {
+		return nil, &Error{valuePos, t.name + ": needs a value"}
+	}
+	switch t.name {
+	case "tag":
+		return Tag{storage.CleanTag(t.text)}, nil
+	case "title":
+		return Title{t.text}, nil
+	case "folder":
+		return Folder{storage.CleanFolder(t.text)}, nil
+	case "after", "before":
+		before := t.name == "before"
+		tm, err := parseDate(t.text, before)
+		if err != nil {
+			return nil, &Error{valuePos, err.Error()}
+		}
+		return Date{Before: before, Time: tm, Raw: t.text}, nil
+	}
+	return nil, &Error{t.pos, fmt.Sprintf("unknown field %q (use %s)", t.name+":", fields)}
+}
+
+// parseDate reads YYYY-MM-DD, YYYY-MM or YYYY in local time. For before: the
+// end of the period is returned so that before:2025-06-30 includes that day.
+func parseDate(s string, end bool) (time.Time, error) {
+	for _, f := range []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{{"2006-01-02", 0, 0, 1}, {"2006-01", 0, 1, 0}, {"2006", 1, 0, 0}} {
+		t, err := time.ParseInLocation(f.layout, s, time.Local)
+		if err != nil {
+			continue
+		}
+		if end {
+			t = t.AddDate(f.years, f.months, f.days)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("bad date %q (use YYYY-MM-DD, YYYY-MM or YYYY)", s)
+}
@@ -0,0 +1,210 @@
+package query
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sahilm/fuzzy"
+
+	"github.com/NekoLambda/journal-tui/internal/search"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// Env is what Eval needs besides the entries themselves
+type Env struct {
+	Index *search.Index
+	// Content returns an entry's body, to check phrases against
+	Content func(id string) (string, error)
+}
+
+// Eval returns the entries matching n, best full-text score first. Entries
+// that score the same, e.g. for queries made only of filters, keep their order.
+func Eval(n Node, entries []storage.Entry, env Env) []storage.Entry {
+	ev := evaluator{entries: entries, env: env, byID: make(map[string]int, len(entries))}
+	for i, e := range entries {
+		ev.byID[e.ID] = i
+	}
+	scores := ev.eval(n)
+	idx := make([]int, 0, len(scores))
+	for i := range scores {
+		idx = append(idx, i)
+	}
+	sort.Slice(idx, func(a, b int) bool {
+		if sa, sb := scores[idx[a]], scores[idx[b]]; sa != sb {
+			return sa > sb
+		}
+		return idx[a] < idx[b]
+	})
+	out := make([]storage.Entry, len(idx))
+	for i, j := range idx {
+		out[i] = entries[j]
+	}
+	return out
+}
+
+// Match is what searching entries for input, parsed as n, finds: the entries
+// Eval matches, then for plain words those whose titles fuzzy match them,
+// like abbreviations the index can't find. titleHits holds the offsets each
+// title matched at, by id.
+func Match(n Node, input string, entries []storage.Entry, env Env) (out []storage.Entry, titleHits map[string][]int) {
+	out = Eval(n, entries, env)
+	titleHits = map[string][]int{}
+	if !Plain(n) {
+		return out, titleHits
+	}
+	added := make(map[string]bool, len(out))
+	for _, e := range out {
+		added[e.ID] = true
+	}
+	titles := make([]string, len(entries))
+	for i := range entries {
+		titles[i] = entries[i].Title
+	}
+	for _, r := range fuzzy.Find(strings.TrimSpace(input), titles) {
+		e := entries[r.Index]
+		titleHits[e.ID] = r.MatchedIndexes
+		if !added[e.ID] {
+			out = append(out, e)
+		}
+	}
+	return out, titleHits
+}
+
+// evaluator maps each node to the scores of the entries it matches, keyed by
+// position in entries
+type evaluator struct {
+	entries []storage.Entry
+	env     Env
+	byID    map[string]int
+}
+
+func (ev *evaluator) eval(n Node) map[int]float64 {
+	switch n := n.(type) {
+	case And:
+		if len(n.Nodes) == 0 {
+			return ev.filter(func(storage.Entry) bool { return true })
+		}
+		acc := ev.eval(n.Nodes[0])
+		for _, c := range n.Nodes[1:] {
+			if len(acc) == 0 {
+				break
+			}
+			next := ev.eval(c)
+			for i, s := range acc {
+				if add, ok := next[i]; ok {
+					acc[i] = s + add
+				} else {
+					delete(acc, i)
+				}
+			}
+		}
+		return acc
+	case Or:
+		acc := map[int]float64{}
+		for _, c := range n.Nodes {
+			for i, s := range ev.eval(c) {
+				acc[i] += s
+			}
+		}
+		return acc
+	case Not:
+		excluded := ev.eval(n.Node)
+		out := map[int]float64{}
+		for i := range ev.entries {
+			if _, ok := excluded[i]; !ok {
+				out[i] = 0
+			}
+		}
+		return out
+	case Term:
+		return ev.byEntry(ev.env.Index.Match(n.Text, n.Prefix))
+	case Phrase:
+		return ev.phrase(n.Text)
+	case Tag:
+		return ev.filter(func(e storage.Entry) bool {
+			for _, t := range e.Tags {
+				if storage.TagHas(t, n.Name) {
+					return true
+				}
+			}
+			return false
+		})
+	case Title:
+		want := search.Fold(n.Text)
+		return ev.filter(func(e storage.Entry) bool { return strings.Contains(search.Fold(e.Title), want) })
+	case Folder:
+		return ev.filter(func(e storage.Entry) bool { return storage.InFolder(e.Filename, n.Path) })
+	case Date:
+		return ev.filter(func(e storage.Entry) bool {
+			if n.Before {
+				return e.Created.Before(n.Time)
+			}
+			return !e.Created.Before(n.Time)
+		})
+	}
+	return map[int]float64{}
+}
+
+// filter matches the entries keep accepts, without a score
+func (ev *evaluator) filter(keep func(storage.Entry) bool) map[int]float64 {
+	out := map[int]float64{}
+	for i, e := range ev.entries {
+		if keep(e) {
+			out[i] = 0
+		}
+	}
+	return out
+}
+
+// byEntry turns index scores by id into scores by position, dropping ids
+// that aren't listed
+func (ev *evaluator) byEntry(scores map[string]float64) map[int]float64 {
+	out := make(map[int]float64, len(scores))
+	for id, s := range scores {
+		if i, ok := ev.byID[id]; ok {
+			out[i] = s
+		}
+	}
+	return out
+}
+
+// phrase narrows the entries containing every word of text down to those
+// where the words appear in order, in the title or the body
+func (ev *evaluator) phrase(text string) map[int]float64 {
+	want := search.Terms(text)
+	out := map[int]float64{}
+	if len(want) == 0 {
+		return out
+	}
+	for i, s := range ev.byEntry(ev.env.Index.Match(text, false)) {
+		e := ev.entries[i]
+		if containsRun(search.Terms(e.Title), want) {
+			out[i] = s
+			continue
+		}
+		if ev.env.Content == nil {
+			continue
+		}
+		if body, err := ev.env.Content(e.ID); err == nil && containsRun(search.Terms(body), want) {
+			out[i] = s
+		}
+	}
+	return out
+}
+
+// containsRun reports whether want occurs as a contiguous run in terms
+func containsRun(terms, want []string) bool {
+	for i := 0; i+len(want) <= len(terms); i++ {
+		match := true
+		for j, w := range want {
+			if terms[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
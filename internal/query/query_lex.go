@@ -0,0 +1,136 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokPhrase
+	tokField
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokKind
+	pos  int    // byte offset in the query
+	text string // word, phrase or field value
+	name string // field name, lowercased
+	last bool   // word runs to the end of the query
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "-"
+	case tokLParen:
+		return "("
+	case tokRParen:
+		return ")"
+	case tokPhrase:
+		return `"` + t.text + `"`
+	case tokField:
+		return t.name + ":" + t.text
+	}
+	return t.text
+}
+
+// lex splits a query into tokens
+func lex(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			toks = append(toks, token{kind: tokLParen, pos: i})
+			i++
+		case r == ')':
+			toks = append(toks, token{kind: tokRParen, pos: i})
+			i++
+		case r == '"':
+			text, end, err := quoted(s, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokPhrase, pos: i, text: text})
+			i = end
+		case r == '-' && i+1 < len(s) && negates(s[i+1:]):
+			toks = append(toks, token{kind: tokNot, pos: i})
+			i++
+		default:
+			end := i
+			for end < len(s) && !isBreak(s[end:]) {
+				_, n := utf8.DecodeRuneInString(s[end:])
+				end += n
+			}
+			chunk := s[i:end]
+			if c := strings.IndexByte(chunk, ':'); c > 0 && isIdent(chunk[:c]) {
+				t := token{kind: tokField, pos: i, name: strings.ToLower(chunk[:c]), text: chunk[c+1:]}
+				if t.text == "" && end < len(s) && s[end] == '"' {
+					text, qend, err := quoted(s, end)
+					if err != nil {
+						return nil, err
+					}
+					t.text, end = text, qend
+				}
+				toks = append(toks, t)
+			} else if chunk == "OR" {
+				toks = append(toks, token{kind: tokOr, pos: i})
+			} else {
+				toks = append(toks, token{kind: tokWord, pos: i, text: chunk, last: end == len(s)})
+			}
+			i = end
+		}
+	}
+	return toks, nil
+}
+
+// quoted reads the "..." starting at s[start], returning its contents and
+// the offset just past the closing quote
+func quoted(s string, start int) (string, int, error) {
+	end := strings.IndexByte(s[start+1:], '"')
+	if end < 0 {
+		return "", 0, &Error{start, "unterminated quote"}
+	}
+	text := s[start+1 : start+1+end]
+	if strings.TrimSpace(text) == "" {
+		return "", 0, &Error{start, "empty quotes"}
+	}
+	return text, start + end + 2, nil
+}
+
+// isBreak reports whether s starts with a character that ends a word
+func isBreak(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+}
+
+// negates reports whether a - followed by s starts a negated clause rather
+// than being a word on its own
+func negates(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return !unicode.IsSpace(r) && r != ')'
+}
+
+func isIdent(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
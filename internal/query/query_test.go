@@ -0,0 +1,182 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NekoLambda/journal-tui/internal/search"
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		"":                 "(and )",
+		"retro":            "retro*",
+		"retro ":           "retro",
+		"a b":              "(and a b*)",
+		"a OR b c":         "(and (or a b) c*)",
+		"a or b":           "(and a or b*)",
+		`"exact phrase"`:   `"exact phrase"`,
+		"-tag:draft":       "-tag:draft",
+		"tag:#Work":        "tag:Work",
+		`title:"stand up"`: `title:"stand up"`,
+		"TITLE:standup":    `title:"standup"`,
+		"folder:/work/x/":  "folder:work/x",
+		"-(a OR b) c":      "(and -(or a b) c*)",
+		"12:30 e-mail":     "(and 12:30 e-mail*)",
+		"after:2025-01-01": "after:2025-01-01",
+		"before:2025-06 x": "(and before:2025-06 x*)",
+		`tag:work -tag:draft after:2025-01-01 before:2025-06-30 title:"standup" "exact phrase" OR retro`: `(and tag:work -tag:draft after:2025-01-01 before:2025-06-30 title:"standup" (or "exact phrase" retro*))`,
+	}
+	for in, want := range cases {
+		n, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", in, err)
+			continue
+		}
+		if got := n.String(); got != want {
+			t.Errorf("Parse(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	n, _ := Parse(`running -tag:x -secret "Exact phrases" OR title:Cafés stand`)
+	terms, prefixes := Highlights(n)
+	if want := []string{"run", "exact", "phrase", "cafe", "stand"}; !reflect.DeepEqual(terms, want) {
+		t.Errorf("terms = %q, want %q", terms, want)
+	}
+	if want := []string{"stand"}; !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("prefixes = %q, want %q", prefixes, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := map[string]struct {
+		pos int
+		msg string
+	}{
+		`"open`:          {0, "unterminated quote"},
+		`a ""`:           {2, "empty quotes"},
+		"tag:":           {4, "needs a value"},
+		"mood:happy":     {0, `unknown field "mood:"`},
+		"after:jan":      {6, "bad date"},
+		"OR a":           {0, "needs something on its left"},
+		"a OR":           {2, "needs something on its right"},
+		"(a b":           {0, "unclosed ("},
+		"a b)":           {3, "unmatched )"},
+		"()":             {0, "empty ( )"},
+		"a -":            {2, ""}, // a lone trailing - is a word
+		"before:2025-13": {7, "bad date"},
+	}
+	for in, want := range cases {
+		_, err := Parse(in)
+		if want.msg == "" {
+			if err != nil {
+				t.Errorf("Parse(%q): unexpected %v", in, err)
+			}
+			continue
+		}
+		var qe *Error
+		if !errors.As(err, &qe) {
+			t.Errorf("Parse(%q) = %v, want an *Error", in, err)
+			continue
+		}
+		if qe.Pos != want.pos || !strings.Contains(qe.Msg, want.msg) {
+			t.Errorf("Parse(%q) = %v at %d, want %q at %d", in, qe.Msg, qe.Pos, want.msg, want.pos)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		return d
+	}
+	entries := []storage.Entry{
+		{ID: "1", Title: "Standup Monday", Tags: []string{"work"}, Created: day("2025-01-06 09:00"),
+			Content: "blocked on the exact phrase review"},
+		{ID: "2", Title: "Standup draft", Tags: []string{"work", "draft"}, Created: day("2025-02-03 09:00"),
+			Content: "exact phrase here too"},
+		{ID: "3", Title: "Sprint retro", Tags: []string{"Work"}, Filename: "work/retros/sprint.md", Created: day("2025-06-30 18:00"),
+			Content: "phrase, exact. what went well"},
+		{ID: "4", Title: "Standup", Tags: []string{"work"}, Filename: "work/standup.md", Created: day("2025-07-01 09:00"),
+			Content: "retro notes"},
+		{ID: "5", Title: "Café", Tags: []string{"home/garden"}, Created: day("2024-12-31 23:59"),
+			Content: "retro games"},
+	}
+	ix := search.New()
+	for _, e := range entries {
+		ix.Add(e)
+	}
+	env := Env{Index: ix, Content: func(id string) (string, error) {
+		for _, e := range entries {
+			if e.ID == id {
+				return e.Content, nil
+			}
+		}
+		return "", storage.ErrNotFound
+	}}
+	cases := map[string][]string{
+		"":                                   {"1", "2", "3", "4", "5"},
+		"tag:work":                           {"1", "2", "3", "4"},
+		"tag:work -tag:draft":                {"1", "3", "4"},
+		"tag:home":                           {"5"}, // includes nested tags
+		"tag:Home/Garden":                    {"5"},
+		"tag:hom":                            {},
+		"folder:work":                        {"3", "4"}, // includes nested folders
+		"folder:work/retros":                 {"3"},
+		"-folder:wor":                        {"1", "2", "3", "4", "5"},
+		"after:2025-01-01 before:2025-06-30": {"1", "2", "3"},
+		"before:2024":                        {"5"},
+		"after:2025-07":                      {"4"},
+		`title:"standup"`:                    {"1", "2", "4"},
+		"title:cafe":                         {"5"},
+		`"exact phrase"`:                     {"1", "2"},
+		`"exact phrase" OR retro`:            {"1", "2", "3", "4", "5"},
+		"-retro":                             {"1", "2"},
+		"retr":                               {"3", "4", "5"}, // prefix while typing
+		"retr ":                              {},
+		`tag:work -tag:draft after:2025-01-01 before:2025-06-30 title:"standup" "exact phrase" OR retro`: {"1"},
+	}
+	for q, want := range cases {
+		n, err := Parse(q)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", q, err)
+		}
+		var got []string
+		for _, e := range Eval(n, entries, env) {
+			got = append(got, e.ID)
+		}
+		sorted := append([]string{}, got...)
+		sort.Strings(sorted)
+		if !reflect.DeepEqual(sorted, want) {
+			t.Errorf("Eval(%q) = %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestEvalRanksAndKeepsOrder(t *testing.T) {
+	entries := []storage.Entry{
+		{ID: "a", Title: "Other", Content: "one mention of garden"},
+		{ID: "b", Title: "Garden", Content: "garden garden plans"},
+		{ID: "c", Title: "Unrelated", Content: "nothing"},
+	}
+	ix := search.New()
+	for _, e := range entries {
+		ix.Add(e)
+	}
+	n, _ := Parse("garden")
+	if got := Eval(n, entries, Env{Index: ix}); len(got) != 2 || got[0].ID != "b" {
+		t.Errorf("garden ranked %v", got)
+	}
+	// filters alone don't reorder
+	n, _ = Parse("-title:garden")
+	if got := Eval(n, entries, Env{Index: ix}); len(got) != 2 || got[0].ID != "a" || got[1].ID != "c" {
+		t.Errorf("filter reordered %v", got)
+	}
+}
@@ -0,0 +1,323 @@
+// Package search keeps a full-text inverted index of journal entries and
+// ranks matches with BM25 over their title, tags and body.
+package search
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// field weights; a title hit counts as three body hits (BM25F)
+const (
+	titleWeight = 3
+	tagWeight   = 2
+	bodyWeight  = 1
+)
+
+// minPrefix is the shortest partial word that is expanded as a prefix
+const minPrefix = 2
+
+// Result is an entry matching a query and its score
+type Result struct {
+	ID    string
+	Score float64
+}
+
+// posting records that a term occurs in a document
+type posting struct {
+	Doc int32   // slot in Index.docs
+	TF  float32 // field weighted term frequency
+}
+
+// docInfo describes an indexed document; a free slot has an empty ID
+type docInfo struct {
+	ID   string
+	Hash string  // storage.Entry.Hash when indexed, to spot stale documents
+	Len  float32 // field weighted length in terms
+}
+
+// Index is an inverted index of entries. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	docs     []docInfo
+	slots    map[string]int32 // id -> slot in docs
+	free     []int32
+	postings map[string][]posting
+	totalLen float64
+	vocab    []string // sorted terms for prefix lookups; nil when stale
+	// changes counts modifications; saved is its value as of the last Load or Save
+	changes, saved uint64
+}
+
+// New returns an empty index
+func New() *Index {
+	return &Index{slots: map[string]int32{}, postings: map[string][]posting{}}
+}
+
+// Len returns the number of indexed entries
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.slots)
+}
+
+// Dirty reports whether the index changed since it was loaded or saved
+func (ix *Index) Dirty() bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.changes != ix.saved
+}
+
+// Has reports whether the entry is indexed at the given content hash
+func (ix *Index) Has(id, hash string) bool {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	slot, ok := ix.slots[id]
+	return ok && ix.docs[slot].Hash == hash
+}
+
+// Add indexes e's title, tags and content, replacing any earlier version
+func (ix *Index) Add(e storage.Entry) {
+	tf := map[string]float32{}
+	var n float32
+	count := func(text string, weight float32) {
+		for _, t := range Tokenize(text) {
+			tf[t.Term] += weight
+			n += weight
+		}
+	}
+	count(e.Title, titleWeight)
+	count(strings.Join(e.Tags, " "), tagWeight)
+	count(e.Content, bodyWeight)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if slot, ok := ix.slots[e.ID]; ok {
+		ix.removeSlots(map[int32]bool{slot: true})
+	}
+	var slot int32
+	if k := len(ix.free); k > 0 {
+		slot, ix.free = ix.free[k-1], ix.free[:k-1]
+		ix.docs[slot] = docInfo{ID: e.ID, Hash: e.Hash, Len: n}
+	} else {
+		slot = int32(len(ix.docs))
+		ix.docs = append(ix.docs, docInfo{ID: e.ID, Hash: e.Hash, Len: n})
+	}
+	ix.slots[e.ID] = slot
+	ix.totalLen += float64(n)
+	for term, f := range tf {
+		if _, ok := ix.postings[term]; !ok {
+			ix.vocab = nil
+		}
+		ix.postings[term] = append(ix.postings[term], posting{Doc: slot, TF: f})
+	}
+	ix.changes++
+}
+
+// Remove drops an entry from the index
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if slot, ok := ix.slots[id]; ok {
+		ix.removeSlots(map[int32]bool{slot: true})
+	}
+}
+
+// removeSlots drops documents in a single pass over the postings
+func (ix *Index) removeSlots(slots map[int32]bool) {
+	if len(slots) == 0 {
+		return
+	}
+	for term, list := range ix.postings {
+		kept := list[:0]
+		for _, p := range list {
+			if !slots[p.Doc] {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(ix.postings, term)
+			ix.vocab = nil
+		} else {
+			ix.postings[term] = kept
+		}
+	}
+	for slot := range slots {
+		d := ix.docs[slot]
+		delete(ix.slots, d.ID)
+		ix.totalLen -= float64(d.Len)
+		ix.docs[slot] = docInfo{}
+		ix.free = append(ix.free, slot)
+	}
+	ix.changes++
+}
+
+// Sync brings the index in line with entries, as listed by s: entries that
+// are gone or whose hash changed are dropped, and new or changed ones are
+// read from s and indexed. progress, if set, is called after each entry read.
+func (ix *Index) Sync(s storage.Store, entries []storage.Entry, progress func(done, total int)) error {
+	listed := make(map[string]string, len(entries)) // id -> hash
+	for _, e := range entries {
+		listed[e.ID] = e.Hash
+	}
+	ix.mu.Lock()
+	stale := map[int32]bool{}
+	for id, slot := range ix.slots {
+		if hash, ok := listed[id]; !ok || hash != ix.docs[slot].Hash {
+			stale[slot] = true
+		}
+	}
+	ix.removeSlots(stale)
+	var todo []string
+	for _, e := range entries {
+		if _, ok := ix.slots[e.ID]; !ok {
+			todo = append(todo, e.ID)
+		}
+	}
+	ix.mu.Unlock()
+
+	// read outside the lock so searches keep working while a large journal indexes
+	for i, id := range todo {
+		full, err := s.Get(id)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		ix.Add(full)
+		if progress != nil {
+			progress(i+1, len(todo))
+		}
+	}
+	return nil
+}
+
+// Search returns the entries matching every word of q, best first. The last
+// word also matches as a prefix so results keep up while typing.
+func (ix *Index) Search(q string) []Result {
+	scores := ix.Match(q, true)
+	out := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		out = append(out, Result{ID: id, Score: score})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].ID > out[j].ID
+	})
+	return out
+}
+
+// Match scores the entries containing every word of text, by id. With
+// prefix set the last word also matches terms it is a prefix of. Text
+// without any indexable words matches nothing.
+func (ix *Index) Match(text string, prefix bool) map[string]float64 {
+	toks := Tokenize(text)
+	if len(toks) == 0 {
+		return map[string]float64{}
+	}
+	if prefix {
+		ix.ensureVocab()
+	}
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	var acc map[int32]float64
+	for i, t := range toks {
+		terms := []string{t.Term}
+		if prefix && i == len(toks)-1 {
+			terms = ix.expand(t.Term, Fold(text[t.Start:t.End]))
+		}
+		scores := ix.score(terms)
+		if acc == nil {
+			acc = scores
+			continue
+		}
+		// every word has to match
+		for slot, s := range acc {
+			if add, ok := scores[slot]; ok {
+				acc[slot] = s + add
+			} else {
+				delete(acc, slot)
+			}
+		}
+	}
+	out := make(map[string]float64, len(acc))
+	for slot, s := range acc {
+		out[ix.docs[slot].ID] = s
+	}
+	return out
+}
+
+// score returns the BM25 score of each document containing any of terms,
+// taking the best term for documents matching several
+func (ix *Index) score(terms []string) map[int32]float64 {
+	out := map[int32]float64{}
+	n := float64(len(ix.slots))
+	if n == 0 {
+		return out
+	}
+	avg := ix.totalLen / n
+	for _, term := range terms {
+		list := ix.postings[term]
+		df := float64(len(list))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range list {
+			tf := float64(p.TF)
+			norm := k1 * (1 - b + b*float64(ix.docs[p.Doc].Len)/avg)
+			if s := idf * tf * (k1 + 1) / (tf + norm); s > out[p.Doc] {
+				out[p.Doc] = s
+			}
+		}
+	}
+	return out
+}
+
+// expand returns term plus every indexed term starting with the folded,
+// unstemmed word, since a half typed word usually doesn't stem like the
+// whole one
+func (ix *Index) expand(term, word string) []string {
+	terms := []string{term}
+	if len(word) < minPrefix {
+		return terms
+	}
+	for i := sort.SearchStrings(ix.vocab, word); i < len(ix.vocab) && strings.HasPrefix(ix.vocab[i], word); i++ {
+		if ix.vocab[i] != term {
+			terms = append(terms, ix.vocab[i])
+		}
+	}
+	return terms
+}
+
+// ensureVocab rebuilds the sorted term list after terms came or went
+func (ix *Index) ensureVocab() {
+	ix.mu.RLock()
+	ok := ix.vocab != nil
+	ix.mu.RUnlock()
+	if ok {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.vocab != nil {
+		return
+	}
+	vocab := make([]string, 0, len(ix.postings))
+	for term := range ix.postings {
+		vocab = append(vocab, term)
+	}
+	sort.Strings(vocab)
+	ix.vocab = vocab
+}
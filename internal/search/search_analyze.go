@@ -0,0 +1,113 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxTermLen drops things like base64 blobs and long urls from the index
+const maxTermLen = 64
+
+// Token is a word of the analysed text: its index term and where it was found
+type Token struct {
+	Term       string // folded and stemmed
+	Start, End int    // byte offsets of the word in the original text
+}
+
+// Tokenize splits text into words (letters and digits, with apostrophes
+// inside words dropped), folds case and diacritics and stems English words
+func Tokenize(text string) []Token {
+	var toks []Token
+	var f folder
+	start := -1
+	for i := 0; i <= len(text); {
+		r, size := utf8.RuneError, 1
+		if i < len(text) {
+			r, size = utf8.DecodeRuneInString(text[i:])
+		}
+		if isWordRune(r) || start >= 0 && isApostrophe(r) && i+size < len(text) && isWordRune(nextRune(text[i+size:])) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			if term := f.term(text[start:i]); term != "" {
+				toks = append(toks, Token{Term: term, Start: start, End: i})
+			}
+			start = -1
+		}
+		i += size
+	}
+	return toks
+}
+
+// Terms returns just the index terms of text
+func Terms(text string) []string {
+	toks := Tokenize(text)
+	terms := make([]string, len(toks))
+	for i, t := range toks {
+		terms[i] = t.Term
+	}
+	return terms
+}
+
+// Fold lowercases word and strips diacritics without stemming it
+func Fold(word string) string {
+	var f folder
+	return f.fold(word)
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r))
+}
+
+func isApostrophe(r rune) bool { return r == '\'' || r == '’' }
+
+func nextRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+// folder turns words into terms; the x/text transformer is built on first
+// non-ASCII word since most notes never need it
+type folder struct {
+	t transform.Transformer
+}
+
+func (f *folder) term(word string) string {
+	w := f.fold(word)
+	if w == "" || len(w) > maxTermLen {
+		return ""
+	}
+	return Stem(w)
+}
+
+func (f *folder) fold(word string) string {
+	ascii := true
+	for i := 0; i < len(word); i++ {
+		if word[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		w := strings.ToLower(word)
+		if strings.IndexByte(w, '\'') >= 0 {
+			w = strings.ReplaceAll(w, "'", "")
+		}
+		return w
+	}
+	if f.t == nil {
+		f.t = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), cases.Fold(), norm.NFC)
+	}
+	w, _, err := transform.String(f.t, word)
+	if err != nil {
+		return strings.ToLower(word)
+	}
+	return strings.NewReplacer("'", "", "’", "").Replace(w)
+}
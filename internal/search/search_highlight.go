@@ -0,0 +1,149 @@
+package search
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Span is the byte range of a match in some text
+type Span struct{ Start, End int }
+
+// Highlighter finds the words of a text that a query matched on
+type Highlighter struct {
+	terms    map[string]bool
+	prefixes []string
+}
+
+// NewHighlighter matches words whose index term is one of terms, or whose
+// folded form starts with one of prefixes (a word still being typed).
+// Prefixes shorter than the index expands are ignored, as in Match.
+func NewHighlighter(terms, prefixes []string) *Highlighter {
+	h := &Highlighter{terms: map[string]bool{}}
+	for _, t := range terms {
+		h.terms[t] = true
+	}
+	for _, p := range prefixes {
+		if len(p) >= minPrefix {
+			h.prefixes = append(h.prefixes, p)
+		}
+	}
+	return h
+}
+
+// Empty reports whether the highlighter can't match anything
+func (h *Highlighter) Empty() bool { return len(h.terms) == 0 && len(h.prefixes) == 0 }
+
+// Spans returns the matching words of text in order
+func (h *Highlighter) Spans(text string) []Span {
+	if h.Empty() {
+		return nil
+	}
+	var spans []Span
+	for _, t := range Tokenize(text) {
+		if h.terms[t.Term] || h.prefixed(text[t.Start:t.End]) {
+			spans = append(spans, Span{t.Start, t.End})
+		}
+	}
+	return spans
+}
+
+func (h *Highlighter) prefixed(word string) bool {
+	if len(h.prefixes) == 0 {
+		return false
+	}
+	folded := Fold(word)
+	for _, p := range h.prefixes {
+		if strings.HasPrefix(folded, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Snippet cuts about width bytes out of text around the densest run of
+// spans, on word boundaries, with line breaks flattened to spaces. The
+// returned spans are relative to the snippet, which is marked with … where
+// text was cut. Without spans the start of text is returned.
+func Snippet(text string, spans []Span, width int) (string, []Span) {
+	if width <= 0 {
+		return "", nil
+	}
+	start := 0
+	if len(spans) > 0 {
+		// the span starting the window holding the most spans
+		best, count := 0, 0
+		for i := range spans {
+			n := 0
+			for j := i; j < len(spans) && spans[j].End-spans[i].Start <= width; j++ {
+				n++
+			}
+			if n > count {
+				best, count = i, n
+			}
+		}
+		// leave a little context before the first match
+		start = spans[best].Start - width/5
+	}
+	if start < 0 {
+		start = 0
+	}
+	end := start + width
+	if end >= len(text) {
+		end = len(text)
+		if start = end - width; start < 0 {
+			start = 0
+		}
+	}
+	if start > 0 {
+		// forward to the next word, but not past a match
+		limit := len(text)
+		if len(spans) > 0 {
+			limit = firstFrom(spans, start)
+		}
+		if i := strings.IndexAny(text[start:], " \t\n"); i >= 0 && start+i < limit && start+i+1 < end {
+			start += i + 1
+		}
+		for start < len(text) && !utf8.RuneStart(text[start]) {
+			start++
+		}
+	}
+	if end < len(text) {
+		if i := strings.LastIndexAny(text[start:end], " \t\n"); i > 0 {
+			end = start + i
+		}
+		for end > start && !utf8.RuneStart(text[end]) {
+			end--
+		}
+	}
+	body := []byte(strings.TrimRight(text[start:end], " \t\r\n"))
+	for i, c := range body {
+		if c == '\n' || c == '\t' || c == '\r' {
+			body[i] = ' '
+		}
+	}
+	snippet, shift := string(body), -start
+	if start > 0 {
+		snippet = "…" + snippet
+		shift += len("…")
+	}
+	if end < len(text) {
+		snippet += "…"
+	}
+	var rel []Span
+	for _, s := range spans {
+		if s.Start >= start && s.End <= start+len(body) {
+			rel = append(rel, Span{s.Start + shift, s.End + shift})
+		}
+	}
+	return snippet, rel
+}
+
+// firstFrom returns the start of the first span at or after off
+func firstFrom(spans []Span, off int) int {
+	for _, s := range spans {
+		if s.Start >= off {
+			return s.Start
+		}
+	}
+	return off
+}
@@ -0,0 +1,89 @@
+package search
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+)
+
+// indexVersion is bumped whenever tokenizing, stemming or the file layout
+// changes, so older indexes get rebuilt rather than misread
+const indexVersion = 1
+
+// indexData is the on-disk form of an Index. It is gob encoded rather than
+// JSON like the storage index since postings dominate its size.
+type indexData struct {
+	Version  int
+	Docs     []docInfo
+	Postings map[string][]posting
+}
+
+// Load replaces the index with the one saved at path. A missing, outdated or
+// unreadable file leaves the index empty; the next Sync rebuilds it.
+func (ix *Index) Load(path string) {
+	var data indexData
+	f, err := os.Open(path)
+	if err == nil {
+		err = gob.NewDecoder(f).Decode(&data)
+		f.Close()
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.docs, ix.free, ix.vocab, ix.totalLen = nil, nil, nil, 0
+	ix.slots = map[string]int32{}
+	ix.postings = map[string][]posting{}
+	ix.saved = ix.changes
+	if err != nil || data.Version != indexVersion {
+		if err == nil {
+			// outdated; overwrite it on the next Save
+			ix.changes++
+		}
+		return
+	}
+	ix.docs = data.Docs
+	ix.postings = data.Postings
+	if ix.postings == nil {
+		ix.postings = map[string][]posting{}
+	}
+	for i, d := range ix.docs {
+		if d.ID == "" {
+			ix.free = append(ix.free, int32(i))
+			continue
+		}
+		ix.slots[d.ID] = int32(i)
+		ix.totalLen += float64(d.Len)
+	}
+}
+
+// Save writes the index to path atomically
+func (ix *Index) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	ix.mu.RLock()
+	err = gob.NewEncoder(tmp).Encode(indexData{Version: indexVersion, Docs: ix.docs, Postings: ix.postings})
+	changes := ix.changes
+	ix.mu.RUnlock()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	ix.mu.Lock()
+	ix.saved = changes
+	ix.mu.Unlock()
+	return nil
+}
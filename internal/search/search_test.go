@@ -0,0 +1,319 @@
+package search
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/NekoLambda/journal-tui/internal/storage"
+)
+
+func TestStem(t *testing.T) {
+	cases := map[string]string{
+		"caresses": "caress", "ponies": "poni", "ties": "ti", "caress": "caress", "cats": "cat",
+		"feed": "feed", "agreed": "agre", "plastered": "plaster", "bled": "bled", "motoring": "motor",
+		"sing": "sing", "conflated": "conflat", "troubled": "troubl", "sized": "size", "hopping": "hop",
+		"tanned": "tan", "falling": "fall", "hissing": "hiss", "fizzed": "fizz", "failing": "fail",
+		"filing": "file", "happy": "happi", "sky": "sky", "relational": "relat", "conditional": "condit",
+		"rational": "ration", "valenci": "valenc", "digitizer": "digit", "generalization": "gener",
+		"triplicate": "triplic", "formative": "form", "electrical": "electr", "hopeful": "hope",
+		"goodness": "good", "revival": "reviv", "allowance": "allow", "inference": "infer",
+		"adjustment": "adjust", "dependent": "depend", "adoption": "adopt", "effective": "effect",
+		"probate": "probat", "rate": "rate", "cease": "ceas", "controlling": "control", "roll": "roll",
+		"running": "run", "runs": "run", "generously": "gener", "happiness": "happi",
+		"go": "go", "naïve": "naïve", "x11": "x11",
+	}
+	for in, want := range cases {
+		if got := Stem(in); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	text := "Café RÉSUMÉ, don't  Straße-running x11"
+	got := Tokenize(text)
+	want := []string{"cafe", "resum", "dont", "strass", "run", "x11"}
+	if terms := Terms(text); !reflect.DeepEqual(terms, want) {
+		t.Fatalf("Terms = %q, want %q", terms, want)
+	}
+	// offsets point into the original text
+	words := []string{"Café", "RÉSUMÉ", "don't", "Straße", "running", "x11"}
+	for i, tok := range got {
+		if text[tok.Start:tok.End] != words[i] {
+			t.Errorf("token %d spans %q, want %q", i, text[tok.Start:tok.End], words[i])
+		}
+	}
+	if Fold("Ünïcödé") != "unicode" {
+		t.Errorf("Fold = %q", Fold("Ünïcödé"))
+	}
+}
+
+func add(ix *Index, id, title, content string, tags ...string) {
+	ix.Add(storage.Entry{ID: id, Title: title, Content: content, Tags: tags, Hash: id + "-hash"})
+}
+
+func ids(rs []Result) []string {
+	out := make([]string, len(rs))
+	for i, r := range rs {
+		out[i] = r.ID
+	}
+	return out
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := New()
+	add(ix, "a", "Groceries", "milk eggs bread and a note about running shoes")
+	add(ix, "b", "Running log", "ran five km, running felt good", "running")
+	add(ix, "c", "Standup notes", "blocked on review; nothing about jogging")
+	add(ix, "d", "Retro", "we talked about the standup format and running late")
+
+	if got := ids(ix.Search("runs")); !reflect.DeepEqual(got, []string{"b", "d", "a"}) {
+		t.Errorf("runs: %v", got)
+	}
+	// every word must match
+	if got := ids(ix.Search("running standup")); !reflect.DeepEqual(got, []string{"d"}) {
+		t.Errorf("running standup: %v", got)
+	}
+	// the last word is a prefix while typing
+	if got := ids(ix.Search("stand")); !reflect.DeepEqual(got, []string{"c", "d"}) {
+		t.Errorf("stand: %v", got)
+	}
+	if got := ix.Match("stand", false); len(got) != 0 {
+		t.Errorf("exact stand matched %v", got)
+	}
+	if got := ix.Search("   ,, "); len(got) != 0 {
+		t.Errorf("blank query matched %v", got)
+	}
+}
+
+func TestAddReplacesAndRemove(t *testing.T) {
+	ix := New()
+	add(ix, "a", "First", "apples")
+	add(ix, "b", "Second", "apples and pears")
+	add(ix, "a", "First", "oranges")
+	if got := ids(ix.Search("apples")); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("after replace: %v", got)
+	}
+	ix.Remove("b")
+	if got := ix.Search("apples"); len(got) != 0 || ix.Len() != 1 {
+		t.Errorf("after remove: %v (len %d)", got, ix.Len())
+	}
+	// freed slots are reused
+	add(ix, "c", "Third", "pears")
+	if got := ids(ix.Search("pears")); !reflect.DeepEqual(got, []string{"c"}) || len(ix.docs) != 2 {
+		t.Errorf("reuse: %v, %d slots", got, len(ix.docs))
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.idx")
+	ix := New()
+	add(ix, "a", "Kept", "alpha beta")
+	add(ix, "b", "Dropped", "beta gamma")
+	ix.Remove("b")
+	if !ix.Dirty() {
+		t.Fatal("expected dirty index")
+	}
+	if err := ix.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if ix.Dirty() {
+		t.Error("still dirty after Save")
+	}
+
+	loaded := New()
+	loaded.Load(path)
+	if loaded.Dirty() || loaded.Len() != 1 || !loaded.Has("a", "a-hash") {
+		t.Fatalf("loaded %d docs, dirty %v", loaded.Len(), loaded.Dirty())
+	}
+	if !reflect.DeepEqual(loaded.Search("beta"), ix.Search("beta")) {
+		t.Errorf("scores differ after reload: %v vs %v", loaded.Search("beta"), ix.Search("beta"))
+	}
+
+	// a missing file loads empty
+	loaded.Load(filepath.Join(t.TempDir(), "missing"))
+	if loaded.Len() != 0 {
+		t.Errorf("missing file loaded %d docs", loaded.Len())
+	}
+}
+
+func TestSync(t *testing.T) {
+	s := storage.NewMemStore()
+	keep, _ := storage.NewEntry(s, "Keep", "unchanged body")
+	edit, _ := storage.NewEntry(s, "Edit", "old words")
+	gone, _ := storage.NewEntry(s, "Gone", "deleted words")
+
+	ix := New()
+	list, _ := s.List()
+	if err := ix.Sync(s, list, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ix.Len() != 3 {
+		t.Fatalf("indexed %d", ix.Len())
+	}
+
+	edit.Content = "new words"
+	s.Put(edit)
+	s.Delete(gone.ID)
+	list, _ = s.List()
+	var done, total int
+	if err := ix.Sync(s, list, func(d, n int) { done, total = d, n }); err != nil {
+		t.Fatal(err)
+	}
+	if done != 1 || total != 1 {
+		t.Errorf("progress %d/%d, want only the edited entry read", done, total)
+	}
+	if got := ids(ix.Search("words")); !reflect.DeepEqual(got, []string{edit.ID}) {
+		t.Errorf("words: %v", got)
+	}
+	if got := ix.Search("old"); len(got) != 0 {
+		t.Errorf("stale terms still indexed: %v", got)
+	}
+	for _, e := range list {
+		if e.ID == keep.ID && !ix.Has(e.ID, e.Hash) {
+			t.Errorf("unchanged entry dropped")
+		}
+	}
+}
+
+func TestHighlighter(t *testing.T) {
+	text := "Running late; the runner ran. Stand-up at nine, standups weekly."
+	h := NewHighlighter(Terms("run"), []string{"stand"})
+	var got []string
+	for _, sp := range h.Spans(text) {
+		got = append(got, text[sp.Start:sp.End])
+	}
+	want := []string{"Running", "Stand", "standups"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("spans = %q, want %q", got, want)
+	}
+	if !NewHighlighter(nil, []string{"s"}).Empty() {
+		t.Error("one letter prefixes should be ignored")
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("filler words here ", 10) + "the\nneedle is here " + strings.Repeat("more filler text ", 10)
+	spans := NewHighlighter(Terms("needle"), nil).Spans(text)
+	snip, rel := Snippet(text, spans, 60)
+	if !strings.HasPrefix(snip, "…") || !strings.HasSuffix(snip, "…") || strings.Contains(snip, "\n") {
+		t.Errorf("snippet = %q", snip)
+	}
+	if len(rel) != 1 || snip[rel[0].Start:rel[0].End] != "needle" {
+		t.Fatalf("spans %v in %q", rel, snip)
+	}
+	if n := len(snip) - 2*len("…"); n > 60 {
+		t.Errorf("snippet is %d bytes", n)
+	}
+	// no matches: the start of the text
+	if snip, rel := Snippet("short text", nil, 60); snip != "short text" || rel != nil {
+		t.Errorf("got %q %v", snip, rel)
+	}
+}
+
+// -------------------- benchmarks --------------------
+
+var benchWords = func() []string {
+	r := rand.New(rand.NewSource(1))
+	words := make([]string, 20000)
+	for i := range words {
+		var b strings.Builder
+		for n := 3 + r.Intn(7); n > 0; n-- {
+			b.WriteByte(byte('a' + r.Intn(26)))
+		}
+		words[i] = b.String()
+	}
+	return words
+}()
+
+// benchCorpus generates n entries of ~200 words drawn from a Zipf-ish distribution
+func benchCorpus(n int) []storage.Entry {
+	r := rand.New(rand.NewSource(2))
+	z := rand.NewZipf(r, 1.1, 1, uint64(len(benchWords)-1))
+	entries := make([]storage.Entry, n)
+	for i := range entries {
+		var b strings.Builder
+		for w := 0; w < 200; w++ {
+			b.WriteString(benchWords[z.Uint64()])
+			b.WriteByte(' ')
+		}
+		entries[i] = storage.Entry{
+			ID:      fmt.Sprintf("%026d", i),
+			Title:   benchWords[z.Uint64()] + " " + benchWords[z.Uint64()],
+			Tags:    []string{benchWords[r.Intn(50)]},
+			Content: b.String(),
+			Hash:    fmt.Sprint(i),
+		}
+	}
+	return entries
+}
+
+func benchIndex(b *testing.B, n int) (*Index, []storage.Entry) {
+	b.Helper()
+	entries := benchCorpus(n)
+	ix := New()
+	for _, e := range entries {
+		ix.Add(e)
+	}
+	return ix, entries
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	text := benchCorpus(1)[0].Content
+	b.SetBytes(int64(len(text)))
+	for i := 0; i < b.N; i++ {
+		Tokenize(text)
+	}
+}
+
+func BenchmarkStem(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Stem(benchWords[i%len(benchWords)] + "ing")
+	}
+}
+
+func BenchmarkBuild10k(b *testing.B) {
+	entries := benchCorpus(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ix := New()
+		for _, e := range entries {
+			ix.Add(e)
+		}
+	}
+}
+
+func BenchmarkSearch10k(b *testing.B) {
+	ix, _ := benchIndex(b, 10000)
+	queries := []string{benchWords[1], benchWords[5] + " " + benchWords[40], benchWords[300][:2], benchWords[9000]}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ix.Search(queries[i%len(queries)])
+	}
+}
+
+func BenchmarkUpdate10k(b *testing.B) {
+	ix, entries := benchIndex(b, 10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e := entries[i%len(entries)]
+		e.Hash = fmt.Sprint("v", i)
+		ix.Add(e)
+	}
+}
+
+func BenchmarkSaveLoad10k(b *testing.B) {
+	ix, _ := benchIndex(b, 10000)
+	path := filepath.Join(b.TempDir(), "search.idx")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ix.Save(path); err != nil {
+			b.Fatal(err)
+		}
+		New().Load(path)
+	}
+}
@@ -1,65 +1,98 @@
 package storage
 
 import (
-	"archive/zip"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"io/fs"
-	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 	"unicode"
 )
 
 type Entry struct {
+	ID       string // stable ULID kept in front matter; survives renames
 	Title    string
-	Filename string
-	Content  string // Populate when loading
-	ModTime  time.Time
+	Filename string    // path below the journal root, slash separated; the folder part is its notebook
+	Content  string    // markdown body without front matter; populated when loading
+	ModTime  time.Time // filesystem mtime; informational only
+	Created  time.Time // from front matter, else the filename timestamp
+	Updated  time.Time // bumped on every save
 	Tags     []string
+	Fields   map[string]any // extra front matter fields (string or []string values)
+	Size     int64          // size of the file on disk
+	Hash     string         // sha256 of the file on disk
+	Snippet  string         // start of the body, for previews
 }
 
-const (
-	dataDir  = "data"
-	metaFile = "metadata.json"
+// Store is a journal backend. Entries are addressed by their stable ID; the
+// filename is only where the entry happens to live.
+type Store interface {
+	// List returns every entry, newest first. Content is left empty so large
+	// journals stay cheap to list; use Get or LoadEntryContent for the body.
+	List() ([]Entry, error)
+	// Get returns a single entry including its content
+	Get(id string) (Entry, error)
+	// Put writes an entry; an unknown or empty ID creates a new one named after
+	// the title. Saving bumps Updated, except on new entries that have one.
+	Put(e Entry) (Entry, error)
+	// Delete removes the entry, its tags and its history for good; see Trash
+	Delete(id string) error
+	// Trash moves an entry to the trash, where it is kept until purged
+	Trash(id string) error
+	// Trashed lists the entries in the trash, most recently deleted first
+	Trashed() ([]Trashed, error)
+	// Restore moves an entry out of the trash to where it was, under a free
+	// name if that is taken by now
+	Restore(id string) (Entry, error)
+	// Purge deletes an entry in the trash, and its history, for good
+	Purge(id string) error
+	// History lists the revisions an entry went through, newest first. Every
+	// save that changes an entry keeps the version it replaces.
+	History(id string) ([]Revision, error)
+	// Revision returns an entry as it was in the revision with hash
+	Revision(id, hash string) (Entry, error)
+	// PruneHistory drops all but the newest keep revisions of each entry (no
+	// limit when keep <= 0) and those written before the given time (none
+	// when it is zero), returning how many went
+	PruneHistory(keep int, before time.Time) (int, error)
+	// Rename moves an entry to a new filename, keeping its ID and tags
+	Rename(id, newName string) (Entry, error)
+	// Tags returns the tags of every entry keyed by ID
+	Tags() (map[string][]string, error)
+	// Folders returns every folder (notebook), empty ones included, sorted
+	Folders() ([]string, error)
+	// MakeFolder creates a folder and any missing parents
+	MakeFolder(folder string) error
+	// RenameFolder moves a folder, and the entries and folders in it, to a new path
+	RenameFolder(oldPath, newPath string) error
+	// Retag replaces the tags of every entry with rewrite(tags) in one
+	// transaction and returns the number of entries changed
+	Retag(rewrite func(tags []string) []string) (int, error)
+}
+
+var (
+	ErrNotFound  = errors.New("entry not found")
+	ErrExists    = errors.New("entry already exists")
+	ErrInvalidID = errors.New("invalid entry id")
+	ErrNoFolder  = errors.New("folder not found")
 )
 
-// EnsureDataDir makes sure data dir exists
-func EnsureDataDir() error {
-	return os.MkdirAll(dataDir, 0o755)
+// UnreadableError reports the files a listing left out because reading them
+// failed; the entries listed with it are all the others
+type UnreadableError struct {
+	Errs []error // one per file
 }
 
-// metadata is a simple map: filename -> tags
-func loadMetadata() (map[string][]string, error) {
-	mp := map[string][]string{}
-	path := filepath.Join(dataDir, metaFile)
-	f, err := os.Open(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return mp, nil
-		}
-		return nil, err
-	}
-	defer f.Close()
-	if err := json.NewDecoder(f).Decode(&mp); err != nil {
-		return nil, err
+func (e *UnreadableError) Error() string {
+	if len(e.Errs) == 1 {
+		return "skipped a note: " + e.Errs[0].Error()
 	}
-	return mp, nil
+	return fmt.Sprintf("skipped %d notes: %v (and %d more)", len(e.Errs), e.Errs[0], len(e.Errs)-1)
 }
 
-func saveMetadata(mp map[string][]string) error {
-	path := filepath.Join(dataDir, metaFile)
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	return enc.Encode(mp)
-}
+func (e *UnreadableError) Unwrap() []error { return e.Errs }
 
 // sanitize a string to slug
 func slugify(s string) string {
@@ -80,253 +113,296 @@ func slugify(s string) string {
 	return out
 }
 
-// SaveEntry writes a markdown file named with timestamp + slug and returns Entry
-// now accepts tags
-func SaveEntry(title string, content string, tags []string) (Entry, error) {
-	if err := EnsureDataDir(); err != nil {
-		return Entry{}, err
-	}
-	ts := time.Now().Format("20060102-150405")
-	filename := fmt.Sprintf("%s-%s.md", ts, slugify(title))
-	path := filepath.Join(dataDir, filename)
+const stampLayout = "20060102-150405"
 
-	f, err := os.Create(path)
-	if err != nil {
-		return Entry{}, err
+// entryFilename builds the timestamp + slug filename for a new entry
+func entryFilename(title string, t time.Time) string {
+	return fmt.Sprintf("%s-%s.md", t.Format(stampLayout), slugify(title))
+}
+
+// EntryPath is where a new entry titled title, created at t, goes in folder
+func EntryPath(folder, title string, t time.Time) string {
+	return path.Join(folder, entryFilename(title, t))
+}
+
+// Folder returns the folder holding the entry, "" for the journal root
+func (e Entry) Folder() string {
+	if dir := path.Dir(e.Filename); dir != "." {
+		return dir
 	}
-	defer f.Close()
+	return ""
+}
 
-	// write a simple markdown: title + body
-	_, err = f.WriteString("# " + title + "\n\n" + content)
-	if err != nil {
-		return Entry{}, err
+// CleanFolder normalizes a folder path as typed: slash separated, no blank
+// parts and no leading or trailing slash. The root is "".
+func CleanFolder(folder string) string {
+	parts := strings.Split(filepath.ToSlash(folder), "/")
+	out := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" && p != "." {
+			out = append(out, p)
+		}
 	}
-	fi, _ := f.Stat()
+	return strings.Join(out, "/")
+}
 
-	// update metadata
-	mp, err := loadMetadata()
-	if err != nil {
-		return Entry{}, err
+// InFolder reports whether filename is in folder or a folder below it; every
+// entry is in the root folder ""
+func InFolder(filename, folder string) bool {
+	if folder == "" {
+		return true
 	}
-	if tags == nil {
-		tags = []string{}
+	dir := path.Dir(filename)
+	return dir == folder || strings.HasPrefix(dir, folder+"/")
+}
+
+// filenameStamp returns the timestamp prefix of an entry filename, if it has one
+func filenameStamp(filename string) (string, bool) {
+	if len(filename) < len(stampLayout) {
+		return "", false
 	}
-	mp[filename] = tags
-	if err := saveMetadata(mp); err != nil {
-		// metadata is important
-		return Entry{}, err
+	stamp := filename[:len(stampLayout)]
+	if _, err := time.ParseInLocation(stampLayout, stamp, time.Local); err != nil {
+		return "", false
 	}
-
-	return Entry{Title: title, Filename: filename, Content: content, ModTime: fi.ModTime(), Tags: tags}, nil
+	return stamp, true
 }
 
-// LoadEntries lists markdown files and returns entries with title (from file first line if present)
-func LoadEntries() ([]Entry, error) {
-	if err := EnsureDataDir(); err != nil {
-		return nil, err
+// FilenameTime returns the creation time in the timestamp prefix of an
+// entry's file name, if it has one
+func FilenameTime(filename string) (time.Time, bool) {
+	stamp, ok := filenameStamp(path.Base(filename))
+	if !ok {
+		return time.Time{}, false
 	}
-	entries := []Entry{}
+	t, err := time.ParseInLocation(stampLayout, stamp, time.Local)
+	return t, err == nil
+}
 
-	mp, err := loadMetadata()
-	if err != nil {
-		return nil, err
+// titleFromContent returns the first heading of content, or fallback
+func titleFromContent(content, fallback string) string {
+	lines := strings.SplitN(content, "\n", 2)
+	if len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[0]), "# ") {
+		return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[0]), "# "))
 	}
+	return fallback
+}
 
-	err = filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		// skip metadata
-		if filepath.Base(path) == metaFile {
-			return nil
-		}
-		if filepath.Ext(path) != ".md" {
-			return nil
-		}
-		// skip tmp folder files
-		if strings.Contains(path, string(filepath.Separator)+"tmp"+string(filepath.Separator)) {
-			return nil
-		}
-		bytes, err := os.ReadFile(path)
-		if err != nil {
-			return nil
-		}
-		content := string(bytes)
-
-		// determine title from first heading or filename
-		var titleStr string
-		lines := strings.SplitN(content, "\n", 2)
-		if len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[0]), "# ") {
-			titleStr = strings.TrimSpace(strings.TrimPrefix(lines[0], "# "))
-		} else {
-			titleStr = filepath.Base(path)
-		}
-
-		fi, _ := os.Stat(path)
-		filename := filepath.Base(path)
-		tags := mp[filename]
-		entries = append(entries, Entry{
-			Title:    titleStr,
-			Filename: filename,
-			Content:  content,
-			ModTime:  fi.ModTime(),
-			Tags:     tags,
-		})
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	// sort by ModTime descending
-	for i := 0; i < len(entries)-1; i++ {
-		for j := i + 1; j < len(entries); j++ {
-			if entries[j].ModTime.After(entries[i].ModTime) {
-				entries[i], entries[j] = entries[j], entries[i]
-			}
-		}
+// UniqueFilename appends -2, -3, ... to name until taken reports it is free,
+// the way stores name new entries
+func UniqueFilename(name string, taken func(string) bool) string {
+	base := strings.TrimSuffix(name, ".md")
+	for i := 2; taken(name); i++ {
+		name = fmt.Sprintf("%s-%d.md", base, i)
 	}
-	return entries, nil
+	return name
 }
 
-// LoadEntryContent reads full markdown content (returns raw string)
-func LoadEntryContent(e Entry) (string, error) {
-	path := filepath.Join(dataDir, e.Filename)
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		return "", err
+// validName rejects entry paths that would escape the journal directory or
+// land in a hidden folder (where the journal keeps its own files)
+func validName(name string) error {
+	if name == "" || path.Clean(name) != name || path.IsAbs(name) || strings.Contains(name, "\\") {
+		return fmt.Errorf("invalid entry name %q", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." || part == "." {
+			return fmt.Errorf("invalid entry name %q", name)
+		}
+	}
+	if dir := path.Dir(name); dir != "." && validFolder(dir) != nil {
+		return fmt.Errorf("invalid entry name %q", name)
 	}
-	return string(bytes), nil
+	return nil
 }
 
-// DeleteEntry removes the entry file and updates metadata
-func DeleteEntry(e Entry) error {
-	path := filepath.Join(dataDir, e.Filename)
-	if err := os.Remove(path); err != nil {
-		return err
+// validFolder rejects folder paths that aren't clean relative paths, or that
+// are hidden
+func validFolder(folder string) error {
+	if folder == "" || CleanFolder(folder) != folder || path.Clean(folder) != folder || strings.Contains(folder, "\\") {
+		return fmt.Errorf("invalid folder %q", folder)
+	}
+	for _, part := range strings.Split(folder, "/") {
+		if part == ".." || strings.HasPrefix(part, ".") {
+			return fmt.Errorf("invalid folder %q", folder)
+		}
 	}
-	mp, _ := loadMetadata()
-	delete(mp, e.Filename)
-	_ = saveMetadata(mp)
 	return nil
 }
 
-// NewEntry creates a new entry with the given title and content
-func NewEntry(title string, content string) (Entry, error) {
-	if err := EnsureDataDir(); err != nil {
-		return Entry{}, err
-	}
+// SortKey selects the timestamp entries are ordered by
+type SortKey int
 
-	ts := time.Now().Format("20060102-150405")
-	filename := fmt.Sprintf("%s-%s.md", ts, slugify(title))
-	path := filepath.Join(dataDir, filename)
+const (
+	SortCreated SortKey = iota
+	SortUpdated
+)
 
-	f, err := os.Create(path)
-	if err != nil {
-		return Entry{}, err
+func (k SortKey) String() string {
+	if k == SortUpdated {
+		return "updated"
 	}
-	defer f.Close()
+	return "created"
+}
 
-	// Write markdown with title and content
-	_, err = f.WriteString("# " + title + "\n\n" + content)
-	if err != nil {
-		return Entry{}, err
+// SortEntries orders entries newest first by the given timestamp
+func SortEntries(entries []Entry, by SortKey) {
+	// sort a small permutation instead of swapping whole entries around
+	keys := make([]int64, len(entries))
+	for i := range entries {
+		if by == SortUpdated {
+			keys[i] = entries[i].Updated.UnixNano()
+		} else {
+			keys[i] = entries[i].Created.UnixNano()
+		}
 	}
-
-	fi, _ := f.Stat()
-	return Entry{
-		Title:    title,
-		Filename: filename,
-		Content:  content,
-		ModTime:  fi.ModTime(),
-		Tags:     []string{},
-	}, nil
+	perm := make([]int, len(entries))
+	for i := range perm {
+		perm[i] = i
+	}
+	sort.Slice(perm, func(a, b int) bool {
+		i, j := perm[a], perm[b]
+		if keys[i] != keys[j] {
+			return keys[i] > keys[j]
+		}
+		// deterministic order for entries created in the same second
+		return entries[i].Filename > entries[j].Filename
+	})
+	sorted := make([]Entry, len(entries))
+	for i, p := range perm {
+		sorted[i] = entries[p]
+	}
+	copy(entries, sorted)
 }
 
-// ExportEntry exports a single entry to exports directory
-func ExportEntry(path string) (string, error) {
-	if err := os.MkdirAll("exports", 0755); err != nil {
-		return "", fmt.Errorf("failed to create exports dir: %w", err)
+// fillTimes defaults missing timestamps so entries never depend on mtime
+// for ordering: created falls back to the filename timestamp (then mtime for
+// files we didn't name), updated to created
+func fillTimes(e *Entry) {
+	if e.Created.IsZero() {
+		if stamp, ok := filenameStamp(e.Filename); ok {
+			e.Created, _ = time.ParseInLocation(stampLayout, stamp, time.Local)
+		} else {
+			e.Created = e.ModTime
+		}
 	}
-
-	// Generate export filename with timestamp
-	exportPath := filepath.Join("exports",
-		fmt.Sprintf("journal-export-%s.zip",
-			time.Now().Format("20060102-150405")))
-
-	out, err := os.Create(exportPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create export file: %w", err)
+	if e.Updated.IsZero() {
+		e.Updated = e.Created
 	}
-	defer out.Close()
+}
 
-	// Copy the entry
-	src, err := os.Open(path)
-	if err != nil {
-		return "", fmt.Errorf("failed to open entry: %w", err)
+// SaveEntry creates a markdown entry (title heading + body) with tags in s
+func SaveEntry(s Store, title string, content string, tags []string) (Entry, error) {
+	if tags == nil {
+		tags = []string{}
 	}
-	defer src.Close()
+	return s.Put(Entry{Title: title, Content: "# " + title + "\n\n" + content, Tags: tags})
+}
 
-	if _, err := io.Copy(out, src); err != nil {
-		return "", fmt.Errorf("failed to copy entry: %w", err)
-	}
+// NewEntry creates a new entry with the given title and content
+func NewEntry(s Store, title string, content string) (Entry, error) {
+	return SaveEntry(s, title, content, nil)
+}
 
-	return exportPath, nil
+// LoadEntries lists every entry in s, newest first
+func LoadEntries(s Store) ([]Entry, error) {
+	return s.List()
 }
 
-// ExportAll zips every .md file in data/ into exports/<timestamp>.zip
-func ExportAll() (string, error) {
-	if err := EnsureDataDir(); err != nil {
-		return "", err
-	}
-	exportDir := "exports"
-	if err := os.MkdirAll(exportDir, 0o755); err != nil {
-		return "", err
+// ProgressLister is implemented by stores that can report how far a List got,
+// and which files it had to leave out
+type ProgressLister interface {
+	ListProgress(progress func(scanned int)) ([]Entry, error)
+}
+
+// LoadEntriesProgress is LoadEntries, reporting the number of entries scanned
+// so far when the store supports it. progress may be called from the
+// listing goroutine at any time before LoadEntriesProgress returns. Files
+// that couldn't be read come back as an *UnreadableError next to the rest.
+func LoadEntriesProgress(s Store, progress func(scanned int)) ([]Entry, error) {
+	if pl, ok := s.(ProgressLister); ok {
+		return pl.ListProgress(progress)
 	}
-	ts := time.Now().Format("20060102-150405")
-	zipPath := filepath.Join(exportDir, fmt.Sprintf("journal-export-%s.zip", ts))
+	return s.List()
+}
 
-	zf, err := os.Create(zipPath)
+// LoadEntryContent reads full markdown content (returns raw string)
+func LoadEntryContent(s Store, e Entry) (string, error) {
+	full, err := s.Get(e.ID)
 	if err != nil {
 		return "", err
 	}
-	defer zf.Close()
-
-	zw := zip.NewWriter(zf)
-	defer zw.Close()
+	return full.Content, nil
+}
 
-	err = filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if filepath.Ext(path) != ".md" {
-			return nil
-		}
-		if filepath.Base(path) == metaFile {
-			return nil
+// RenameEntry renames an entry's file to match its current title. The
+// timestamp prefix of the original filename is kept (or derived from the
+// creation time for files without one) so entries stay in creation order, and
+// the front matter moves with the file. A numeric suffix avoids clobbering an
+// existing entry. Returns the renamed Entry.
+func RenameEntry(s Store, id string) (Entry, error) {
+	e, err := s.Get(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	filename := e.Filename
+	stamp, ok := filenameStamp(path.Base(filename))
+	if !ok {
+		created := e.Created
+		if created.IsZero() {
+			created = e.ModTime
 		}
-		rel := filepath.Base(path)
-		f, err := os.Open(path)
-		if err != nil {
-			return err
+		stamp = created.Format(stampLayout)
+	}
+	base := path.Join(e.Folder(), stamp+"-"+slugify(e.Title))
+	newName := base + ".md"
+	for i := 2; ; i++ {
+		if newName == filename {
+			return e, nil
 		}
-		defer f.Close()
-		w, err := zw.Create(rel)
-		if err != nil {
-			return err
+		moved, err := s.Rename(id, newName)
+		if !errors.Is(err, ErrExists) {
+			return moved, err
 		}
-		_, err = io.Copy(w, f)
-		return err
+		newName = fmt.Sprintf("%s-%d.md", base, i)
+	}
+}
+
+// SaveEntryIn is SaveEntry for an entry in folder
+func SaveEntryIn(s Store, folder, title string, content string, tags []string) (Entry, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	return s.Put(Entry{
+		Title:    title,
+		Filename: EntryPath(CleanFolder(folder), title, time.Now()),
+		Content:  "# " + title + "\n\n" + content,
+		Tags:     tags,
 	})
+}
+
+// MoveEntry moves an entry into folder (created if needed), keeping its file
+// name unless that is taken there
+func MoveEntry(s Store, id, folder string) (Entry, error) {
+	e, err := s.Get(id)
 	if err != nil {
-		return "", err
+		return Entry{}, err
+	}
+	folder = CleanFolder(folder)
+	if e.Folder() == folder {
+		return e, nil
+	}
+	base := path.Join(folder, strings.TrimSuffix(path.Base(e.Filename), ".md"))
+	newName := base + ".md"
+	for i := 2; ; i++ {
+		moved, err := s.Rename(id, newName)
+		if !errors.Is(err, ErrExists) {
+			return moved, err
+		}
+		newName = fmt.Sprintf("%s-%d.md", base, i)
 	}
-	return zipPath, nil
 }
 
-// EditEntry opens the entry file in $EDITOR
+// DeleteEntry moves the entry to the trash of s
+func DeleteEntry(s Store, e Entry) error {
+	return s.Trash(e.ID)
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const lockFile = ".lock"
+
+// how long writers wait for another process to release the journal lock
+var lockTimeout = 5 * time.Second
+
+var ErrLocked = errors.New("journal is locked by another process")
+
+// atomicFile is written next to its destination and renamed into place on Commit,
+// so readers only ever see the old or the new contents
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, path: path}, nil
+}
+
+// Commit flushes the temp file to disk and renames it over the destination
+func (f *atomicFile) Commit(perm os.FileMode) error {
+	if err := f.stage(perm); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(f.path))
+}
+
+// stage flushes and closes the temp file, leaving it ready to be renamed over
+// the destination; on error it is removed
+func (f *atomicFile) stage(perm os.FileMode) error {
+	if err := f.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// Abort discards the temp file
+func (f *atomicFile) Abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// writeFileAtomic is os.WriteFile via temp file, fsync and rename
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit(perm)
+}
+
+// syncDir makes a rename durable; not every platform can fsync a directory
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) && !errors.Is(err, errSyncUnsupported) {
+		return err
+	}
+	return nil
+}
+
+// lock takes the advisory lock on the journal directory, waiting up to
+// lockTimeout for other writers. The returned func releases it.
+func (s *FSStore) lock() (func(), error) {
+	if err := s.EnsureDataDir(); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		unlock, err := tryLock(filepath.Join(s.dir, lockFile))
+		if err == nil {
+			// finish a transaction that a crashed writer committed but didn't apply
+			if err := s.recoverTx(); err != nil {
+				unlock()
+				return nil, err
+			}
+			return unlock, nil
+		}
+		if !errors.Is(err, ErrLocked) || time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
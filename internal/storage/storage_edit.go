@@ -1,21 +1,89 @@
 package storage
 
 import (
+	"errors"
 	"os"
 	"os/exec"
+	"strings"
 )
 
-// EditEntry opens the entry file in $EDITOR
-func EditEntry(path string) error {
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
+// EditorCmd builds the editor command for path without running it, so callers
+// such as the TUI can hand the terminal over with tea.ExecProcess. editor
+// overrides $VISUAL and $EDITOR; any of them may carry arguments, e.g.
+// "code --wait".
+func EditorCmd(editor, path string) (*exec.Cmd, error) {
+	for _, v := range []string{editor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if strings.TrimSpace(v) != "" {
+			editor = v
+			break
+		}
+	}
+	if strings.TrimSpace(editor) == "" {
 		editor = "nano" // Default to nano
 	}
+	args, err := splitCommand(editor)
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(args[0], append(args[1:], path)...), nil
+}
 
-	cmd := exec.Command(editor, path)
+// EditEntry opens the entry file in the editor and waits for it to exit
+func EditEntry(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	cmd, err := EditorCmd("", path)
+	if err != nil {
+		return err
+	}
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	return cmd.Run()
 }
+
+// splitCommand splits a command line on spaces, honouring quotes and backslashes
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	var quote rune
+	inArg, escaped := false, false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("editor command has an unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty editor command")
+	}
+	return args, nil
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Folders (notebooks) are plain directories below the journal root. Hidden
+// directories and tmp/ are the journal's own and never hold entries.
+
+// skipDir reports whether a directory named name is left out of the journal
+func skipDir(name string) bool {
+	return name == "tmp" || strings.HasPrefix(name, ".")
+}
+
+// Folders lists every folder below the journal root, sorted
+func (s *FSStore) Folders() ([]string, error) {
+	if err := s.EnsureDataDir(); err != nil {
+		return nil, err
+	}
+	var out []string
+	err := filepath.WalkDir(s.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || p == s.dir {
+			return nil
+		}
+		if skipDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		out = append(out, s.rel(p))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// MakeFolder creates folder and any missing parents
+func (s *FSStore) MakeFolder(folder string) error {
+	if err := validFolder(folder); err != nil {
+		return err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return os.MkdirAll(s.abs(folder), 0o755)
+}
+
+// RenameFolder moves a folder with everything in it. Entries keep their ids,
+// so only their filenames change.
+func (s *FSStore) RenameFolder(oldPath, newPath string) error {
+	if err := checkFolderMove(oldPath, newPath); err != nil {
+		return err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if fi, err := os.Stat(s.abs(oldPath)); err != nil || !fi.IsDir() {
+		return fmt.Errorf("%q: %w", oldPath, ErrNoFolder)
+	}
+	if _, err := os.Lstat(s.abs(newPath)); err == nil {
+		return fmt.Errorf("%q: %w", newPath, ErrExists)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.abs(newPath)), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(s.abs(oldPath), s.abs(newPath)); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(s.abs(newPath))); err != nil {
+		return err
+	}
+	// pick up the new filenames; the moved files read as new ones
+	_, _, _, err = s.scan(nil)
+	return err
+}
+
+// checkFolderMove validates the paths of a folder rename
+func checkFolderMove(oldPath, newPath string) error {
+	if err := validFolder(oldPath); err != nil {
+		return err
+	}
+	if err := validFolder(newPath); err != nil {
+		return err
+	}
+	if oldPath == newPath || strings.HasPrefix(newPath, oldPath+"/") {
+		return errors.New("can't move a folder into itself")
+	}
+	return nil
+}
+
+// folderTree adds the parents of every folder in folders, sorted
+func folderTree(folders []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, f := range folders {
+		for f != "." && f != "" && !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+			f = path.Dir(f)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
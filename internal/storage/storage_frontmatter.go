@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entries are stored as markdown with an optional YAML front matter block:
+//
+//	---
+//	id: 01K3F2Q8Z6M5J0V1XWBT9C4N7D
+//	title: Tags are cool eh?
+//	created: 2025-08-25T02:06:44Z
+//	tags: [tags, test]
+//	mood: happy
+//	---
+//	# Tags are cool eh?
+//
+// Only the subset of YAML that notes actually use is understood: scalars,
+// quoted strings, and flow (`[a, b]`) or block (`- a`) lists. Other indented
+// blocks, like nested mappings, are kept as written.
+
+const fmDelim = "---"
+
+// known front matter keys, written in this order before any extra fields
+var fmKeys = []string{"id", "title", "created", "updated", "tags"}
+
+// MarshalEntry renders e as front matter followed by its content
+func MarshalEntry(e Entry) []byte {
+	var b strings.Builder
+	b.WriteString(fmDelim + "\n")
+	if e.ID != "" {
+		writeField(&b, "id", e.ID)
+	}
+	if e.Title != "" {
+		writeField(&b, "title", e.Title)
+	}
+	if !e.Created.IsZero() {
+		writeField(&b, "created", e.Created.Format(time.RFC3339))
+	}
+	if !e.Updated.IsZero() {
+		writeField(&b, "updated", e.Updated.Format(time.RFC3339))
+	}
+	if len(e.Tags) > 0 {
+		writeField(&b, "tags", e.Tags)
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		if !isKnownKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeField(&b, k, e.Fields[k])
+	}
+	b.WriteString(fmDelim + "\n")
+	b.WriteString(e.Content)
+	return []byte(b.String())
+}
+
+// UnmarshalEntry splits raw file data into front matter fields and content.
+// Files without front matter are returned with only Content set.
+func UnmarshalEntry(data []byte) (Entry, error) {
+	raw := strings.ReplaceAll(string(data), "\r\n", "\n")
+	block, body, ok := splitFrontMatter(raw)
+	if !ok {
+		return Entry{Content: raw}, nil
+	}
+	fields, err := parseFrontMatter(block)
+	if err != nil {
+		return Entry{Content: raw}, err
+	}
+	e := Entry{Content: body}
+	for k, v := range fields {
+		switch k {
+		case "id":
+			e.ID = asString(v)
+		case "title":
+			e.Title = asString(v)
+		case "created":
+			if e.Created, err = parseTime(asString(v)); err != nil {
+				return Entry{Content: raw}, fmt.Errorf("front matter created: %w", err)
+			}
+		case "updated":
+			if e.Updated, err = parseTime(asString(v)); err != nil {
+				return Entry{Content: raw}, fmt.Errorf("front matter updated: %w", err)
+			}
+		case "tags":
+			e.Tags = asList(v)
+		default:
+			if e.Fields == nil {
+				e.Fields = map[string]any{}
+			}
+			e.Fields[k] = v
+		}
+	}
+	return e, nil
+}
+
+func isKnownKey(k string) bool {
+	for _, known := range fmKeys {
+		if k == known {
+			return true
+		}
+	}
+	return false
+}
+
+// splitFrontMatter returns the lines between the leading --- delimiters and the rest
+func splitFrontMatter(raw string) (block, body string, ok bool) {
+	if !strings.HasPrefix(raw, fmDelim+"\n") {
+		return "", raw, false
+	}
+	rest := raw[len(fmDelim)+1:]
+	if strings.HasPrefix(rest, fmDelim+"\n") || rest == fmDelim {
+		return "", strings.TrimPrefix(strings.TrimPrefix(rest, fmDelim), "\n"), true
+	}
+	end := strings.Index(rest, "\n"+fmDelim+"\n")
+	if end < 0 {
+		if strings.HasSuffix(rest, "\n"+fmDelim) {
+			return rest[:len(rest)-len(fmDelim)-1], "", true
+		}
+		return "", raw, false
+	}
+	return rest[:end], rest[end+len(fmDelim)+2:], true
+}
+
+// rawValue is a value the parser doesn't understand, such as a nested mapping
+// or a block scalar: the text after its key's colon, kept as written so it
+// survives a rewrite
+type rawValue string
+
+// parseFrontMatter parses `key: value` lines; values are string or []string,
+// or rawValue for indented blocks
+func parseFrontMatter(block string) (map[string]any, error) {
+	fields := map[string]any{}
+	var listKey string
+	// the last key's value as written and the lines under it, in case they
+	// turn out to be a block this parser doesn't understand
+	var key, after string
+	var under []string
+	raw := false
+	flush := func() {
+		if raw {
+			for len(under) > 0 && strings.TrimSpace(under[len(under)-1]) == "" {
+				under = under[:len(under)-1]
+			}
+			fields[key] = rawValue(after + "\n" + strings.Join(under, "\n"))
+		}
+		raw, under = false, nil
+	}
+	sc := bufio.NewScanner(strings.NewReader(block))
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		trim := strings.TrimSpace(line)
+		item := strings.HasPrefix(trim, "- ") || trim == "-"
+		indented := line != "" && (line[0] == ' ' || line[0] == '\t')
+		if key != "" && (indented || item || trim == "") {
+			under = append(under, line)
+			if raw {
+				continue
+			}
+		}
+		if trim == "" || strings.HasPrefix(trim, "#") {
+			continue
+		}
+		if item {
+			if listKey == "" {
+				return nil, fmt.Errorf("front matter line %d: list item without key", n)
+			}
+			v, err := parseScalar(strings.TrimSpace(strings.TrimPrefix(trim, "-")))
+			if err != nil {
+				return nil, fmt.Errorf("front matter line %d: %w", n, err)
+			}
+			fields[listKey] = append(fields[listKey].([]string), v)
+			continue
+		}
+		if indented {
+			if key == "" {
+				return nil, fmt.Errorf("front matter line %d: unexpected indentation", n)
+			}
+			raw, listKey = true, ""
+			continue
+		}
+		flush()
+		k, val, found := strings.Cut(line, ":")
+		k = strings.TrimSpace(k)
+		if !found || k == "" {
+			return nil, fmt.Errorf("front matter line %d: expected key: value", n)
+		}
+		key, after = k, val
+		val = strings.TrimSpace(val)
+		listKey = ""
+		switch {
+		case val == "":
+			// block list (or empty value) follows
+			listKey = key
+			fields[key] = []string{}
+		case strings.HasPrefix(val, "["):
+			items, err := parseFlowList(val)
+			if err != nil {
+				return nil, fmt.Errorf("front matter line %d: %w", n, err)
+			}
+			fields[key] = items
+		default:
+			s, err := parseScalar(val)
+			if err != nil {
+				return nil, fmt.Errorf("front matter line %d: %w", n, err)
+			}
+			fields[key] = s
+		}
+	}
+	flush()
+	// a key with nothing after it is an empty string rather than an empty list
+	for k, v := range fields {
+		if l, ok := v.([]string); ok && len(l) == 0 && k != "tags" {
+			fields[k] = ""
+		}
+	}
+	return fields, sc.Err()
+}
+
+// parseScalar unquotes a YAML scalar and strips trailing comments
+func parseScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := closingQuote(s)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strconv.Unquote(s[:end+1])
+	case strings.HasPrefix(s, "'"):
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return strings.ReplaceAll(s[1:i], "''", "'"), nil
+		}
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// closingQuote finds the end of a double quoted string, honouring escapes
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseFlowList parses `[a, "b c", d]`
+func parseFlowList(s string) ([]string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated list %s", s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	items := []string{}
+	for inner != "" {
+		var item string
+		if inner[0] == '"' || inner[0] == '\'' {
+			end := closingQuote(inner)
+			if inner[0] == '\'' {
+				end = strings.Index(inner[1:], "'") + 1
+			}
+			if end <= 0 {
+				return nil, fmt.Errorf("unterminated string in list %s", s)
+			}
+			v, err := parseScalar(inner[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			item = v
+			inner = strings.TrimSpace(inner[end+1:])
+			inner = strings.TrimSpace(strings.TrimPrefix(inner, ","))
+		} else {
+			raw, rest, _ := strings.Cut(inner, ",")
+			item = strings.TrimSpace(raw)
+			inner = strings.TrimSpace(rest)
+		}
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func writeField(b *strings.Builder, key string, v any) {
+	switch v := v.(type) {
+	case rawValue:
+		fmt.Fprintf(b, "%s:%s\n", key, string(v))
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = quoteScalar(s, true)
+		}
+		fmt.Fprintf(b, "%s: [%s]\n", key, strings.Join(quoted, ", "))
+	default:
+		fmt.Fprintf(b, "%s: %s\n", key, quoteScalar(fmt.Sprint(v), false))
+	}
+}
+
+// quoteScalar double-quotes s when writing it bare would change its meaning
+func quoteScalar(s string, inList bool) string {
+	if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, "\"\n\t\\") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'%@`") || inList && strings.ContainsAny(s, ",[]") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func asString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ", ")
+	}
+	return ""
+}
+
+func asList(v any) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case string:
+		if v == "" {
+			return nil
+		}
+		// allow the common `tags: a, b` shorthand
+		var out []string
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				out = append(out, t)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// parseTime accepts RFC3339 timestamps as well as plain dates
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised time %q", s)
+}
@@ -0,0 +1,626 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const metaFile = "metadata.json"
+
+// FSStore keeps entries as markdown files with front matter in a directory
+type FSStore struct {
+	dir string
+
+	mu  sync.Mutex
+	ids map[string]string      // id -> filename, refreshed by every scan
+	idx map[string]indexRecord // filename -> cached metadata, see storage_index.go
+	// idx has writes that are not on disk yet; flushed by the next scan
+	dirty bool
+}
+
+// NewFSStore returns a Store rooted at dir
+func NewFSStore(dir string) *FSStore {
+	return &FSStore{dir: dir}
+}
+
+// Dir returns the directory holding the entries
+func (s *FSStore) Dir() string { return s.dir }
+
+// abs is the path on disk of an entry or folder name
+func (s *FSStore) abs(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+// rel is the entry or folder name of a path on disk
+func (s *FSStore) rel(p string) string {
+	r, err := filepath.Rel(s.dir, p)
+	if err != nil {
+		return filepath.Base(p)
+	}
+	return filepath.ToSlash(r)
+}
+
+// EnsureDataDir makes sure data dir exists
+func (s *FSStore) EnsureDataDir() error {
+	return os.MkdirAll(s.dir, 0o755)
+}
+
+// metadata.json is the legacy tag store: filename -> tags
+func (s *FSStore) loadMetadata() (map[string][]string, error) {
+	mp := map[string][]string{}
+	path := filepath.Join(s.dir, metaFile)
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return mp, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&mp); err != nil {
+		return nil, err
+	}
+	return mp, nil
+}
+
+// List walks the directory and returns entries (without content). Only files
+// that changed since the last scan are read; the rest come from the index.
+// Files without an id, or sharing one with another file (e.g. a copied
+// note), are given a fresh id that is written back to their front matter.
+// Files that can't be read, or whose front matter doesn't parse, are left
+// out; ListProgress reports them.
+func (s *FSStore) List() ([]Entry, error) {
+	entries, err := s.ListProgress(nil)
+	var unreadable *UnreadableError
+	if errors.As(err, &unreadable) {
+		err = nil
+	}
+	return entries, err
+}
+
+// ListProgress is List, calling progress (if set) with the number of files
+// scanned so far. Files that can't be read are returned as an
+// *UnreadableError alongside the entries of the others.
+func (s *FSStore) ListProgress(progress func(scanned int)) ([]Entry, error) {
+	entries, missing, unreadable, err := s.scan(progress)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		if err := s.backfillIDs(entries, missing); err != nil {
+			return nil, err
+		}
+	}
+	SortEntries(entries, SortCreated)
+	if len(unreadable) > 0 {
+		return entries, &UnreadableError{Errs: unreadable}
+	}
+	return entries, nil
+}
+
+// scan refreshes the index and the id -> filename map from the directory. It
+// also returns the indexes of entries that need a new id, and the errors of
+// files it couldn't read.
+func (s *FSStore) scan(progress func(int)) ([]Entry, []int, []error, error) {
+	if err := s.EnsureDataDir(); err != nil {
+		return nil, nil, nil, err
+	}
+	entries := []Entry{}
+	var unreadable []error
+
+	mp, err := s.loadMetadata()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	s.mu.Lock()
+	if s.idx == nil {
+		s.idx = s.loadIndex()
+	}
+	prev := s.idx
+	changed := s.dirty
+	s.mu.Unlock()
+	next := make(map[string]indexRecord, len(prev))
+	scanned := 0
+
+	err = filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			// skip tmp folder files and hidden folders like .trash
+			if path != s.dir && skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// skip metadata
+		if filepath.Base(path) == metaFile {
+			return nil
+		}
+		if filepath.Ext(path) != ".md" {
+			return nil
+		}
+		filename := s.rel(path)
+		fi, err := d.Info()
+		if err != nil {
+			unreadable = append(unreadable, err)
+			return nil
+		}
+		if scanned++; progress != nil {
+			progress(scanned)
+		}
+		if r, ok := prev[filename]; ok && r.fresh(fi) {
+			next[filename] = r
+			entries = append(entries, r.entry(filename))
+			return nil
+		}
+		e, err := s.read(path, mp)
+		if err != nil {
+			unreadable = append(unreadable, err)
+			return nil
+		}
+		changed = true
+		next[filename] = newRecord(e)
+		e.Content = ""
+		entries = append(entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(next) != len(prev) {
+		changed = true
+	}
+
+	ids := make(map[string]string, len(entries))
+	var missing []int
+	for i, e := range entries {
+		if _, dup := ids[e.ID]; dup || !validID(e.ID) {
+			missing = append(missing, i)
+			continue
+		}
+		ids[e.ID] = e.Filename
+	}
+	s.mu.Lock()
+	s.ids = ids
+	s.idx = next
+	s.dirty = false
+	s.mu.Unlock()
+	if changed {
+		// the index is only a cache; failing to persist it costs a slower next start
+		_ = s.saveIndex(next)
+	}
+	return entries, missing, unreadable, nil
+}
+
+// backfillIDs assigns new ids to entries[i] for i in missing and persists them.
+// The file's mtime is kept so the rewrite doesn't reorder the list.
+func (s *FSStore) backfillIDs(entries []Entry, missing []int) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return s.assignIDs(entries, missing)
+}
+
+// assignIDs is backfillIDs for callers holding the lock
+func (s *FSStore) assignIDs(entries []Entry, missing []int) error {
+	for _, i := range missing {
+		e := &entries[i]
+		// re-read under the lock in case another writer got there first
+		path := s.abs(e.Filename)
+		cur, err := s.read(path, nil)
+		if err != nil {
+			continue
+		}
+		s.mu.Lock()
+		owner, taken := s.ids[cur.ID]
+		s.mu.Unlock()
+		if validID(cur.ID) && (!taken || owner == cur.Filename) {
+			e.ID = cur.ID
+		} else {
+			if cur.Tags == nil {
+				cur.Tags = e.Tags
+			}
+			cur.ID = NewID()
+			if err := s.write(cur); err != nil {
+				return err
+			}
+			if err := os.Chtimes(path, cur.ModTime, cur.ModTime); err != nil {
+				return err
+			}
+			if cur, err = s.read(path, nil); err != nil {
+				return err
+			}
+			s.remember(cur)
+			cur.Content = ""
+			*e = cur
+		}
+		s.mu.Lock()
+		s.ids[e.ID] = e.Filename
+		s.mu.Unlock()
+	}
+	s.mu.Lock()
+	records := s.idx
+	s.mu.Unlock()
+	return s.saveIndex(records)
+}
+
+// remember records a freshly written entry in the id map and index
+func (s *FSStore) remember(e Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ids == nil {
+		s.ids = map[string]string{}
+	}
+	if s.idx == nil {
+		s.idx = s.loadIndex()
+	}
+	s.ids[e.ID] = e.Filename
+	s.idx[e.Filename] = newRecord(e)
+	s.dirty = true
+}
+
+// forget drops a filename from the id map and index
+func (s *FSStore) forget(id, filename string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.ids, id)
+	delete(s.idx, filename)
+	s.dirty = true
+}
+
+// read loads a single entry file, falling back to metadata.json tags for
+// files that have not been migrated to front matter yet
+func (s *FSStore) read(path string, mp map[string][]string) (Entry, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	filename := s.rel(path)
+	// a malformed header makes the note unreadable rather than part of the
+	// body, so nothing writes a second header on top of it
+	e, err := UnmarshalEntry(bytes)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", filename, err)
+	}
+	e.Filename = filename
+	e.Title = titleFromContent(e.Content, e.Title)
+	if e.Title == "" {
+		e.Title = filename
+	}
+	e.ModTime = fi.ModTime()
+	e.Size = int64(len(bytes))
+	e.Hash = contentHash(bytes)
+	e.Snippet = makeSnippet(e.Content)
+	fillTimes(&e)
+	if e.Tags == nil {
+		e.Tags = mp[filename]
+	}
+	return e, nil
+}
+
+// filename returns where the entry with id lives, rescanning once on a miss
+func (s *FSStore) filename(id string) (string, error) {
+	s.mu.Lock()
+	name, ok := s.ids[id]
+	s.mu.Unlock()
+	if ok {
+		return name, nil
+	}
+	if _, _, _, err := s.scan(nil); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	name, ok = s.ids[id]
+	s.mu.Unlock()
+	if !ok {
+		return "", ErrNotFound
+	}
+	return name, nil
+}
+
+// get reads the entry with id, rescanning if the file moved underneath us
+func (s *FSStore) get(id string) (Entry, error) {
+	name, err := s.filename(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	e, err := s.read(s.abs(name), nil)
+	if err == nil && e.ID == id {
+		return e, nil
+	}
+	if _, _, _, err := s.scan(nil); err != nil {
+		return Entry{}, err
+	}
+	if name, err = s.filename(id); err != nil {
+		return Entry{}, err
+	}
+	return s.read(s.abs(name), nil)
+}
+
+// Get loads a single entry by id
+func (s *FSStore) Get(id string) (Entry, error) {
+	return s.get(id)
+}
+
+// Put writes the entry with its metadata as front matter. Entries with an
+// empty or unknown ID are created, under a collision-free filename.
+func (s *FSStore) Put(e Entry) (Entry, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+	return s.put(e)
+}
+
+func (s *FSStore) put(e Entry) (Entry, error) {
+	now := time.Now()
+	existing := ""
+	if e.ID != "" {
+		if !validID(e.ID) {
+			return Entry{}, ErrInvalidID
+		}
+		name, err := s.filename(e.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return Entry{}, err
+		}
+		existing = name
+	}
+	if existing != "" {
+		e.Filename = existing
+	} else {
+		if e.ID == "" {
+			e.ID = NewID()
+		}
+		if e.Filename == "" {
+			e.Filename = entryFilename(e.Title, now)
+		} else if err := validName(e.Filename); err != nil {
+			return Entry{}, err
+		}
+		e.Filename = UniqueFilename(e.Filename, func(name string) bool {
+			_, err := os.Lstat(s.abs(name))
+			return err == nil
+		})
+	}
+	if e.Created.IsZero() {
+		// new entries, or edits that dropped the field
+		e.ModTime = now
+		fillTimes(&e)
+	}
+	if e.Tags == nil {
+		e.Tags = []string{}
+	}
+	if existing != "" || e.Updated.IsZero() {
+		// new entries may come with their own, when imported
+		e.Updated = now
+	}
+	e.Title = titleFromContent(e.Content, e.Title)
+	if existing != "" {
+		if err := s.snapshot(e.ID, existing); err != nil {
+			return Entry{}, err
+		}
+	}
+	if err := s.write(e); err != nil {
+		return Entry{}, err
+	}
+	saved, err := s.read(s.abs(e.Filename), nil)
+	if err != nil {
+		return Entry{}, err
+	}
+	s.remember(saved)
+	return saved, nil
+}
+
+// write serializes e to its file, creating its folder; callers hold the lock
+func (s *FSStore) write(e Entry) error {
+	p := s.abs(e.Filename)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(p, MarshalEntry(e), 0o644)
+}
+
+// Delete removes the entry file
+func (s *FSStore) Delete(id string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	name, err := s.filename(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.abs(name)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	s.forget(id, name)
+	return s.dropHistory(id)
+}
+
+// Rename moves the entry file; its id and tags travel with it in the front matter
+func (s *FSStore) Rename(id, newName string) (Entry, error) {
+	if err := validName(newName); err != nil {
+		return Entry{}, err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+	oldName, err := s.filename(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	oldPath := s.abs(oldName)
+	newPath := s.abs(newName)
+	if _, err := os.Lstat(newPath); err == nil {
+		return Entry{}, ErrExists
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		if os.IsNotExist(err) {
+			return Entry{}, ErrNotFound
+		}
+		return Entry{}, err
+	}
+	if err := syncDir(filepath.Dir(newPath)); err != nil {
+		return Entry{}, err
+	}
+	s.forget(id, oldName)
+	e, err := s.read(newPath, nil)
+	if err != nil {
+		return Entry{}, err
+	}
+	s.remember(e)
+	return e, nil
+}
+
+// Tags returns id -> tags for every entry
+func (s *FSStore) Tags() (map[string][]string, error) {
+	entries, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	mp := make(map[string][]string, len(entries))
+	for _, e := range entries {
+		mp[e.ID] = e.Tags
+	}
+	return mp, nil
+}
+
+// Retag rewrites the tags of every entry in one transaction: all changed
+// entries are written or none are. Each changed entry gets a revision
+// snapshot and its Updated time bumped.
+func (s *FSStore) Retag(rewrite func(tags []string) []string) (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	entries, missing, _, err := s.scan(nil)
+	if err != nil {
+		return 0, err
+	}
+	if len(missing) > 0 {
+		// entries without an id of their own can't have history or be found again
+		if err := s.assignIDs(entries, missing); err != nil {
+			return 0, err
+		}
+	}
+	now := time.Now()
+	var changed []Entry
+	for _, e := range entries {
+		tags := rewrite(append([]string{}, e.Tags...))
+		if sameTags(tags, e.Tags) {
+			continue
+		}
+		full, err := s.read(s.abs(e.Filename), nil)
+		if err != nil {
+			return 0, err
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		full.Tags = tags
+		full.Updated = now
+		changed = append(changed, full)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	for _, e := range changed {
+		if err := s.snapshot(e.ID, e.Filename); err != nil {
+			return 0, err
+		}
+	}
+	if err := s.writeTx(changed); err != nil {
+		return 0, err
+	}
+	for _, e := range changed {
+		saved, err := s.read(s.abs(e.Filename), nil)
+		if err != nil {
+			return len(changed), err
+		}
+		s.remember(saved)
+	}
+	return len(changed), nil
+}
+
+// MigrateMetadata moves tags from the legacy metadata.json into each entry's
+// front matter, then renames metadata.json so the migration only runs once.
+// It returns the number of entries rewritten.
+func (s *FSStore) MigrateMetadata() (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	mp, err := s.loadMetadata()
+	if err != nil {
+		return 0, err
+	}
+	path := filepath.Join(s.dir, metaFile)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return 0, nil
+	}
+	n := 0
+	for filename, tags := range mp {
+		if validName(filename) != nil {
+			continue
+		}
+		e, err := s.read(s.abs(filename), nil)
+		if os.IsNotExist(err) {
+			// tags of a file that is already gone
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		merged := append([]string{}, e.Tags...)
+		for _, t := range tags {
+			if !containsTag(merged, t) {
+				merged = append(merged, t)
+			}
+		}
+		if len(merged) == len(e.Tags) {
+			continue
+		}
+		e.Tags = merged
+		if !validID(e.ID) {
+			e.ID = NewID()
+		}
+		if err := s.write(e); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, os.Rename(path, path+".migrated")
+}
+
+func containsTag(tags []string, t string) bool {
+	for _, x := range tags {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
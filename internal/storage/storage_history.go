@@ -0,0 +1,374 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Every save that changes an entry first snapshots the version it replaces
+// under data/.history: objects/<sha256> holds the entry file as it was, once
+// however many revisions share it, and <id>.json lists the entry's revisions
+// oldest first. History is keyed by id, so it follows renames and moves.
+
+const historyDir = ".history"
+
+// Revision is a saved version of an entry
+type Revision struct {
+	Hash    string    `json:"hash"`    // of the entry file, naming its object
+	Updated time.Time `json:"updated"` // when this version was written
+	Title   string    `json:"title"`
+	Size    int64     `json:"size"`
+}
+
+// newRevision describes raw, an entry file last written at modTime
+func newRevision(raw []byte, modTime time.Time) Revision {
+	e, _ := UnmarshalEntry(raw)
+	rev := Revision{Hash: contentHash(raw), Updated: e.Updated, Title: titleFromContent(e.Content, e.Title), Size: int64(len(raw))}
+	if rev.Updated.IsZero() {
+		rev.Updated = modTime
+	}
+	return rev
+}
+
+// addRevision appends rev unless it is the latest revision already
+func addRevision(revs []Revision, rev Revision) ([]Revision, bool) {
+	if n := len(revs); n > 0 && revs[n-1].Hash == rev.Hash {
+		return revs, false
+	}
+	return append(revs, rev), true
+}
+
+// pruneRevisions keeps the newest keep revisions (all when keep <= 0) not
+// written before the given time (any when zero)
+func pruneRevisions(revs []Revision, keep int, before time.Time) []Revision {
+	out := revs[:0]
+	for _, r := range revs {
+		if before.IsZero() || !r.Updated.Before(before) {
+			out = append(out, r)
+		}
+	}
+	if keep > 0 && len(out) > keep {
+		out = out[len(out)-keep:]
+	}
+	return out
+}
+
+// newestFirst returns a reversed copy of revs
+func newestFirst(revs []Revision) []Revision {
+	out := make([]Revision, len(revs))
+	for i, r := range revs {
+		out[len(revs)-1-i] = r
+	}
+	return out
+}
+
+// revisionEntry parses the object of a revision of the entry with id
+func revisionEntry(id string, rev Revision, raw []byte) Entry {
+	e, _ := UnmarshalEntry(raw)
+	e.ID = id
+	e.Title = titleFromContent(e.Content, e.Title)
+	e.Size = int64(len(raw))
+	e.Hash = rev.Hash
+	if e.Updated.IsZero() {
+		e.Updated = rev.Updated
+	}
+	return e
+}
+
+// findRevision returns the revision of revs with hash
+func findRevision(revs []Revision, hash string) (Revision, bool) {
+	for _, r := range revs {
+		if r.Hash == hash {
+			return r, true
+		}
+	}
+	return Revision{}, false
+}
+
+func (s *FSStore) historyPath(id string) string {
+	return filepath.Join(s.dir, historyDir, id+".json")
+}
+
+func (s *FSStore) objectPath(hash string) string {
+	return filepath.Join(s.dir, historyDir, "objects", hash)
+}
+
+// readHistory reads the revisions of the entry with id, oldest first
+func (s *FSStore) readHistory(id string) ([]Revision, error) {
+	data, err := os.ReadFile(s.historyPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var revs []Revision
+	if err := json.Unmarshal(data, &revs); err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+// writeHistory replaces the revisions of the entry with id
+func (s *FSStore) writeHistory(id string, revs []Revision) error {
+	if len(revs) == 0 {
+		return s.dropHistory(id)
+	}
+	data, err := json.Marshal(revs)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.historyPath(id), data, 0o644)
+}
+
+// dropHistory forgets the revisions of the entry with id; their objects go
+// with the next PruneHistory
+func (s *FSStore) dropHistory(id string) error {
+	if err := os.Remove(s.historyPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// snapshot records the file of entry id at name as a revision before it is
+// overwritten; callers hold the lock. The object is written before the
+// revision list, so a crash leaves at worst an unreferenced object.
+func (s *FSStore) snapshot(id, name string) error {
+	p := s.abs(name)
+	raw, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	revs, err := s.readHistory(id)
+	if err != nil {
+		return err
+	}
+	rev := newRevision(raw, fi.ModTime())
+	revs, added := addRevision(revs, rev)
+	if !added {
+		return nil
+	}
+	obj := s.objectPath(rev.Hash)
+	if _, err := os.Stat(obj); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(obj), 0o755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(obj, raw, 0o644); err != nil {
+			return err
+		}
+	}
+	return s.writeHistory(id, revs)
+}
+
+// History lists the saved revisions of an entry, newest first
+func (s *FSStore) History(id string) ([]Revision, error) {
+	if !validID(id) {
+		return nil, ErrInvalidID
+	}
+	revs, err := s.readHistory(id)
+	if err != nil {
+		return nil, err
+	}
+	return newestFirst(revs), nil
+}
+
+// Revision reads an entry as it was in the revision with hash
+func (s *FSStore) Revision(id, hash string) (Entry, error) {
+	revs, err := s.History(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	// only hashes listed in the history name objects, so hash can't wander off
+	rev, ok := findRevision(revs, hash)
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	raw, err := os.ReadFile(s.objectPath(rev.Hash))
+	if err != nil {
+		return Entry{}, err
+	}
+	return revisionEntry(id, rev, raw), nil
+}
+
+// PruneHistory applies the retention policy to every entry's revisions, then
+// deletes the objects no revision refers to any more
+func (s *FSStore) PruneHistory(keep int, before time.Time) (int, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	dirents, err := os.ReadDir(filepath.Join(s.dir, historyDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	dropped := 0
+	used := map[string]bool{}
+	for _, d := range dirents {
+		id, ok := strings.CutSuffix(d.Name(), ".json")
+		if !ok || !validID(id) {
+			continue
+		}
+		revs, err := s.readHistory(id)
+		if err != nil {
+			return dropped, err
+		}
+		n := len(revs)
+		if revs = pruneRevisions(revs, keep, before); len(revs) != n {
+			dropped += n - len(revs)
+			if err := s.writeHistory(id, revs); err != nil {
+				return dropped, err
+			}
+		}
+		for _, r := range revs {
+			used[r.Hash] = true
+		}
+	}
+	objects, err := os.ReadDir(filepath.Join(s.dir, historyDir, "objects"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return dropped, err
+	}
+	for _, o := range objects {
+		if !used[o.Name()] {
+			if err := os.Remove(filepath.Join(s.dir, historyDir, "objects", o.Name())); err != nil {
+				return dropped, err
+			}
+		}
+	}
+	return dropped, nil
+}
+
+// RestoreRevision saves the content and tags of a revision as the entry's
+// current version. The version it replaces is snapshotted like any save, so
+// a restore can be undone.
+func RestoreRevision(s Store, id, hash string) (Entry, error) {
+	rev, err := s.Revision(id, hash)
+	if err != nil {
+		return Entry{}, err
+	}
+	cur, err := s.Get(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	cur.Title, cur.Content, cur.Tags = rev.Title, rev.Content, rev.Tags
+	return s.Put(cur)
+}
+
+// DiffLine is a line of a line diff: Op is ' ' for a line both sides have,
+// '-' for one only the old side has and '+' for one only the new side has
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+// maxDiffCells bounds the table of the longest common subsequence; changes
+// bigger than that diff as everything removed, then everything added
+const maxDiffCells = 4 << 20
+
+// DiffLines diffs old against new line by line
+func DiffLines(old, new string) []DiffLine {
+	x, y := splitLines(old), splitLines(new)
+	// most edits leave the start and end of a note alone
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+	var out []DiffLine
+	for _, l := range x[:pre] {
+		out = append(out, DiffLine{' ', l})
+	}
+	out = append(out, lcsDiff(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, l := range x[len(x)-suf:] {
+		out = append(out, DiffLine{' ', l})
+	}
+	return out
+}
+
+// DiffStat counts the added and removed lines of a diff
+func DiffStat(diff []DiffLine) (added, removed int) {
+	for _, l := range diff {
+		switch l.Op {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// lcsDiff diffs x against y through their longest common subsequence
+func lcsDiff(x, y []string) []DiffLine {
+	var out []DiffLine
+	if len(x)*len(y) > maxDiffCells {
+		for _, l := range x {
+			out = append(out, DiffLine{'-', l})
+		}
+		for _, l := range y {
+			out = append(out, DiffLine{'+', l})
+		}
+		return out
+	}
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int32, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			out = append(out, DiffLine{' ', x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, DiffLine{'-', x[i]})
+			i++
+		default:
+			out = append(out, DiffLine{'+', y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		out = append(out, DiffLine{'-', x[i]})
+	}
+	for ; j < len(y); j++ {
+		out = append(out, DiffLine{'+', y[j]})
+	}
+	return out
+}
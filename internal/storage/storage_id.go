@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// Crockford's base32, as used by ULIDs
+const idAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewID returns a ULID: 48 bits of millisecond timestamp followed by 80
+// random bits, encoded as 26 sortable characters
+func NewID() string {
+	return newIDAt(time.Now())
+}
+
+func newIDAt(t time.Time) string {
+	var b [16]byte
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(t.UnixMilli()))
+	copy(b[:6], ms[2:])
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic("storage: no randomness for entry ids: " + err.Error())
+	}
+	return encodeID(b)
+}
+
+// encodeID writes 128 bits as a ULID
+func encodeID(b [16]byte) string {
+	// 128 bits -> 26 groups of 5 bits, with two implicit leading zero bits
+	var out [26]byte
+	for i := range out {
+		bit := i*5 - 2
+		v := 0
+		for j := 0; j < 5; j++ {
+			if k := bit + j; k >= 0 && b[k/8]&(0x80>>(k%8)) != 0 {
+				v |= 1 << (4 - j)
+			}
+		}
+		out[i] = idAlphabet[v]
+	}
+	return string(out[:])
+}
+
+// decodeID reads the 128 bits of a ULID, case insensitively
+func decodeID(id string) (b [16]byte, ok bool) {
+	if len(id) != 26 {
+		return b, false
+	}
+	for i := 0; i < len(id); i++ {
+		v := strings.IndexByte(idAlphabet, id[i]&^0x20)
+		if id[i] >= '0' && id[i] <= '9' {
+			v = int(id[i] - '0')
+		}
+		if v < 0 || i == 0 && v > 7 {
+			return b, false
+		}
+		for j := 0; j < 5; j++ {
+			if k := i*5 - 2 + j; k >= 0 && v&(1<<(4-j)) != 0 {
+				b[k/8] |= 0x80 >> (k % 8)
+			}
+		}
+	}
+	return b, true
+}
+
+// IDHex returns an entry id as 32 hex digits, the form of Joplin's ids: the
+// bits of a ULID, else a hash of the id
+func IDHex(id string) string {
+	b, ok := decodeID(id)
+	if !ok {
+		sum := sha256.Sum256([]byte(id))
+		copy(b[:], sum[:])
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// IDFromHex is the ULID with the bits of 32 hex digits, undoing IDHex
+func IDFromHex(h string) (string, bool) {
+	var b [16]byte
+	if len(h) != 32 {
+		return "", false
+	}
+	if _, err := hex.Decode(b[:], []byte(h)); err != nil {
+		return "", false
+	}
+	return encodeID(b), true
+}
+
+// validID rejects ids that can't be used as keys; ids written by hand or by
+// other tools don't have to be ULIDs
+func validID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, "/\\\n") && strings.TrimSpace(id) == id
+}
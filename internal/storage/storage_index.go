@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The index caches everything List needs so that only files whose size or
+// mtime changed since the last scan are read again. It is a cache: deleting
+// it is always safe.
+const (
+	indexFile    = ".index.json"
+	indexVersion = 1
+	snippetLen   = 160
+)
+
+type indexRecord struct {
+	ID      string         `json:"id"`
+	Title   string         `json:"title"`
+	Created time.Time      `json:"created"`
+	Updated time.Time      `json:"updated"`
+	Tags    []string       `json:"tags,omitempty"`
+	Fields  map[string]any `json:"fields,omitempty"`
+	Size    int64          `json:"size"`
+	MTime   int64          `json:"mtime"` // unix nanoseconds
+	Hash    string         `json:"hash"`
+	Snippet string         `json:"snippet,omitempty"`
+}
+
+type indexData struct {
+	Version int                    `json:"version"`
+	Entries map[string]indexRecord `json:"entries"` // filename -> record
+}
+
+func newRecord(e Entry) indexRecord {
+	return indexRecord{
+		ID:      e.ID,
+		Title:   e.Title,
+		Created: e.Created,
+		Updated: e.Updated,
+		Tags:    e.Tags,
+		Fields:  e.Fields,
+		Size:    e.Size,
+		MTime:   e.ModTime.UnixNano(),
+		Hash:    e.Hash,
+		Snippet: e.Snippet,
+	}
+}
+
+// fresh reports whether the file behind the record is unchanged
+func (r indexRecord) fresh(fi fs.FileInfo) bool {
+	return r.Size == fi.Size() && r.MTime == fi.ModTime().UnixNano()
+}
+
+// entry rebuilds an Entry (without Content) from the record
+func (r indexRecord) entry(filename string) Entry {
+	return Entry{
+		ID:       r.ID,
+		Title:    r.Title,
+		Filename: filename,
+		ModTime:  time.Unix(0, r.MTime),
+		Created:  r.Created,
+		Updated:  r.Updated,
+		Tags:     r.Tags,
+		Fields:   r.Fields,
+		Size:     r.Size,
+		Hash:     r.Hash,
+		Snippet:  r.Snippet,
+	}
+}
+
+// loadIndex reads the on-disk index; a missing or stale-format index is empty
+func (s *FSStore) loadIndex() map[string]indexRecord {
+	var data indexData
+	raw, err := os.ReadFile(filepath.Join(s.dir, indexFile))
+	if err != nil || json.Unmarshal(raw, &data) != nil || data.Version != indexVersion {
+		return map[string]indexRecord{}
+	}
+	for name, r := range data.Entries {
+		r.Fields = normalizeFields(r.Fields)
+		data.Entries[name] = r
+	}
+	return data.Entries
+}
+
+func (s *FSStore) saveIndex(records map[string]indexRecord) error {
+	raw, err := json.Marshal(indexData{Version: indexVersion, Entries: records})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, indexFile), raw, 0o644)
+}
+
+// normalizeFields turns JSON-decoded lists back into []string
+func normalizeFields(fields map[string]any) map[string]any {
+	for k, v := range fields {
+		if list, ok := v.([]any); ok {
+			out := make([]string, 0, len(list))
+			for _, x := range list {
+				if s, ok := x.(string); ok {
+					out = append(out, s)
+				}
+			}
+			fields[k] = out
+		}
+	}
+	return fields
+}
+
+// contentHash is the hex sha256 of a file's bytes
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// makeSnippet returns the start of the body, skipping the title heading and
+// collapsing whitespace
+func makeSnippet(content string) string {
+	body := content
+	if strings.HasPrefix(strings.TrimSpace(body), "# ") {
+		if _, rest, ok := strings.Cut(strings.TrimSpace(body), "\n"); ok {
+			body = rest
+		} else {
+			body = ""
+		}
+	}
+	body = strings.Join(strings.Fields(body), " ")
+	if r := []rune(body); len(r) > snippetLen {
+		body = string(r[:snippetLen]) + "…"
+	}
+	return body
+}
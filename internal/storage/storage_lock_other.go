@@ -0,0 +1,32 @@
+//go:build !unix
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var errSyncUnsupported = errors.New("sync unsupported")
+
+// locks older than this are assumed to belong to a crashed process
+const staleLock = time.Minute
+
+// tryLock creates path exclusively; the file's existence is the lock
+func tryLock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, serr := os.Stat(path); serr == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(path)
+		}
+		return nil, ErrLocked
+	}
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	f.Close()
+	return func() { os.Remove(path) }, nil
+}
@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemStore keeps entries in memory; useful for tests and embedding
+type MemStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry // id -> entry
+	folders map[string]bool  // folders made; like directories they outlive their entries
+	trash   map[string]Trashed
+	history map[string][]Revision // id -> revisions, oldest first
+	objects map[string][]byte     // revision hash -> entry file
+}
+
+// NewMemStore returns an empty in-memory Store
+func NewMemStore() *MemStore {
+	return &MemStore{
+		entries: map[string]Entry{},
+		folders: map[string]bool{},
+		trash:   map[string]Trashed{},
+		history: map[string][]Revision{},
+		objects: map[string][]byte{},
+	}
+}
+
+// List returns copies of all entries, newest first
+func (s *MemStore) List() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		e = clone(e)
+		e.Content = "" // same contract as the filesystem store
+		entries = append(entries, e)
+	}
+	SortEntries(entries, SortCreated)
+	return entries, nil
+}
+
+// Get returns a single entry by id
+func (s *MemStore) Get(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return clone(e), nil
+}
+
+// Put stores the entry, naming new ones like the filesystem store does
+func (s *MemStore) Put(e Entry) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if e.ID != "" && !validID(e.ID) {
+		return Entry{}, ErrInvalidID
+	}
+	old, exists := s.entries[e.ID]
+	if exists {
+		e.Filename = old.Filename
+		s.snapshot(old)
+	} else {
+		if e.ID == "" {
+			e.ID = NewID()
+		}
+		if e.Filename == "" {
+			e.Filename = entryFilename(e.Title, now)
+		} else if err := validName(e.Filename); err != nil {
+			return Entry{}, err
+		}
+		e.Filename = UniqueFilename(e.Filename, s.taken)
+		s.keepFolder(e.Folder())
+	}
+	if e.Created.IsZero() {
+		// new entries, or edits that dropped the field
+		e.ModTime = now
+		fillTimes(&e)
+	}
+	if e.Tags == nil {
+		e.Tags = []string{}
+	}
+	e.Title = titleFromContent(e.Content, e.Title)
+	e.ModTime = now
+	if exists || e.Updated.IsZero() {
+		// new entries may come with their own, when imported
+		e.Updated = now
+	}
+	raw := MarshalEntry(e)
+	e.Size = int64(len(raw))
+	e.Hash = contentHash(raw)
+	e.Snippet = makeSnippet(e.Content)
+	s.entries[e.ID] = clone(e)
+	return e, nil
+}
+
+// taken reports whether an entry already uses filename; callers hold mu
+func (s *MemStore) taken(filename string) bool {
+	for _, e := range s.entries {
+		if e.Filename == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete removes the entry
+func (s *MemStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, id)
+	delete(s.history, id)
+	return nil
+}
+
+// Trash moves the entry to the trash
+func (s *MemStore) Trash(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, id)
+	s.trash[id] = Trashed{Entry: e, Deleted: time.Now()}
+	return nil
+}
+
+// Trashed lists copies of the trashed entries, most recently deleted first
+func (s *MemStore) Trashed() ([]Trashed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Trashed, 0, len(s.trash))
+	for _, t := range s.trash {
+		t.Entry = clone(t.Entry)
+		t.Content = ""
+		out = append(out, t)
+	}
+	sortTrash(out)
+	return out, nil
+}
+
+// Restore moves a trashed entry back, under a free name
+func (s *MemStore) Restore(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.trash[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	if _, ok := s.entries[id]; ok {
+		return Entry{}, fmt.Errorf("restoring %s: %w", id, ErrExists)
+	}
+	e := t.Entry
+	e.Filename = UniqueFilename(e.Filename, s.taken)
+	s.keepFolder(e.Folder())
+	delete(s.trash, id)
+	s.entries[id] = e
+	return clone(e), nil
+}
+
+// Purge drops a trashed entry
+func (s *MemStore) Purge(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.trash[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.trash, id)
+	delete(s.history, id)
+	return nil
+}
+
+// snapshot keeps e as a revision before it is replaced; callers hold mu
+func (s *MemStore) snapshot(e Entry) {
+	raw := MarshalEntry(e)
+	rev := newRevision(raw, e.ModTime)
+	revs, added := addRevision(s.history[e.ID], rev)
+	if added {
+		s.objects[rev.Hash] = raw
+		s.history[e.ID] = revs
+	}
+}
+
+// History lists the revisions of an entry, newest first
+func (s *MemStore) History(id string) ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return newestFirst(s.history[id]), nil
+}
+
+// Revision returns an entry as it was in the revision with hash
+func (s *MemStore) Revision(id, hash string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rev, ok := findRevision(s.history[id], hash)
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return revisionEntry(id, rev, s.objects[hash]), nil
+}
+
+// PruneHistory applies the retention policy and drops unused revisions
+func (s *MemStore) PruneHistory(keep int, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := 0
+	used := map[string]bool{}
+	for id, revs := range s.history {
+		n := len(revs)
+		revs = pruneRevisions(revs, keep, before)
+		dropped += n - len(revs)
+		if len(revs) == 0 {
+			delete(s.history, id)
+		} else {
+			s.history[id] = revs
+		}
+		for _, r := range revs {
+			used[r.Hash] = true
+		}
+	}
+	for hash := range s.objects {
+		if !used[hash] {
+			delete(s.objects, hash)
+		}
+	}
+	return dropped, nil
+}
+
+// Rename moves an entry to a new filename
+func (s *MemStore) Rename(id, newName string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := validName(newName); err != nil {
+		return Entry{}, err
+	}
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	if s.taken(newName) {
+		return Entry{}, ErrExists
+	}
+	e.Filename = newName
+	s.entries[id] = e
+	s.keepFolder(e.Folder())
+	return clone(e), nil
+}
+
+// keepFolder records folder, if any, as made
+func (s *MemStore) keepFolder(folder string) {
+	if folder != "" {
+		s.folders[folder] = true
+	}
+}
+
+// Tags returns id -> tags for every entry
+func (s *MemStore) Tags() (map[string][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	mp := make(map[string][]string, len(s.entries))
+	for id, e := range s.entries {
+		mp[id] = append([]string{}, e.Tags...)
+	}
+	return mp, nil
+}
+
+// Retag rewrites the tags of every entry at once
+func (s *MemStore) Retag(rewrite func(tags []string) []string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for id, e := range s.entries {
+		tags := rewrite(append([]string{}, e.Tags...))
+		if sameTags(tags, e.Tags) {
+			continue
+		}
+		if tags == nil {
+			tags = []string{}
+		}
+		s.snapshot(e)
+		e.Tags = tags
+		e.ModTime, e.Updated = now, now
+		raw := MarshalEntry(e)
+		e.Size = int64(len(raw))
+		e.Hash = contentHash(raw)
+		s.entries[id] = e
+		n++
+	}
+	return n, nil
+}
+
+// Folders lists the folders made, with their parents
+func (s *MemStore) Folders() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.folderList(), nil
+}
+
+// folderList is Folders for callers holding mu
+func (s *MemStore) folderList() []string {
+	var folders []string
+	for f := range s.folders {
+		folders = append(folders, f)
+	}
+	return folderTree(folders)
+}
+
+// MakeFolder records an (empty) folder
+func (s *MemStore) MakeFolder(folder string) error {
+	if err := validFolder(folder); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.folders[folder] = true
+	return nil
+}
+
+// RenameFolder moves the entries and folders below oldPath to newPath. Every
+// check is done before anything moves, so a failed rename moves nothing.
+func (s *MemStore) RenameFolder(oldPath, newPath string) error {
+	if err := checkFolderMove(oldPath, newPath); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for _, f := range s.folderList() {
+		if f == newPath {
+			return fmt.Errorf("%q: %w", newPath, ErrExists)
+		}
+		found = found || f == oldPath
+	}
+	if !found {
+		return fmt.Errorf("%q: %w", oldPath, ErrNoFolder)
+	}
+	if s.taken(newPath) {
+		// an entry sits where the folder would go
+		return fmt.Errorf("%q: %w", newPath, ErrExists)
+	}
+	for id, e := range s.entries {
+		if InFolder(e.Filename, oldPath) {
+			e.Filename = newPath + strings.TrimPrefix(e.Filename, oldPath)
+			s.entries[id] = e
+		}
+	}
+	for f := range s.folders {
+		if f == oldPath || strings.HasPrefix(f, oldPath+"/") {
+			delete(s.folders, f)
+			s.folders[newPath+strings.TrimPrefix(f, oldPath)] = true
+		}
+	}
+	return nil
+}
+
+// clone copies tags and fields so callers can't mutate stored entries
+func clone(e Entry) Entry {
+	e.Tags = append([]string{}, e.Tags...)
+	if e.Fields != nil {
+		fields := make(map[string]any, len(e.Fields))
+		for k, v := range e.Fields {
+			fields[k] = v
+		}
+		e.Fields = fields
+	}
+	return e
+}
@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tags nest with slashes: work/project-x/retro sits under work/project-x,
+// which sits under work. Filtering by a tag includes the tags below it.
+
+var (
+	ErrTagNotFound = errors.New("no entry has that tag")
+	ErrTagExists   = errors.New("tag already in use (merge instead)")
+)
+
+// TagCount is a tag and the number of entries carrying it
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// TagNode is a tag in the tag tree. Parents of nested tags are listed even
+// when no entry carries them directly.
+type TagNode struct {
+	Path  string // full tag, e.g. work/project-x
+	Name  string // last part, e.g. project-x
+	Depth int    // 0 for top level tags
+	Count int    // entries carrying the tag or one below it
+	Own   int    // entries carrying exactly this tag
+}
+
+// CleanTag normalizes a tag as typed: no leading #, no blank parts, no
+// spaces around the slashes and no double quotes, which a tag: query can't
+// escape
+func CleanTag(t string) string {
+	t = strings.ReplaceAll(t, `"`, "")
+	t = strings.TrimPrefix(strings.TrimSpace(t), "#")
+	parts := strings.Split(t, "/")
+	out := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+// TagHas reports whether tag is parent or nested below it, ignoring case
+func TagHas(tag, parent string) bool {
+	if len(tag) < len(parent) || !strings.EqualFold(tag[:len(parent)], parent) {
+		return false
+	}
+	return len(tag) == len(parent) || tag[len(parent)] == '/'
+}
+
+// SetTags replaces the tags of the entry with the given id
+func SetTags(s Store, id string, tags []string) (Entry, error) {
+	e, err := s.Get(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	e.Tags = tags
+	return s.Put(e)
+}
+
+// RenameTag renames a tag, and the tags nested below it, on every entry in
+// one transaction. Renaming onto a tag that is already in use is refused
+// with ErrTagExists; use MergeTags for that.
+func RenameTag(s Store, from, to string) (int, error) {
+	from, to, err := tagPair(from, to)
+	if err != nil {
+		return 0, err
+	}
+	if from == to {
+		return 0, nil
+	}
+	if !strings.EqualFold(from, to) && !TagHas(to, from) {
+		all, err := s.Tags()
+		if err != nil {
+			return 0, err
+		}
+		for _, tags := range all {
+			for _, t := range tags {
+				if TagHas(t, to) {
+					return 0, fmt.Errorf("%q: %w", to, ErrTagExists)
+				}
+			}
+		}
+	}
+	return retag(s, from, moveTag(from, to))
+}
+
+// MergeTags moves every entry tagged from (or below it) to into, nesting
+// from's children under into, in one transaction
+func MergeTags(s Store, from, into string) (int, error) {
+	from, into, err := tagPair(from, into)
+	if err != nil {
+		return 0, err
+	}
+	if strings.EqualFold(from, into) {
+		return 0, errors.New("can't merge a tag into itself")
+	}
+	return retag(s, from, moveTag(from, into))
+}
+
+// DeleteTag removes a tag and the tags nested below it from every entry in
+// one transaction
+func DeleteTag(s Store, tag string) (int, error) {
+	tag = CleanTag(tag)
+	if tag == "" {
+		return 0, errors.New("empty tag")
+	}
+	return retag(s, tag, func(t string) string {
+		if TagHas(t, tag) {
+			return ""
+		}
+		return t
+	})
+}
+
+func tagPair(from, to string) (string, string, error) {
+	from, to = CleanTag(from), CleanTag(to)
+	if from == "" || to == "" {
+		return "", "", errors.New("empty tag")
+	}
+	return from, to, nil
+}
+
+// moveTag maps from, and tags below it, to the same place under to
+func moveTag(from, to string) func(string) string {
+	return func(t string) string {
+		if TagHas(t, from) {
+			return to + t[len(from):]
+		}
+		return t
+	}
+}
+
+// retag applies fn to every tag of every entry (an empty result drops the
+// tag), failing with ErrTagNotFound when no entry carried tag
+func retag(s Store, tag string, fn func(string) string) (int, error) {
+	n, err := s.Retag(func(tags []string) []string {
+		out := tags[:0]
+		seen := map[string]bool{}
+		for _, t := range tags {
+			t = fn(t)
+			if t == "" || seen[strings.ToLower(t)] {
+				continue
+			}
+			seen[strings.ToLower(t)] = true
+			out = append(out, t)
+		}
+		return out
+	})
+	if err == nil && n == 0 {
+		err = fmt.Errorf("%q: %w", tag, ErrTagNotFound)
+	}
+	return n, err
+}
+
+// sameTags reports whether a and b hold the same tags in the same order
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CountTags counts the tags of entries, sorted by name. Tags differing only
+// in case are counted together under the first spelling seen.
+func CountTags(entries []Entry) []TagCount {
+	index := map[string]int{}
+	var out []TagCount
+	for _, e := range entries {
+		seen := map[string]bool{}
+		for _, t := range e.Tags {
+			key := strings.ToLower(t)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			i, ok := index[key]
+			if !ok {
+				i = len(out)
+				index[key] = i
+				out = append(out, TagCount{Name: t})
+			}
+			out[i].Count++
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+	})
+	return out
+}
+
+// TagTree lays out the tags of entries as a tree, each tag followed by the
+// tags below it, siblings sorted by name
+func TagTree(entries []Entry) []TagNode {
+	index := map[string]int{}
+	var out []TagNode
+	for _, e := range entries {
+		seen := map[string]bool{}
+		for _, t := range e.Tags {
+			t = CleanTag(t)
+			if t == "" {
+				continue
+			}
+			parts := strings.Split(t, "/")
+			for depth := range parts {
+				path := strings.Join(parts[:depth+1], "/")
+				key := strings.ToLower(path)
+				i, ok := index[key]
+				if !ok {
+					i = len(out)
+					index[key] = i
+					out = append(out, TagNode{Path: path, Name: parts[depth], Depth: depth})
+				}
+				if !seen[key] {
+					seen[key] = true
+					out[i].Count++
+				}
+				if depth == len(parts)-1 && !seen["="+key] {
+					seen["="+key] = true
+					out[i].Own++
+				}
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a := strings.Split(strings.ToLower(out[i].Path), "/")
+		b := strings.Split(strings.ToLower(out[j].Path), "/")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return out
+}
@@ -1,10 +1,17 @@
 package storage
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestSlugify(t *testing.T) {
@@ -15,16 +22,16 @@ func TestSlugify(t *testing.T) {
 }
 
 func TestNewAndLoadEntry(t *testing.T) {
-	os.RemoveAll("data")
-	entry, err := NewEntry("TestNote", "This is a test")
+	s := NewFSStore(t.TempDir())
+	entry, err := NewEntry(s, "TestNote", "This is a test")
 	if err != nil {
 		t.Fatalf("NewEntry failed: %v", err)
 	}
-	if !strings.HasPrefix(entry.Filename, "testnote") {
+	if !strings.HasSuffix(entry.Filename, "-testnote.md") {
 		t.Errorf("unexpected filename: %s", entry.Filename)
 	}
 
-	entries, err := LoadEntries()
+	entries, err := LoadEntries(s)
 	if err != nil {
 		t.Fatalf("LoadEntries failed: %v", err)
 	}
@@ -33,22 +40,6 @@ func TestNewAndLoadEntry(t *testing.T) {
 	}
 }
 
-func TestExportEntry(t *testing.T) {
-	os.RemoveAll("exports")
-	entry, err := NewEntry("ExportMe", "Some content")
-	if err != nil {
-		t.Fatalf("NewEntry failed: %v", err)
-	}
-	path := filepath.Join("data", entry.Filename)
-	exportPath, err := ExportEntry(path)
-	if err != nil {
-		t.Fatalf("ExportEntry failed: %v", err)
-	}
-	if _, err := os.Stat(exportPath); err != nil {
-		t.Fatalf("expected export file at %s, got error: %v", exportPath, err)
-	}
-}
-
 func TestEditEntry(t *testing.T) {
 	// Create a temporary file for testing
 	tmpFile, err := os.CreateTemp("", "test-*.md")
@@ -60,6 +51,7 @@ func TestEditEntry(t *testing.T) {
 	// Set test editor environment
 	oldEditor := os.Getenv("EDITOR")
 	os.Setenv("EDITOR", "echo") // Use echo as a mock editor that does nothing
+	t.Setenv("VISUAL", "")
 	defer os.Setenv("EDITOR", oldEditor)
 
 	// Test editing
@@ -78,10 +70,1044 @@ func TestEditEntry(t *testing.T) {
 // We can’t fully test EditEntry (it opens nano/editor),
 // but we can at least check it doesn't error with invalid $EDITOR.
 func TestEditEntry_NoEditor(t *testing.T) {
-	entry, _ := NewEntry("Dummy", "content")
-	path := filepath.Join("data", entry.Filename)
-	os.Setenv("EDITOR", "/bin/true") // no-op editor
+	s := NewFSStore(t.TempDir())
+	entry, _ := NewEntry(s, "Dummy", "content")
+	path := filepath.Join(s.Dir(), entry.Filename)
+	t.Setenv("EDITOR", "/bin/true") // no-op editor
+	t.Setenv("VISUAL", "")
 	if err := EditEntry(path); err != nil {
 		t.Errorf("EditEntry failed: %v", err)
 	}
 }
+
+func TestEditorCmd(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", `code --wait "--profile=My Notes"`)
+	cmd, err := EditorCmd("", "note.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"code", "--wait", "--profile=My Notes", "note.md"}; !reflect.DeepEqual(cmd.Args, want) {
+		t.Errorf("EDITOR args = %q, want %q", cmd.Args, want)
+	}
+
+	t.Setenv("VISUAL", "vim")
+	if cmd, _ = EditorCmd("", "note.md"); cmd.Args[0] != "vim" {
+		t.Errorf("expected $VISUAL to win over $EDITOR, got %q", cmd.Args)
+	}
+	if cmd, _ = EditorCmd("hx", "note.md"); cmd.Args[0] != "hx" {
+		t.Errorf("expected override to win over $VISUAL, got %q", cmd.Args)
+	}
+	if _, err := EditorCmd(`vim "unterminated`, "note.md"); err == nil {
+		t.Error("expected error for unterminated quote")
+	}
+}
+
+func TestRenameEntry(t *testing.T) {
+	s := NewFSStore(t.TempDir())
+	e, err := s.Put(Entry{Filename: "20250825-020644-tags-are-cool-eh.md", Content: "# Tags are cool eh?\n", Tags: []string{"tags", "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second entry already owns the slug we are about to rename to
+	other, err := s.Put(Entry{Filename: "20250825-020644-better-title.md", Content: "# Better title\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Content = "# Better title\n\nedited"
+	if _, err := s.Put(e); err != nil {
+		t.Fatal(err)
+	}
+
+	renamed, err := RenameEntry(s, e.ID)
+	if err != nil {
+		t.Fatalf("RenameEntry: %v", err)
+	}
+	if renamed.Filename != "20250825-020644-better-title-2.md" {
+		t.Errorf("expected timestamp prefix and collision suffix, got %s", renamed.Filename)
+	}
+	if renamed.ID != e.ID || !reflect.DeepEqual(renamed.Tags, []string{"tags", "test"}) {
+		t.Errorf("id or tags lost on rename: %+v", renamed)
+	}
+	if _, err := os.Stat(filepath.Join(s.Dir(), e.Filename)); !os.IsNotExist(err) {
+		t.Errorf("old file should be gone, got %v", err)
+	}
+
+	// renaming an entry whose title matches its filename is a no-op
+	again, err := RenameEntry(s, other.ID)
+	if err != nil || again.Filename != "20250825-020644-better-title.md" {
+		t.Errorf("unexpected rename: %s %v", again.Filename, err)
+	}
+}
+
+func TestStores(t *testing.T) {
+	for name, s := range map[string]Store{
+		"fs":  NewFSStore(t.TempDir()),
+		"mem": NewMemStore(),
+	} {
+		t.Run(name, func(t *testing.T) { testStore(t, s) })
+	}
+}
+
+// testStore exercises the Store contract shared by every backend
+func testStore(t *testing.T, s Store) {
+	e, err := SaveEntry(s, "First Note", "hello", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	if len(e.ID) != 26 {
+		t.Errorf("expected a ULID, got %q", e.ID)
+	}
+	got, err := s.Get(e.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Title != "First Note" || !strings.Contains(got.Content, "hello") {
+		t.Errorf("unexpected entry: %+v", got)
+	}
+
+	got.Content = "# Renamed\n\nhello again"
+	if got, err = s.Put(got); err != nil || got.Title != "Renamed" {
+		t.Fatalf("Put: %+v %v", got, err)
+	}
+
+	moved, err := s.Rename(e.ID, "renamed.md")
+	if err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if moved.ID != e.ID || moved.Filename != "renamed.md" || len(moved.Tags) != 2 {
+		t.Errorf("id or tags lost on rename: %+v", moved)
+	}
+	tags, err := s.Tags()
+	if err != nil {
+		t.Fatalf("Tags: %v", err)
+	}
+	if len(tags[e.ID]) != 2 {
+		t.Errorf("expected tags keyed by id, got %v", tags)
+	}
+
+	// same title in the same second must not overwrite the first entry
+	twin, err := SaveEntry(s, "Renamed", "twin", nil)
+	if err != nil {
+		t.Fatalf("SaveEntry: %v", err)
+	}
+	clash, err := s.Put(Entry{Filename: twin.Filename, Content: "# Renamed\n\nclash"})
+	if err != nil || clash.Filename == twin.Filename || clash.ID == twin.ID {
+		t.Errorf("expected a distinct entry, got %+v (%v)", clash, err)
+	}
+	for _, id := range []string{twin.ID, clash.ID} {
+		if err := s.Delete(id); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+
+	if _, err := s.Put(Entry{Filename: "../escape.md"}); err == nil {
+		t.Error("expected error for path outside the store")
+	}
+
+	if err := s.Delete(e.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(e.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+	entries, err := s.List()
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected empty store, got %d entries (%v)", len(entries), err)
+	}
+}
+
+func TestFrontMatterRoundTrip(t *testing.T) {
+	in := Entry{
+		Title:   "Standup: notes",
+		Created: time.Date(2025, 8, 25, 2, 6, 44, 0, time.UTC),
+		Tags:    []string{"work", "a, b"},
+		Fields:  map[string]any{"mood": "happy", "aliases": []string{"x", "y"}},
+		Content: "# Standup: notes\n\nbody\n",
+	}
+	out, err := UnmarshalEntry(MarshalEntry(in))
+	if err != nil {
+		t.Fatalf("UnmarshalEntry: %v", err)
+	}
+	if out.Title != in.Title || !out.Created.Equal(in.Created) || out.Content != in.Content {
+		t.Errorf("round trip mismatch: %+v", out)
+	}
+	if !reflect.DeepEqual(out.Tags, in.Tags) || !reflect.DeepEqual(out.Fields, in.Fields) {
+		t.Errorf("round trip lost metadata: %v %v", out.Tags, out.Fields)
+	}
+
+	block := "---\ntitle: 'It''s here'\ntags:\n  - one\n  - \"two\"\ncreated: 2025-01-02\n---\nbody"
+	e, err := UnmarshalEntry([]byte(block))
+	if err != nil {
+		t.Fatalf("UnmarshalEntry: %v", err)
+	}
+	if e.Title != "It's here" || !reflect.DeepEqual(e.Tags, []string{"one", "two"}) || e.Content != "body" || e.Created.Day() != 2 {
+		t.Errorf("unexpected parse: %+v", e)
+	}
+
+	if e, _ := UnmarshalEntry([]byte("# Plain\n\nno header")); e.Content != "# Plain\n\nno header" {
+		t.Errorf("plain markdown should be content only, got %+v", e)
+	}
+
+	// nested blocks aren't understood, but survive a rewrite as written
+	nested := "---\ntitle: Trip\nauthor:\n  name: Sam\n  links:\n    - a\nsummary: |\n  line one\n\n  line two\ntags: [x]\n---\nbody"
+	e, err = UnmarshalEntry([]byte(nested))
+	if err != nil {
+		t.Fatalf("UnmarshalEntry: %v", err)
+	}
+	if e.Title != "Trip" || !reflect.DeepEqual(e.Tags, []string{"x"}) || e.Content != "body" {
+		t.Errorf("unexpected parse: %+v", e)
+	}
+	again, err := UnmarshalEntry(MarshalEntry(e))
+	if err != nil || !reflect.DeepEqual(again.Fields, e.Fields) {
+		t.Fatalf("nested fields came back as %q, %v", again.Fields, err)
+	}
+	out2 := string(MarshalEntry(e))
+	for _, want := range []string{"author:\n  name: Sam\n  links:\n    - a\n", "summary: |\n  line one\n\n  line two\n"} {
+		if !strings.Contains(out2, want) {
+			t.Errorf("rewrite lost %q:\n%s", want, out2)
+		}
+	}
+}
+
+func TestMigrateMetadata(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "20250825-020644-tags.md"), []byte("# Tags\n\nbody"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"20250825-020644-tags.md": ["tags", "test"], "gone.md": ["x"]}`
+	if err := os.WriteFile(filepath.Join(dir, metaFile), []byte(meta), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFSStore(dir)
+	n, err := s.MigrateMetadata()
+	if err != nil || n != 1 {
+		t.Fatalf("MigrateMetadata = %d, %v", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, metaFile)); !os.IsNotExist(err) {
+		t.Errorf("metadata.json should be retired after migration")
+	}
+	entries, err := s.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List: %d entries, %v", len(entries), err)
+	}
+	e, err := s.Get(entries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.ID == "" || !reflect.DeepEqual(e.Tags, []string{"tags", "test"}) || !strings.HasPrefix(e.Content, "# Tags") {
+		t.Errorf("tags not moved into front matter: %+v", e)
+	}
+	if n, err := s.MigrateMetadata(); n != 0 || err != nil {
+		t.Errorf("second migration should be a no-op, got %d %v", n, err)
+	}
+}
+
+func TestLegacyFilesGetStableIDs(t *testing.T) {
+	dir := t.TempDir()
+	legacy := "# Legacy\n\nno front matter"
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(legacy), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewFSStore(dir)
+	first, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b.md is a copy of a.md, front matter and all; it must get its own id
+	raw, _ := os.ReadFile(filepath.Join(dir, "a.md"))
+	if err := os.WriteFile(filepath.Join(dir, "b.md"), raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewFSStore(dir).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]string{}
+	for _, e := range first {
+		ids[e.Filename] = e.ID
+	}
+	seen := map[string]bool{}
+	for _, e := range second {
+		if e.ID == "" || seen[e.ID] {
+			t.Errorf("%s: missing or duplicate id %q", e.Filename, e.ID)
+		}
+		seen[e.ID] = true
+	}
+	if got, _ := s.Get(ids["a.md"]); got.Filename != "a.md" {
+		t.Errorf("a.md should keep its id across scans, got %q", got.Filename)
+	}
+}
+
+func TestNestedFrontMatterGetsOneHeader(t *testing.T) {
+	dir := t.TempDir()
+	raw := "---\ntitle: Trip\nauthor:\n  name: Sam\n---\n# Trip\n\nbody\n"
+	if err := os.WriteFile(filepath.Join(dir, "trip.md"), []byte(raw), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFSStore(dir)
+	entries, err := s.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("List: %d entries, %v", len(entries), err)
+	}
+	// the id backfill and a later save write the header once, not twice
+	e, _ := s.Get(entries[0].ID)
+	e.Content += "more\n"
+	if _, err := s.Put(e); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "trip.md"))
+	if n := strings.Count(string(data), fmDelim+"\n"); n != 2 || !strings.Contains(string(data), "author:\n  name: Sam\n") {
+		t.Errorf("file now reads:\n%s", data)
+	}
+}
+
+func TestCreatedSurvivesTouch(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"20250825-010202-once.md", "20250825-020644-tags.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("# "+name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// touching the older note must not move it to the top
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "20250825-010202-once.md"), future, future); err != nil {
+		t.Fatal(err)
+	}
+	s := NewFSStore(dir)
+	entries, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Filename != "20250825-020644-tags.md" {
+		t.Errorf("expected order by filename timestamp, got %s first", entries[0].Filename)
+	}
+	want := time.Date(2025, 8, 25, 2, 6, 44, 0, time.Local)
+	if !entries[0].Created.Equal(want) || !entries[0].Updated.Equal(want) {
+		t.Errorf("created/updated = %v/%v, want %v", entries[0].Created, entries[0].Updated, want)
+	}
+
+	// saving bumps updated but keeps created
+	older := entries[1]
+	saved, err := s.Put(older)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Created.Equal(older.Created) || !saved.Updated.After(older.Updated) {
+		t.Errorf("unexpected timestamps after save: %v / %v", saved.Created, saved.Updated)
+	}
+	entries, _ = s.List()
+	SortEntries(entries, SortUpdated)
+	if entries[0].ID != older.ID {
+		t.Errorf("expected the saved entry first when sorting by updated")
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// separate stores behave like separate processes sharing the journal
+			s := NewFSStore(dir)
+			e := Entry{Filename: fmt.Sprintf("note-%d.md", i), Content: strings.Repeat("x", 1<<16)}
+			if _, err := s.Put(e); err != nil {
+				t.Errorf("Put %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".tmp") {
+			t.Errorf("temp file left behind: %s", f.Name())
+		}
+	}
+	entries, err := NewFSStore(dir).List()
+	if err != nil || len(entries) != 8 {
+		t.Fatalf("expected 8 entries, got %d (%v)", len(entries), err)
+	}
+	for _, e := range entries {
+		full, err := NewFSStore(dir).Get(e.ID)
+		if err != nil || len(full.Content) != 1<<16 {
+			t.Errorf("%s: torn write, %d bytes (%v)", e.Filename, len(full.Content), err)
+		}
+	}
+}
+
+func TestLockExcludesOtherWriters(t *testing.T) {
+	s := NewFSStore(t.TempDir())
+	unlock, err := s.lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	old := lockTimeout
+	lockTimeout = 50 * time.Millisecond
+	defer func() { lockTimeout = old }()
+	if _, err := NewFSStore(s.Dir()).Put(Entry{Filename: "x.md"}); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked while another writer holds the lock, got %v", err)
+	}
+}
+
+func TestIndexSkipsUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFSStore(dir)
+	e, err := SaveEntry(s, "Indexed", "body text", []string{"x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.List(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, indexFile)); err != nil {
+		t.Fatalf("expected index file: %v", err)
+	}
+
+	// a fresh store trusts the index while size and mtime match...
+	path := filepath.Join(dir, e.Filename)
+	fi, _ := os.Stat(path)
+	raw, _ := os.ReadFile(path)
+	forged := strings.ReplaceAll(string(raw), "Indexed", "Forgedd")
+	if err := os.WriteFile(path, []byte(forged), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, fi.ModTime(), fi.ModTime())
+	entries, err := NewFSStore(dir).List()
+	if err != nil || entries[0].Title != "Indexed" || entries[0].Snippet != "body text" {
+		t.Fatalf("expected cached record, got %+v (%v)", entries, err)
+	}
+
+	// ...and rereads the file once either changes
+	later := fi.ModTime().Add(time.Second)
+	os.Chtimes(path, later, later)
+	entries, err = NewFSStore(dir).List()
+	if err != nil || entries[0].Title != "Forgedd" {
+		t.Fatalf("expected refreshed record, got %+v (%v)", entries, err)
+	}
+	if entries[0].Content != "" {
+		t.Errorf("List should not load content")
+	}
+	if c, _ := LoadEntryContent(s, entries[0]); !strings.Contains(c, "body text") {
+		t.Errorf("LoadEntryContent = %q", c)
+	}
+}
+
+func TestLoadEntriesProgress(t *testing.T) {
+	for name, s := range map[string]Store{"fs": NewFSStore(t.TempDir()), "mem": NewMemStore()} {
+		for i := 0; i < 3; i++ {
+			if _, err := NewEntry(s, fmt.Sprintf("note %d", i), "x"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		last := 0
+		entries, err := LoadEntriesProgress(s, func(n int) { last = n })
+		if err != nil || len(entries) != 3 {
+			t.Fatalf("%s: got %d entries (%v)", name, len(entries), err)
+		}
+		if _, ok := s.(ProgressLister); ok && last != 3 {
+			t.Errorf("%s: last progress = %d, want 3", name, last)
+		}
+	}
+}
+
+func TestListReportsUnreadableFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFSStore(dir)
+	if _, err := NewEntry(s, "fine", "x"); err != nil {
+		t.Fatal(err)
+	}
+	// a link to a note that is gone can be listed but not read
+	if err := os.Symlink(filepath.Join(dir, "gone.md"), filepath.Join(dir, "broken.md")); err != nil {
+		t.Skip(err)
+	}
+	entries, err := LoadEntriesProgress(s, nil)
+	var unreadable *UnreadableError
+	if !errors.As(err, &unreadable) || len(unreadable.Errs) != 1 || !strings.Contains(err.Error(), "broken.md") {
+		t.Fatalf("expected broken.md reported, got %v", err)
+	}
+	if len(entries) != 1 || entries[0].Title != "fine" {
+		t.Errorf("readable entries = %+v", entries)
+	}
+	if entries, err := s.List(); err != nil || len(entries) != 1 {
+		t.Errorf("List = %d entries, %v", len(entries), err)
+	}
+}
+
+func TestMalformedFrontMatterIsNotRewritten(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFSStore(dir)
+	if _, err := NewEntry(s, "fine", "x"); err != nil {
+		t.Fatal(err)
+	}
+	text := "---\ntitle: Hello\ncreated: last tuesday\ntags: [work]\n---\n# Hello\n"
+	path := filepath.Join(dir, "20250101-120000-hello.md")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := LoadEntriesProgress(s, nil)
+	if err == nil || !strings.Contains(err.Error(), "20250101-120000-hello.md") || len(entries) != 1 {
+		t.Fatalf("expected hello.md reported, got %d entries, %v", len(entries), err)
+	}
+	if _, err := s.Retag(func(tags []string) []string { return append(tags, "extra") }); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != text {
+		t.Errorf("malformed note rewritten:\n%s", got)
+	}
+}
+
+func TestSetTagsAndCountTags(t *testing.T) {
+	for name, s := range map[string]Store{"fs": NewFSStore(t.TempDir()), "mem": NewMemStore()} {
+		a, _ := SaveEntry(s, "a", "x", []string{"work", "draft"})
+		SaveEntry(s, "b", "y", []string{"Work"})
+		saved, err := SetTags(s, a.ID, []string{"work", "1on1"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(saved.Tags, []string{"work", "1on1"}) || !saved.Created.Equal(a.Created) {
+			t.Errorf("%s: saved %v created %v", name, saved.Tags, saved.Created)
+		}
+		entries, _ := LoadEntries(s)
+		got := CountTags(entries)
+		want := []TagCount{{"1on1", 1}, {"Work", 2}}
+		if got[1].Name == "work" {
+			want[1].Name = "work" // first spelling seen depends on list order
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: CountTags = %v, want %v", name, got, want)
+		}
+		if _, err := SetTags(s, NewID(), nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: SetTags on a missing entry: %v", name, err)
+		}
+	}
+}
+
+func TestTagTreeAndHas(t *testing.T) {
+	if !TagHas("Work/X", "work") || !TagHas("work", "WORK") || TagHas("workshop", "work") || TagHas("work", "work/x") {
+		t.Error("TagHas")
+	}
+	if got := CleanTag(" #work / project-x//retro/ "); got != "work/project-x/retro" {
+		t.Errorf("CleanTag = %q", got)
+	}
+	if got := CleanTag(`"quoted" talk`); got != "quoted talk" {
+		t.Errorf("CleanTag = %q", got)
+	}
+	entries := []Entry{
+		{Tags: []string{"work/x/retro", "work/x"}},
+		{Tags: []string{"work/y", "home"}},
+		{Tags: []string{"Work"}},
+	}
+	var got []string
+	for _, n := range TagTree(entries) {
+		got = append(got, fmt.Sprintf("%s%s %d/%d", strings.Repeat(" ", n.Depth), n.Name, n.Own, n.Count))
+	}
+	want := []string{"home 1/1", "work 1/3", " x 1/1", "  retro 1/1", " y 1/1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TagTree = %q, want %q", got, want)
+	}
+}
+
+func TestRenameMergeDeleteTags(t *testing.T) {
+	past := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, s := range map[string]Store{"fs": NewFSStore(t.TempDir()), "mem": NewMemStore()} {
+		a, _ := s.Put(Entry{Title: "a", Content: "# a\n\nx", Tags: []string{"work/x/retro", "home"}, Updated: past})
+		b, _ := SaveEntry(s, "b", "y", []string{"work", "job"})
+		c, _ := s.Put(Entry{Title: "c", Content: "# c\n\nz", Tags: []string{"misc"}, Updated: past})
+		tags := func(e Entry) []string {
+			full, err := s.Get(e.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return full.Tags
+		}
+
+		if _, err := RenameTag(s, "work", "job"); !errors.Is(err, ErrTagExists) {
+			t.Errorf("%s: rename onto a used tag: %v", name, err)
+		}
+		if _, err := RenameTag(s, "nope", "x"); !errors.Is(err, ErrTagNotFound) {
+			t.Errorf("%s: rename a missing tag: %v", name, err)
+		}
+		if n, err := RenameTag(s, "Work", "office"); n != 2 || err != nil {
+			t.Fatalf("%s: rename = %d, %v", name, n, err)
+		}
+		if got := tags(a); !reflect.DeepEqual(got, []string{"office/x/retro", "home"}) {
+			t.Errorf("%s: a = %q", name, got)
+		}
+		if n, err := MergeTags(s, "job", "office"); n != 1 || err != nil {
+			t.Fatalf("%s: merge = %d, %v", name, n, err)
+		}
+		if got := tags(b); !reflect.DeepEqual(got, []string{"office"}) {
+			t.Errorf("%s: b = %q (merged tags dedupe)", name, got)
+		}
+		if n, err := DeleteTag(s, "office/x"); n != 1 || err != nil {
+			t.Fatalf("%s: delete = %d, %v", name, n, err)
+		}
+		if got := tags(a); !reflect.DeepEqual(got, []string{"home"}) {
+			t.Errorf("%s: a = %q", name, got)
+		}
+		if got := tags(c); !reflect.DeepEqual(got, []string{"misc"}) {
+			t.Errorf("%s: c = %q, untouched", name, got)
+		}
+		// retagging is an edit like any other
+		if full, _ := s.Get(a.ID); !full.Updated.After(past) {
+			t.Errorf("%s: retag kept updated at %s", name, full.Updated)
+		}
+		if full, _ := s.Get(c.ID); !full.Updated.Equal(past) {
+			t.Errorf("%s: untouched entry updated at %s", name, full.Updated)
+		}
+	}
+}
+
+func TestRetagBackfillsIDs(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFSStore(dir)
+	a, _ := SaveEntry(s, "a", "x", []string{"old"})
+	// a copy of a made outside the journal, id and all, then edited
+	raw, _ := os.ReadFile(filepath.Join(dir, a.Filename))
+	os.WriteFile(filepath.Join(dir, "copy.md"), append(raw, "more\n"...), 0o644)
+	n, err := s.Retag(func([]string) []string { return []string{"new"} })
+	if n != 2 || err != nil {
+		t.Fatalf("retag = %d, %v", n, err)
+	}
+	entries, _ := LoadEntries(s)
+	if len(entries) != 2 || entries[0].ID == entries[1].ID {
+		t.Fatalf("entries share an id: %+v", entries)
+	}
+	for _, e := range entries {
+		if !reflect.DeepEqual(e.Tags, []string{"new"}) {
+			t.Errorf("%s tags = %q", e.Filename, e.Tags)
+		}
+		// each keeps its own history, not the other's
+		if revs, _ := s.History(e.ID); len(revs) != 1 {
+			t.Errorf("%s has %d revisions", e.Filename, len(revs))
+		}
+	}
+}
+
+func TestRetagRecoversCommittedTx(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFSStore(dir)
+	a, _ := SaveEntry(s, "a", "x", []string{"old"})
+	b, _ := SaveEntry(s, "b", "y", []string{"old"})
+
+	// a writer that committed a retag and crashed after the first rename
+	var renames []txRename
+	for i, e := range []Entry{a, b} {
+		full, _ := s.Get(e.ID)
+		full.Tags = []string{"new"}
+		tmp := fmt.Sprintf(".%s.%d.tmp", full.Filename, i)
+		if err := os.WriteFile(filepath.Join(dir, tmp), MarshalEntry(full), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		renames = append(renames, txRename{Tmp: tmp, Dest: full.Filename})
+	}
+	os.Rename(filepath.Join(dir, renames[0].Tmp), filepath.Join(dir, renames[0].Dest))
+	data, _ := json.Marshal(renames)
+	os.WriteFile(filepath.Join(dir, txFile), data, 0o644)
+
+	// the next writer finishes it
+	if _, err := SaveEntry(s, "c", "z", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []Entry{a, b} {
+		if full, _ := s.Get(e.ID); !reflect.DeepEqual(full.Tags, []string{"new"}) {
+			t.Errorf("%s tags = %q after recovery", e.Title, full.Tags)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, txFile)); !os.IsNotExist(err) {
+		t.Errorf("tx record left behind: %v", err)
+	}
+}
+
+func TestFolders(t *testing.T) {
+	for name, s := range map[string]Store{"fs": NewFSStore(t.TempDir()), "mem": NewMemStore()} {
+		a, err := SaveEntryIn(s, "work/x", "Retro", "a", nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		// same file name in another folder doesn't collide
+		b, err := s.Put(Entry{Title: "Retro", Filename: "home/" + path.Base(a.Filename), Content: "# Retro\n\nb"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if a.Folder() != "work/x" || b.Folder() != "home" || path.Base(a.Filename) != path.Base(b.Filename) {
+			t.Errorf("%s: filenames %s %s", name, a.Filename, b.Filename)
+		}
+		for _, bad := range []string{"../x.md", "/x.md", ".trash/x.md", "a/../x.md"} {
+			if _, err := s.Put(Entry{Title: "x", Filename: bad}); err == nil {
+				t.Errorf("%s: put %q succeeded", name, bad)
+			}
+		}
+		if err := s.MakeFolder("empty/nested"); err != nil {
+			t.Fatal(err)
+		}
+		folders, _ := s.Folders()
+		if want := []string{"empty", "empty/nested", "home", "work", "work/x"}; !reflect.DeepEqual(folders, want) {
+			t.Errorf("%s: folders = %q, want %q", name, folders, want)
+		}
+
+		moved, err := MoveEntry(s, b.ID, "work/x")
+		if err != nil || moved.Folder() != "work/x" || moved.Filename == a.Filename {
+			t.Errorf("%s: move = %s, %v", name, moved.Filename, err)
+		}
+		if err := s.RenameFolder("work", "work/y"); err == nil {
+			t.Errorf("%s: moved a folder into itself", name)
+		}
+		if err := s.RenameFolder("work", "empty"); !errors.Is(err, ErrExists) {
+			t.Errorf("%s: rename onto a folder: %v", name, err)
+		}
+		// an entry where the folder would go stops the rename before anything moves
+		s.Put(Entry{Title: "Notes", Filename: "notes.md", Content: "# Notes"})
+		if err := s.RenameFolder("work", "notes.md"); !errors.Is(err, ErrExists) {
+			t.Errorf("%s: rename onto an entry: %v", name, err)
+		}
+		if got, _ := s.Get(a.ID); got.Folder() != "work/x" {
+			t.Errorf("%s: failed rename moved %s", name, got.Filename)
+		}
+		if err := s.RenameFolder("work", "archive/2025"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := s.Get(a.ID)
+		if err != nil || got.Folder() != "archive/2025/x" {
+			t.Errorf("%s: after folder rename %s, %v", name, got.Filename, err)
+		}
+		got.Content = "# Retro notes\n\na"
+		s.Put(got)
+		if renamed, err := RenameEntry(s, a.ID); err != nil || renamed.Folder() != "archive/2025/x" {
+			t.Errorf("%s: RenameEntry left the folder: %s, %v", name, renamed.Filename, err)
+		}
+		entries, _ := LoadEntries(s)
+		if len(entries) != 3 {
+			t.Errorf("%s: %d entries", name, len(entries))
+		}
+	}
+}
+
+// benchJournal writes n entries (with ids, so no backfill) into a fresh directory
+func benchJournal(b *testing.B, n int) string {
+	b.Helper()
+	dir := b.TempDir()
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		created := base.Add(time.Duration(i) * time.Minute)
+		e := Entry{
+			ID:      newIDAt(created),
+			Title:   fmt.Sprintf("Entry %d", i),
+			Created: created,
+			Tags:    []string{"bench", fmt.Sprintf("t%d", i%50)},
+			Content: fmt.Sprintf("# Entry %d\n\n%s\n", i, strings.Repeat("lorem ipsum dolor sit amet ", 20)),
+		}
+		name := entryFilename(e.Title, created)
+		if err := os.WriteFile(filepath.Join(dir, name), MarshalEntry(e), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func BenchmarkList50k(b *testing.B) {
+	dir := benchJournal(b, 50000)
+	s := NewFSStore(dir)
+	if _, err := s.List(); err != nil { // build the index
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := s.List(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListFromIndex50k(b *testing.B) {
+	dir := benchJournal(b, 50000)
+	if _, err := NewFSStore(dir).List(); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// a new store has to load the index from disk, like a fresh start
+		if _, err := NewFSStore(dir).List(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReloadAfterEdit50k(b *testing.B) {
+	dir := benchJournal(b, 50000)
+	s := NewFSStore(dir)
+	entries, err := s.List()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e, err := s.Get(entries[i%len(entries)].ID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		e.Content += "edited\n"
+		if _, err := s.Put(e); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.List(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkColdList50k(b *testing.B) {
+	dir := benchJournal(b, 50000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		os.Remove(filepath.Join(dir, indexFile))
+		if _, err := NewFSStore(dir).List(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSortEntries50k(b *testing.B) {
+	entries := make([]Entry, 50000)
+	for i := range entries {
+		entries[i].Created = time.Unix(int64((i*7919)%50000), 0)
+	}
+	work := make([]Entry, len(entries))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(work, entries)
+		SortEntries(work, SortCreated)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	for name, s := range map[string]Store{"fs": NewFSStore(t.TempDir()), "mem": NewMemStore()} {
+		a, err := SaveEntryIn(s, "work", "Old plan", "a", []string{"plan"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		b, _ := SaveEntry(s, "Keep", "b", nil)
+		if err := DeleteEntry(s, a); err != nil {
+			t.Fatalf("%s: delete: %v", name, err)
+		}
+		if _, err := s.Get(a.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: trashed entry still listed: %v", name, err)
+		}
+		entries, _ := s.List()
+		if len(entries) != 1 || entries[0].ID != b.ID {
+			t.Errorf("%s: list = %+v", name, entries)
+		}
+		trashed, err := s.Trashed()
+		if err != nil || len(trashed) != 1 {
+			t.Fatalf("%s: trashed = %+v, %v", name, trashed, err)
+		}
+		if got := trashed[0]; got.ID != a.ID || got.Filename != a.Filename || got.Title != "Old plan" ||
+			!reflect.DeepEqual(got.Tags, []string{"plan"}) || time.Since(got.Deleted) > time.Minute {
+			t.Errorf("%s: trashed[0] = %+v", name, got)
+		}
+
+		// restoring puts it back under its old name, or a free one
+		taken, _ := s.Put(Entry{Title: "Old plan", Filename: a.Filename, Content: "# Old plan\n\nnew"})
+		restored, err := s.Restore(a.ID)
+		if err != nil || restored.ID != a.ID || restored.Folder() != "work" || restored.Filename == taken.Filename {
+			t.Errorf("%s: restore = %+v, %v", name, restored, err)
+		}
+		if got, err := s.Get(a.ID); err != nil || !strings.Contains(got.Content, "a") {
+			t.Errorf("%s: restored entry = %+v, %v", name, got, err)
+		}
+		if trashed, _ := s.Trashed(); len(trashed) != 0 {
+			t.Errorf("%s: trash not empty after restore: %+v", name, trashed)
+		}
+
+		// purging is for good
+		for _, id := range []string{a.ID, b.ID} {
+			if err := s.Trash(id); err != nil {
+				t.Fatalf("%s: trash: %v", name, err)
+			}
+		}
+		if n, err := PurgeTrash(s, time.Now().Add(-time.Hour)); n != 0 || err != nil {
+			t.Errorf("%s: purged %d recent entries (%v)", name, n, err)
+		}
+		if err := s.Purge(a.ID); err != nil {
+			t.Fatalf("%s: purge: %v", name, err)
+		}
+		if _, err := s.Restore(a.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: restore after purge: %v", name, err)
+		}
+		if n, err := EmptyTrash(s); n != 1 || err != nil {
+			t.Errorf("%s: emptied %d (%v)", name, n, err)
+		}
+		if trashed, _ := s.Trashed(); len(trashed) != 0 {
+			t.Errorf("%s: trash = %+v", name, trashed)
+		}
+	}
+}
+
+func TestPurgeTrashByAge(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFSStore(dir)
+	old, _ := SaveEntry(s, "Old", "x", nil)
+	recent, _ := SaveEntry(s, "Recent", "y", nil)
+	for _, e := range []Entry{old, recent} {
+		if err := s.Trash(e.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// age the first deletion
+	rec, _ := json.Marshal(trashRecord{Filename: old.Filename, Deleted: time.Now().AddDate(0, 0, -40)})
+	if err := os.WriteFile(filepath.Join(dir, trashDir, old.ID+".json"), rec, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	n, err := PurgeTrash(s, time.Now().AddDate(0, 0, -30))
+	if n != 1 || err != nil {
+		t.Fatalf("purged %d (%v)", n, err)
+	}
+	trashed, _ := s.Trashed()
+	if len(trashed) != 1 || trashed[0].ID != recent.ID {
+		t.Errorf("trash = %+v", trashed)
+	}
+	// the trash never shows up as a folder
+	if folders, _ := s.Folders(); len(folders) != 0 {
+		t.Errorf("folders = %q", folders)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	for name, s := range map[string]Store{"fs": NewFSStore(t.TempDir()), "mem": NewMemStore()} {
+		e, err := SaveEntry(s, "Plan", "first", []string{"a"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if revs, _ := s.History(e.ID); len(revs) != 0 {
+			t.Errorf("%s: new entry has history %+v", name, revs)
+		}
+		for _, body := range []string{"second", "third"} {
+			e.Content = "# Plan\n\n" + body
+			if e, err = s.Put(e); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		if _, err := SetTags(s, e.ID, []string{"b"}); err != nil {
+			t.Fatal(err)
+		}
+		revs, err := s.History(e.ID)
+		if err != nil || len(revs) != 3 {
+			t.Fatalf("%s: history = %+v, %v", name, revs, err)
+		}
+		old, err := s.Revision(e.ID, revs[len(revs)-1].Hash)
+		if err != nil || !strings.Contains(old.Content, "first") || !reflect.DeepEqual(old.Tags, []string{"a"}) {
+			t.Errorf("%s: oldest revision = %+v, %v", name, old, err)
+		}
+		if _, err := s.Revision(e.ID, "../../x"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: unknown revision: %v", name, err)
+		}
+
+		// restoring is a save too, so it can be undone
+		restored, err := RestoreRevision(s, e.ID, old.Hash)
+		if err != nil || !strings.Contains(restored.Content, "first") || !reflect.DeepEqual(restored.Tags, []string{"a"}) {
+			t.Errorf("%s: restored = %+v, %v", name, restored, err)
+		}
+		if revs, _ = s.History(e.ID); len(revs) != 4 {
+			t.Errorf("%s: %d revisions after restore", name, len(revs))
+		}
+
+		if n, err := s.PruneHistory(2, time.Time{}); n != 2 || err != nil {
+			t.Errorf("%s: pruned %d (%v)", name, n, err)
+		}
+		revs, _ = s.History(e.ID)
+		if len(revs) != 2 {
+			t.Fatalf("%s: %d revisions after prune", name, len(revs))
+		}
+		if _, err := s.Revision(e.ID, revs[1].Hash); err != nil {
+			t.Errorf("%s: kept revision: %v", name, err)
+		}
+		if n, _ := s.PruneHistory(0, time.Now().Add(time.Hour)); n != 2 {
+			t.Errorf("%s: pruned %d by age", name, n)
+		}
+
+		// purging an entry drops its history
+		e.Content = "# Plan\n\nfourth"
+		s.Put(e)
+		s.Trash(e.ID)
+		if revs, _ := s.History(e.ID); len(revs) != 1 {
+			t.Errorf("%s: trashed entry lost its history", name)
+		}
+		s.Purge(e.ID)
+		if revs, _ := s.History(e.ID); len(revs) != 0 {
+			t.Errorf("%s: purged entry kept its history", name)
+		}
+	}
+}
+
+func TestPruneHistoryDropsObjects(t *testing.T) {
+	dir := t.TempDir()
+	s := NewFSStore(dir)
+	e, _ := SaveEntry(s, "Draft", "a", nil)
+	full, _ := s.Get(e.ID)
+	for i := 0; i < 3; i++ {
+		full.Content = "# Draft\n\n" + fmt.Sprint(i)
+		if _, err := s.Put(full); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objects, _ := os.ReadDir(filepath.Join(dir, historyDir, "objects"))
+	revs, _ := s.History(e.ID)
+	if len(revs) != 3 || len(objects) != 3 {
+		t.Errorf("%d revisions in %d objects", len(revs), len(objects))
+	}
+	s.PruneHistory(1, time.Time{})
+	if objects, _ = os.ReadDir(filepath.Join(dir, historyDir, "objects")); len(objects) != 1 {
+		t.Errorf("%d objects left after pruning to one revision", len(objects))
+	}
+	// the history never shows up as entries or folders
+	if entries, _ := s.List(); len(entries) != 1 {
+		t.Errorf("%d entries", len(entries))
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	diff := DiffLines("a\nb\nc\nd\n", "a\nc\nx\nd\n")
+	var got []string
+	for _, l := range diff {
+		got = append(got, string(l.Op)+l.Text)
+	}
+	if want := []string{" a", "-b", " c", "+x", " d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("diff = %q, want %q", got, want)
+	}
+	if added, removed := DiffStat(diff); added != 1 || removed != 1 {
+		t.Errorf("stat = +%d -%d", added, removed)
+	}
+	if diff := DiffLines("", "new\n"); len(diff) != 1 || diff[0].Op != '+' {
+		t.Errorf("diff from empty = %+v", diff)
+	}
+}
+
+func TestIDHex(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		id := NewID()
+		h := IDHex(id)
+		back, ok := IDFromHex(h)
+		if len(h) != 32 || !ok || back != id {
+			t.Fatalf("%s -> %s -> %s, %v", id, h, back, ok)
+		}
+		if IDHex(strings.ToLower(id)) != h {
+			t.Errorf("lower case %s hexes differently", id)
+		}
+	}
+	if h := IDHex("legacy-id"); len(h) != 32 || h == IDHex("other-id") {
+		t.Errorf("IDHex(legacy-id) = %s", h)
+	}
+	if _, ok := IDFromHex("xyz"); ok {
+		t.Error("IDFromHex(xyz) succeeded")
+	}
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Deleted entries go to data/.trash: <id>.md is the entry file as it was and
+// <id>.json records where it lived and when it was deleted. The record is
+// written first, so every entry file in the trash has one; a record left
+// without its file by a crash is ignored.
+
+const trashDir = ".trash"
+
+// Trashed is an entry in the trash. Filename is where it lived; Content is
+// left empty.
+type Trashed struct {
+	Entry
+	Deleted time.Time
+}
+
+// trashRecord is the <id>.json kept next to a trashed entry file
+type trashRecord struct {
+	Filename string    `json:"filename"`
+	Deleted  time.Time `json:"deleted"`
+}
+
+// trashPath is the path of the trashed entry file (ext ".md") or its record
+// (ext ".json")
+func (s *FSStore) trashPath(id, ext string) string {
+	return filepath.Join(s.dir, trashDir, id+ext)
+}
+
+// Trash moves the entry file into the trash
+func (s *FSStore) Trash(id string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	name, err := s.filename(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(trashRecord{Filename: name, Deleted: time.Now()})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(s.dir, trashDir), 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.trashPath(id, ".json"), data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(s.abs(name), s.trashPath(id, ".md")); err != nil {
+		os.Remove(s.trashPath(id, ".json"))
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err := syncDir(filepath.Join(s.dir, trashDir)); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(s.abs(name))); err != nil {
+		return err
+	}
+	s.forget(id, name)
+	return nil
+}
+
+// Trashed lists the trash, most recently deleted first
+func (s *FSStore) Trashed() ([]Trashed, error) {
+	dirents, err := os.ReadDir(filepath.Join(s.dir, trashDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []Trashed
+	for _, d := range dirents {
+		id, ok := strings.CutSuffix(d.Name(), ".md")
+		if !ok || !validID(id) {
+			continue
+		}
+		t, err := s.trashed(id)
+		if err != nil {
+			continue
+		}
+		out = append(out, t)
+	}
+	sortTrash(out)
+	return out, nil
+}
+
+// trashed reads the trashed entry with id. Without a usable record (say, a
+// file dropped into the trash by hand) it goes back to the top level and
+// counts as deleted when last modified.
+func (s *FSStore) trashed(id string) (Trashed, error) {
+	e, err := s.read(s.trashPath(id, ".md"), nil)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Trashed{}, ErrNotFound
+	}
+	if err != nil {
+		return Trashed{}, err
+	}
+	e.ID = id
+	e.Content = ""
+	t := Trashed{Entry: e, Deleted: e.ModTime}
+	t.Filename = id + ".md"
+	var rec trashRecord
+	if data, err := os.ReadFile(s.trashPath(id, ".json")); err == nil &&
+		json.Unmarshal(data, &rec) == nil && validName(rec.Filename) == nil {
+		t.Filename, t.Deleted = rec.Filename, rec.Deleted
+	}
+	return t, nil
+}
+
+// Restore moves a trashed entry back to where it lived
+func (s *FSStore) Restore(id string) (Entry, error) {
+	if !validID(id) {
+		return Entry{}, ErrInvalidID
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+	t, err := s.trashed(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	if _, err := s.filename(id); err == nil {
+		return Entry{}, fmt.Errorf("restoring %s: %w", id, ErrExists)
+	}
+	name := UniqueFilename(t.Filename, func(name string) bool {
+		_, err := os.Lstat(s.abs(name))
+		return err == nil
+	})
+	p := s.abs(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Rename(s.trashPath(id, ".md"), p); err != nil {
+		return Entry{}, err
+	}
+	if err := syncDir(filepath.Dir(p)); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Remove(s.trashPath(id, ".json")); err != nil && !os.IsNotExist(err) {
+		return Entry{}, err
+	}
+	e, err := s.read(p, nil)
+	if err != nil {
+		return Entry{}, err
+	}
+	s.remember(e)
+	return e, nil
+}
+
+// Purge deletes a trashed entry file, its record and its history
+func (s *FSStore) Purge(id string) error {
+	if !validID(id) {
+		return ErrInvalidID
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := os.Remove(s.trashPath(id, ".md")); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err := os.Remove(s.trashPath(id, ".json")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := s.dropHistory(id); err != nil {
+		return err
+	}
+	return syncDir(filepath.Join(s.dir, trashDir))
+}
+
+// PurgeTrash deletes the entries trashed before the given time for good and
+// returns how many went
+func PurgeTrash(s Store, before time.Time) (int, error) {
+	return purgeTrash(s, func(t Trashed) bool { return t.Deleted.Before(before) })
+}
+
+// EmptyTrash deletes everything in the trash for good
+func EmptyTrash(s Store) (int, error) {
+	return purgeTrash(s, func(Trashed) bool { return true })
+}
+
+func purgeTrash(s Store, purge func(Trashed) bool) (int, error) {
+	trashed, err := s.Trashed()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, t := range trashed {
+		if !purge(t) {
+			continue
+		}
+		if err := s.Purge(t.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// sortTrash orders trashed entries most recently deleted first
+func sortTrash(trashed []Trashed) {
+	sort.Slice(trashed, func(i, j int) bool {
+		return trashed[i].Deleted.After(trashed[j].Deleted)
+	})
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// txFile records a multi-file write between committing and applying it, so a
+// crash in between is finished by the next writer instead of leaving some
+// files old and some new
+const txFile = ".tx.json"
+
+// txRename moves a staged temp file over its destination (entry names, the
+// temp file sitting next to its destination)
+type txRename struct {
+	Tmp  string `json:"tmp"`
+	Dest string `json:"dest"`
+}
+
+// writeTx writes entries all together: every file is staged first, so a
+// failure leaves the journal untouched, and once the transaction record is on
+// disk the renames are finished even across a crash. Callers hold the lock.
+func (s *FSStore) writeTx(entries []Entry) error {
+	var staged []*atomicFile
+	abort := func() {
+		for _, f := range staged {
+			os.Remove(f.Name())
+		}
+	}
+	renames := make([]txRename, 0, len(entries))
+	for _, e := range entries {
+		f, err := createAtomic(s.abs(e.Filename))
+		if err != nil {
+			abort()
+			return err
+		}
+		if _, err := f.Write(MarshalEntry(e)); err != nil {
+			f.Abort()
+			abort()
+			return err
+		}
+		if err := f.stage(0o644); err != nil {
+			abort()
+			return err
+		}
+		staged = append(staged, f)
+		renames = append(renames, txRename{Tmp: path.Join(path.Dir(e.Filename), filepath.Base(f.Name())), Dest: e.Filename})
+	}
+	data, err := json.Marshal(renames)
+	if err != nil {
+		abort()
+		return err
+	}
+	// the commit point
+	if err := writeFileAtomic(filepath.Join(s.dir, txFile), data, 0o644); err != nil {
+		abort()
+		return err
+	}
+	return s.applyTx(renames)
+}
+
+// applyTx renames the staged files of a committed transaction into place and
+// drops its record. Renames already done by an earlier attempt are skipped.
+func (s *FSStore) applyTx(renames []txRename) error {
+	dirs := map[string]bool{}
+	for _, r := range renames {
+		err := os.Rename(s.abs(r.Tmp), s.abs(r.Dest))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		dirs[filepath.Dir(s.abs(r.Dest))] = true
+	}
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(filepath.Join(s.dir, txFile)); err != nil {
+		return err
+	}
+	return syncDir(s.dir)
+}
+
+// recoverTx applies a transaction left behind by a crashed writer, if any;
+// callers hold the lock
+func (s *FSStore) recoverTx() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, txFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var renames []txRename
+	if err := json.Unmarshal(data, &renames); err != nil {
+		return err
+	}
+	for _, r := range renames {
+		// only ever rename within the journal
+		if validName(r.Dest) != nil || path.Dir(r.Tmp) != path.Dir(r.Dest) || !strings.HasPrefix(path.Base(r.Tmp), ".") {
+			return errors.New("storage: malformed " + txFile)
+		}
+	}
+	return s.applyTx(renames)
+}
@@ -1,7 +1,7 @@
 package components
 
 import (
-	"github.com/NekoLambda/journal-tui/ui/styles"
+	styles "github.com/NekoLambda/journal-tui/ui"
 )
 
 func HelpView() string {
@@ -23,6 +23,22 @@ var (
 	HelpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("#6272A4"))
 
+	// Search match styles
+	MatchStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#282A36")).
+			Background(lipgloss.Color("#F1FA8C"))
+
+	CurrentMatchStyle = lipgloss.NewStyle().
+				Bold(true).
+				Foreground(lipgloss.Color("#282A36")).
+				Background(lipgloss.Color("#FFB86C"))
+
+	// Tag chips
+	TagStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#282A36")).
+			Background(lipgloss.Color("#BD93F9")).
+			Padding(0, 1)
+
 	// Input/Form styles
 	InputStyle = lipgloss.NewStyle().
 			Border(lipgloss.NormalBorder()).